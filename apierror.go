@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// apiError 错误码目录中的一项：稳定的错误码供客户端判断，HTTP 状态码和中英文提示由目录统一维护
+// 新增错误时在这里登记，不要修改已发布的错误码
+type apiError struct {
+	Status int
+	Code   string
+	zh, en string
+}
+
+// message 按语言返回提示信息
+func (e *apiError) message(lang string) string {
+	if lang == "en" {
+		return e.en
+	}
+	return e.zh
+}
+
+// 错误码目录
+var (
+	// 400 请求参数
+	errInvalidRequestBody   = &apiError{http.StatusBadRequest, "INVALID_REQUEST_BODY", "请求数据格式错误", "Invalid request body"}
+	errInvalidID            = &apiError{http.StatusBadRequest, "INVALID_ID", "ID 无效", "Invalid ID"}
+	errInvalidParameter     = &apiError{http.StatusBadRequest, "INVALID_PARAMETER", "参数无效", "Invalid parameter"}
+	errMissingFields        = &apiError{http.StatusBadRequest, "MISSING_REQUIRED_FIELDS", "缺少必填字段", "Missing required fields"}
+	errValidationFailed     = &apiError{http.StatusBadRequest, "VALIDATION_FAILED", "提交的内容未通过校验", "Validation failed"}
+	errNoFile               = &apiError{http.StatusBadRequest, "NO_FILE_UPLOADED", "未选择上传文件", "No file uploaded"}
+	errFileTypeNotAllowed   = &apiError{http.StatusBadRequest, "FILE_TYPE_NOT_ALLOWED", "不支持的文件类型", "File type not allowed"}
+	errFileContentMismatch  = &apiError{http.StatusBadRequest, "FILE_CONTENT_MISMATCH", "文件内容与类型不符", "File content does not match its type"}
+	errInvalidUploadSession = &apiError{http.StatusBadRequest, "INVALID_UPLOAD_SESSION", "分片上传参数无效", "Invalid chunked upload parameters"}
+	errPartSizeMismatch     = &apiError{http.StatusBadRequest, "PART_SIZE_MISMATCH", "分片大小与 chunk_size 不符", "Part size does not match chunk_size"}
+	errPartChecksumMismatch = &apiError{http.StatusBadRequest, "PART_CHECKSUM_MISMATCH", "分片校验失败", "Part checksum mismatch"}
+	errUploadIncomplete     = &apiError{http.StatusBadRequest, "UPLOAD_INCOMPLETE", "分片尚未全部上传", "Upload incomplete"}
+	errChecksumMismatch     = &apiError{http.StatusBadRequest, "CHECKSUM_MISMATCH", "文件完整性校验失败（sha256 不一致）", "Integrity check failed: sha256 mismatch"}
+	errFileTooLarge         = &apiError{http.StatusRequestEntityTooLarge, "FILE_TOO_LARGE", "文件过大", "File too large"}
+
+	// 401/403 身份与权限
+	errUnauthorized       = &apiError{http.StatusUnauthorized, "UNAUTHORIZED", "请先登录", "Login required"}
+	errAccountNotFound    = &apiError{http.StatusUnauthorized, "ACCOUNT_NOT_FOUND", "用户不存在", "Account not found"}
+	errInvalidCredentials = &apiError{http.StatusUnauthorized, "INVALID_CREDENTIALS", "用户名或密码错误", "Invalid username or password"}
+	errPermissionDenied   = &apiError{http.StatusForbidden, "PERMISSION_DENIED", "无权进行此操作", "Permission denied"}
+	errAdminRequired      = &apiError{http.StatusForbidden, "ADMIN_REQUIRED", "需要管理员权限", "Admin permission required"}
+	errSuperAdminRequired = &apiError{http.StatusForbidden, "SUPER_ADMIN_REQUIRED", "需要超级管理员权限", "Super admin permission required"}
+	errRoleNotAssignable  = &apiError{http.StatusForbidden, "ROLE_NOT_ASSIGNABLE", "不能设置超级管理员角色", "Cannot assign the super_admin role"}
+	errUserBanned         = &apiError{http.StatusForbidden, "USER_BANNED", "用户已被封禁", "User is banned"}
+
+	// 404/405/410 资源与路由
+	errNotFound              = &apiError{http.StatusNotFound, "NOT_FOUND", "接口不存在", "Not found"}
+	errMethodNotAllowed      = &apiError{http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "不支持该请求方法", "Method not allowed"}
+	errContentNotFound       = &apiError{http.StatusNotFound, "CONTENT_NOT_FOUND", "内容不存在", "Content not found"}
+	errUserNotFound          = &apiError{http.StatusNotFound, "USER_NOT_FOUND", "用户不存在", "User not found"}
+	errUploadSessionNotFound = &apiError{http.StatusNotFound, "UPLOAD_SESSION_NOT_FOUND", "分片上传会话不存在或已过期", "Upload session not found or expired"}
+	errNotifyMessageNotFound = &apiError{http.StatusNotFound, "NOTIFY_MESSAGE_NOT_FOUND", "未找到发送失败的消息", "Failed message not found"}
+	errEndpointGone          = &apiError{http.StatusGone, "ENDPOINT_GONE", "此接口已废弃，请使用微信登录 /api/user/wechat-login", "This endpoint has been removed, use /api/user/wechat-login"}
+
+	// 429 频率与配额
+	errRateLimited           = &apiError{http.StatusTooManyRequests, "RATE_LIMITED", "请求过于频繁，请稍后重试", "Too many requests, please retry later"}
+	errUploadRateLimited     = &apiError{http.StatusTooManyRequests, "UPLOAD_RATE_LIMITED", "上传过于频繁，请稍后重试", "Too many uploads, please retry later"}
+	errUploadQuotaExceeded   = &apiError{http.StatusTooManyRequests, "UPLOAD_QUOTA_EXCEEDED", "今日上传已达上限", "Upload quota exceeded"}
+	errTooManyUploadSessions = &apiError{http.StatusTooManyRequests, "TOO_MANY_UPLOAD_SESSIONS", "进行中的分片上传过多，请先完成或取消已有上传", "Too many open upload sessions, complete or abort one first"}
+
+	// 5xx 服务端
+	errInternal           = &apiError{http.StatusInternalServerError, "INTERNAL_ERROR", "服务器内部错误，请稍后重试", "Internal server error, please retry later"}
+	errServiceUnavailable = &apiError{http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", "服务暂不可用", "Service unavailable"}
+)
+
+// requestLang 按 Accept-Language 选择提示语言：英文优先时返回 en，否则 zh
+func requestLang(r *http.Request) string {
+	best, bestQ := "zh", 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if lang != "zh" && lang != "en" {
+			continue
+		}
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				q = f
+			}
+		}
+		if q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// errorResponse 生成错误响应体，code 为 HTTP 状态码，error_code 为目录中的错误码
+func errorResponse(r *http.Request, e *apiError, data interface{}) Response {
+	return Response{Code: e.Status, Message: e.message(requestLang(r)), ErrorCode: e.Code, Data: data}
+}
+
+// sendError 返回目录中的错误，HTTP 状态码与 code 一致（server.legacy_status_codes 开启时为 200）
+func sendError(w http.ResponseWriter, r *http.Request, e *apiError) {
+	sendErrorData(w, r, e, nil)
+}
+
+// sendErrorData 返回错误并在 data 中附带说明，如出错的字段、配额用量
+func sendErrorData(w http.ResponseWriter, r *http.Request, e *apiError, data interface{}) {
+	writeResponse(w, errorResponse(r, e, data))
+}
+
+// fieldError 单个参数出错时 data 中的说明
+func fieldError(field string) map[string]interface{} {
+	return map[string]interface{}{"field": field}
+}
+
+// missingFieldsData 缺少必填字段时 data 中的说明
+func missingFieldsData(fields ...string) map[string]interface{} {
+	return map[string]interface{}{"fields": fields}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestLang(t *testing.T) {
+	for _, tc := range []struct {
+		accept string
+		want   string
+	}{
+		{"", "zh"},
+		{"en", "en"},
+		{"en-US,en;q=0.9", "en"},
+		{"zh-CN,zh;q=0.9,en;q=0.8", "zh"},
+		{"fr-FR, en;q=0.5", "en"},
+		{"fr, de", "zh"},
+		{"zh;q=0.3, en;q=0.7", "en"},
+		{"en;q=0, zh;q=0.1", "zh"},
+		{"EN-gb", "en"},
+		{"en;q=abc", "en"},
+		{"zh-TW;q=0.8, en-US;q=0.8", "zh"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.accept != "" {
+			r.Header.Set("Accept-Language", tc.accept)
+		}
+		if got := requestLang(r); got != tc.want {
+			t.Errorf("requestLang(%q) = %q, want %q", tc.accept, got, tc.want)
+		}
+	}
+}
+
+func TestWriteResponseStatus(t *testing.T) {
+	old := config
+	t.Cleanup(func() { config = old })
+	config = &Config{}
+
+	for _, tc := range []struct {
+		name       string
+		legacy     bool
+		send       func(w http.ResponseWriter, r *http.Request)
+		wantStatus int
+		wantCode   int
+		wantError  string
+	}{
+		{"success", false, func(w http.ResponseWriter, r *http.Request) { sendSuccess(w, nil) }, 200, 200, ""},
+		{"not found", false, func(w http.ResponseWriter, r *http.Request) { sendError(w, r, errContentNotFound) }, 404, 404, "CONTENT_NOT_FOUND"},
+		{"rate limited", false, func(w http.ResponseWriter, r *http.Request) { sendError(w, r, errRateLimited) }, 429, 429, "RATE_LIMITED"},
+		{"internal", false, func(w http.ResponseWriter, r *http.Request) { sendError(w, r, errInternal) }, 500, 500, "INTERNAL_ERROR"},
+		{"legacy not found", true, func(w http.ResponseWriter, r *http.Request) { sendError(w, r, errContentNotFound) }, 200, 404, "CONTENT_NOT_FOUND"},
+		{"legacy success", true, func(w http.ResponseWriter, r *http.Request) { sendSuccess(w, nil) }, 200, 200, ""},
+	} {
+		config.Server.LegacyStatusCodes = tc.legacy
+		rec := httptest.NewRecorder()
+		tc.send(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		var resp Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: decode %q: %v", tc.name, rec.Body.String(), err)
+		}
+		if rec.Code != tc.wantStatus || resp.Code != tc.wantCode || resp.ErrorCode != tc.wantError {
+			t.Errorf("%s: status %d, code %d, error_code %q; want %d, %d, %q",
+				tc.name, rec.Code, resp.Code, resp.ErrorCode, tc.wantStatus, tc.wantCode, tc.wantError)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: Content-Type = %q", tc.name, ct)
+		}
+	}
+}
+
+// 错误消息按 Accept-Language 选择语言
+func TestErrorMessageLanguage(t *testing.T) {
+	old := config
+	t.Cleanup(func() { config = old })
+	config = &Config{}
+
+	for lang, want := range map[string]string{"en": errContentNotFound.en, "zh-CN": errContentNotFound.zh} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Language", lang)
+		rec := httptest.NewRecorder()
+		sendError(rec, r, errContentNotFound)
+		var resp Response
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if resp.Message != want {
+			t.Errorf("Accept-Language %s: message = %q, want %q", lang, resp.Message, want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"zxbe_demo/services"
+)
+
+// recordAudit 记录特权或破坏性操作，写入失败只记日志不影响业务
+func recordAudit(r *http.Request, actorID, action, targetType string, targetID interface{}, before, after interface{}) {
+	entry := &services.AuditLog{
+		ActorID:    actorID,
+		ActorRole:  getOperatorRole(actorID),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   fmt.Sprint(targetID),
+		Before:     services.AuditSnapshot(before),
+		After:      services.AuditSnapshot(after),
+		IP:         clientIP(r),
+	}
+	if err := services.RecordAudit(entry); err != nil {
+		log.Printf("❌ 写入审计日志失败: %v", err)
+	}
+}
+
+// clientIP 获取客户端IP，只有请求直接来自可信反向代理时才采信 X-Forwarded-For / X-Real-IP
+// X-Forwarded-For 从右向左跳过可信代理，取第一个不可信的地址；更靠左的地址可由客户端伪造
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !fromTrustedProxy(r) {
+		return host
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		if !isTrustedProxy(addr) {
+			return addr.String()
+		}
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.String()
+	}
+	return host
+}
+
+// 超级管理员查询审计日志
+func auditLogsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	page := 1
+	pageSize := 20
+	if p := q.Get("page"); p != "" {
+		fmt.Sscanf(p, "%d", &page)
+	}
+	if ps := q.Get("page_size"); ps != "" {
+		fmt.Sscanf(ps, "%d", &pageSize)
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	filter := services.AuditFilter{
+		ActorID:    q.Get("actor_id"),
+		Action:     q.Get("action"),
+		TargetType: q.Get("target_type"),
+		TargetID:   q.Get("target_id"),
+	}
+	var err error
+	if filter.Since, err = parseAuditTime(q.Get("since"), false); err != nil {
+		sendErrorData(w, r, errInvalidParameter, fieldError("since"))
+		return
+	}
+	if filter.Until, err = parseAuditTime(q.Get("until"), true); err != nil {
+		sendErrorData(w, r, errInvalidParameter, fieldError("until"))
+		return
+	}
+
+	logs, total, err := services.QueryAuditLogs(filter, page, pageSize)
+	if err != nil {
+		sendError(w, r, errInternal)
+		return
+	}
+
+	sendSuccess(w, map[string]interface{}{
+		"list":      logs,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// parseAuditTime 支持 RFC3339 或 2006-01-02 日期格式；日期作为结束时间时包含当天
+func parseAuditTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	old := trustedProxies
+	trustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+	t.Cleanup(func() { trustedProxies = old })
+
+	for _, tc := range []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		realIP     string
+		want       string
+	}{
+		{"direct", "198.51.100.7:5000", "", "", "198.51.100.7"},
+		{"spoofed XFF from untrusted peer", "198.51.100.7:5000", "1.2.3.4", "", "198.51.100.7"},
+		{"spoofed X-Real-IP from untrusted peer", "198.51.100.7:5000", "", "1.2.3.4", "198.51.100.7"},
+		{"trusted proxy", "10.0.0.2:5000", "203.0.113.9", "", "203.0.113.9"},
+		{"client-supplied hop before proxy", "10.0.0.2:5000", "1.2.3.4, 203.0.113.9", "", "203.0.113.9"},
+		{"proxy chain", "10.0.0.2:5000", "203.0.113.9, 10.0.0.5", "", "203.0.113.9"},
+		{"trusted proxy X-Real-IP", "[::1]:5000", "", "203.0.113.9", "203.0.113.9"},
+		{"garbage XFF", "10.0.0.2:5000", "not-an-ip", "", "10.0.0.2"},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tc.remoteAddr
+		if tc.xff != "" {
+			r.Header.Set("X-Forwarded-For", tc.xff)
+		}
+		if tc.realIP != "" {
+			r.Header.Set("X-Real-IP", tc.realIP)
+		}
+		if got := clientIP(r); got != tc.want {
+			t.Errorf("%s: clientIP = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"zxbe_demo/services"
+)
+
+// initChunkedUpload 应用分片上传会话有效期和数量上限，并启动过期会话清理任务
+func initChunkedUpload(stop <-chan struct{}) {
+	services.ChunkedUploadTTL = time.Duration(config.Upload.SessionTTLHours) * time.Hour
+	services.MaxOpenUploadSessions = config.Upload.MaxOpenSessions
+	services.StartUploadSessionWorker(time.Hour, stop)
+}
+
+// 初始化分片上传
+func chunkedUploadHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		FileName  string `json:"file_name"`
+		Size      int64  `json:"size"`
+		SHA256    string `json:"sha256"`
+		ChunkSize int64  `json:"chunk_size"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, errInvalidRequestBody)
+		return
+	}
+
+	// 检查文件类型（扩展名白名单，真实内容在合并后校验）
+	if _, ok := services.LookupFileKind(filepath.Ext(req.FileName)); !ok {
+		sendError(w, r, errFileTypeNotAllowed)
+		return
+	}
+
+	// 分片上传同样需登录并受频率限制；初始化时按声明大小占用配额，取消或过期时归还，合并完成后按实际大小调整
+	uploaderID, role, ok := uploadIdentity(w, r)
+	if !ok {
+		return
+	}
+	if err := services.AllowUploadRequest(uploaderID, role); err != nil {
+		sendUploadLimitError(w, r, err)
+		return
+	}
+
+	session, err := services.CreateUploadSession(uploaderID, role, req.FileName, req.Size, req.ChunkSize, req.SHA256)
+	if err != nil {
+		var quotaErr *services.QuotaError
+		switch {
+		case errors.Is(err, services.ErrInvalidUploadSession):
+			sendErrorData(w, r, errInvalidUploadSession, map[string]interface{}{"detail": err.Error()})
+			return
+		case errors.Is(err, services.ErrTooManyUploadSessions):
+			sendErrorData(w, r, errTooManyUploadSessions, map[string]interface{}{"max": services.MaxOpenUploadSessions})
+			return
+		case errors.As(err, &quotaErr):
+			sendUploadLimitError(w, r, err)
+			return
+		}
+		log.Printf("❌ 创建分片上传会话失败: %v", err)
+		sendError(w, r, errInternal)
+		return
+	}
+
+	log.Printf("📦 分片上传开始: %s (%s, %d 字节, %d 片)", session.ID, session.FileName, session.Size, session.TotalChunks)
+	sendSuccess(w, session)
+}
+
+// withUploadSession 加载路由中 {id} 对应的分片上传会话，只有创建者本人可以操作
+// GET    /api/upload/chunked/{id}                查询进度（断点续传时获取缺失的分片）
+// PUT    /api/upload/chunked/{id}/parts/{n}      上传第 n 片（从 1 开始），请求体为分片原始内容，可带 X-Part-SHA256 校验
+// POST   /api/upload/chunked/{id}/complete       合并分片、校验整体哈希并保存文件
+// DELETE /api/upload/chunked/{id}                取消上传
+func withUploadSession(next func(http.ResponseWriter, *http.Request, *services.UploadSession)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := services.GetUploadSession(r.PathValue("id"))
+		if err != nil || session.UploaderID != r.Header.Get("X-Wechat-ID") {
+			if err != nil && !errors.Is(err, services.ErrUploadSessionNotFound) {
+				log.Printf("❌ 获取分片上传会话失败: %v", err)
+			}
+			sendError(w, r, errUploadSessionNotFound)
+			return
+		}
+		next(w, r, session)
+	}
+}
+
+func chunkedUploadStatusHandler(w http.ResponseWriter, r *http.Request, session *services.UploadSession) {
+	received, err := services.UploadSessionParts(session.ID)
+	if err != nil {
+		log.Printf("❌ 获取分片列表失败: %v", err)
+		sendError(w, r, errInternal)
+		return
+	}
+	sendSuccess(w, map[string]interface{}{
+		"session":  session,
+		"received": received,
+		"missing":  session.MissingParts(received),
+	})
+}
+
+func chunkedUploadAbortHandler(w http.ResponseWriter, r *http.Request, session *services.UploadSession) {
+	if err := services.AbortUploadSession(session); err != nil {
+		log.Printf("❌ 取消分片上传失败: %v", err)
+		sendError(w, r, errInternal)
+		return
+	}
+	sendSuccess(w, map[string]interface{}{"message": "Upload aborted"})
+}
+
+func chunkedUploadPartHandler(w http.ResponseWriter, r *http.Request, session *services.UploadSession) {
+	n, err := strconv.Atoi(r.PathValue("n"))
+	if err != nil {
+		sendErrorData(w, r, errInvalidParameter, fieldError("n"))
+		return
+	}
+
+	extendReadDeadline(w)
+	body := http.MaxBytesReader(w, r.Body, session.ChunkSize+1)
+	part, err := services.SaveUploadPart(session, n, body, r.Header.Get("X-Part-SHA256"))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.Is(err, services.ErrInvalidPartNumber):
+			sendErrorData(w, r, errInvalidParameter, fieldError("n"))
+		case errors.Is(err, services.ErrPartSizeMismatch), errors.As(err, &tooLarge):
+			sendError(w, r, errPartSizeMismatch)
+		case errors.Is(err, services.ErrPartChecksumMismatch):
+			sendError(w, r, errPartChecksumMismatch)
+		default:
+			log.Printf("❌ 保存分片失败 (%s #%d): %v", session.ID, n, err)
+			sendError(w, r, errInternal)
+		}
+		return
+	}
+	sendSuccess(w, part)
+}
+
+func chunkedUploadCompleteHandler(w http.ResponseWriter, r *http.Request, session *services.UploadSession) {
+	// 合并大文件耗时较长，放宽写入响应的超时
+	extendWriteDeadline(w)
+
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		sendError(w, r, errInternal)
+		return
+	}
+	tmpPath := tmp.Name()
+
+	hash, err := services.AssembleUpload(session, tmp)
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		if errors.Is(err, services.ErrUploadIncomplete) {
+			sendErrorData(w, r, errUploadIncomplete, map[string]interface{}{"detail": err.Error()})
+			return
+		}
+		log.Printf("❌ 合并分片失败 (%s): %v", session.ID, err)
+		sendError(w, r, errInternal)
+		return
+	}
+
+	// 整体哈希不一致时保留会话，客户端可对照各分片哈希重传出错的分片
+	if hash != session.SHA256 {
+		os.Remove(tmpPath)
+		log.Printf("❌ 分片上传校验失败 (%s): 声明 %s，实际 %s", session.ID, session.SHA256, hash)
+		sendError(w, r, errChecksumMismatch)
+		return
+	}
+
+	if err := services.DeleteUploadSession(session); err != nil {
+		log.Printf("⚠️ 清理分片上传会话失败 (%s): %v", session.ID, err)
+	}
+	log.Printf("📦 分片上传完成: %s (%s)", session.ID, session.FileName)
+	finishUpload(w, r, tmpPath, hash, session.Size, session.FileName, session)
+}
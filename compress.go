@@ -0,0 +1,204 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// encoder 一种响应压缩编码，按服务端偏好排列
+type encoder struct {
+	name string
+	pool *sync.Pool // 复用压缩器，元素为 resettableWriter
+}
+
+type resettableWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// brotli 压缩率高于 gzip，q 值相同时优先；动态响应使用中等级别，兼顾压缩率和耗时
+var encoders = []encoder{
+	{name: "br", pool: &sync.Pool{New: func() interface{} {
+		return brotli.NewWriterLevel(io.Discard, 5)
+	}}},
+	{name: "gzip", pool: &sync.Pool{New: func() interface{} {
+		zw, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return zw
+	}}},
+}
+
+// compressResponses 按 Accept-Encoding 协商压缩响应体，超过 minBytes 且为文本类内容时才压缩
+// 先缓存响应体开头的 minBytes 字节，据此决定是否压缩，之后的内容直接流式写出
+func compressResponses(minBytes int) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			enc, ok := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if !ok || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// 处理函数 panic 时不写出已缓存的内容，由错误恢复中间件返回 500
+			cw := &compressWriter{ResponseWriter: w, enc: enc, minBytes: minBytes}
+			next.ServeHTTP(cw, r)
+			cw.Close()
+		})
+	}
+}
+
+// negotiateEncoding 选择客户端可接受（q > 0）且 q 值最高的编码，q 值相同时按服务端偏好
+func negotiateEncoding(accept string) (encoder, bool) {
+	best, bestQ := encoder{}, 0.0
+	for _, enc := range encoders {
+		if q := acceptQuality(accept, enc.name); q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// acceptQuality 返回 Accept-Encoding 中某编码的 q 值，未列出时按 * 的 q 值
+func acceptQuality(accept, name string) float64 {
+	wildcard := 0.0
+	for _, part := range strings.Split(accept, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != name && coding != "*" {
+			continue
+		}
+		v := 1.0
+		if k, val, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+				v = f
+			}
+		}
+		if coding == name {
+			return v
+		}
+		wildcard = v
+	}
+	return wildcard
+}
+
+// compressWriter 缓存响应体开头部分，决定压缩后改写响应头并通过压缩器写出
+type compressWriter struct {
+	http.ResponseWriter
+	enc      encoder
+	minBytes int
+	status   int
+	buf      []byte
+	decided  bool
+	zw       resettableWriter
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.minBytes {
+			return len(p), nil
+		}
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.zw != nil {
+		return cw.zw.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// decide 根据状态码、内容类型和已缓存的长度决定是否压缩，写出响应头和已缓存的内容
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	h := cw.ResponseWriter.Header()
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if len(cw.buf) >= cw.minBytes && cw.status == http.StatusOK &&
+		h.Get("Content-Encoding") == "" && h.Get("Content-Range") == "" && compressible(h.Get("Content-Type")) {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.enc.name)
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag) // 压缩后的内容与原始内容字节不同，只能作为弱校验
+		}
+		cw.zw = cw.enc.pool.Get().(resettableWriter)
+		cw.zw.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.zw != nil {
+		_, err = cw.zw.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Close 写出尚未决定的内容（小于阈值），结束压缩流并归还压缩器
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		if cw.status == 0 && len(cw.buf) == 0 {
+			return nil // 处理函数未写任何内容，交给 net/http 默认处理
+		}
+		if err := cw.decide(); err != nil {
+			return err
+		}
+	}
+	if cw.zw == nil {
+		return nil
+	}
+	err := cw.zw.Close()
+	cw.enc.pool.Put(cw.zw)
+	cw.zw = nil
+	return err
+}
+
+// Flush 立即写出已缓存的内容
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide()
+	}
+	if zw, ok := cw.zw.(interface{ Flush() error }); ok {
+		zw.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap 供 http.ResponseController 访问底层连接
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// compressible 只压缩 JSON、文本等可压缩的内容，图片和压缩包等跳过
+func compressible(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mt, "text/") || mt == "application/json" ||
+		mt == "application/javascript" || mt == "application/xml" || strings.HasSuffix(mt, "+json") || strings.HasSuffix(mt, "+xml")
+}
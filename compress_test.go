@@ -0,0 +1,113 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	for _, tc := range []struct {
+		accept string
+		want   string // 空表示不压缩
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"br", "br"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+		{"*", "br"},
+		{"*;q=0.1, gzip;q=0.5", "gzip"},
+		{"GZIP", "gzip"},
+	} {
+		got := ""
+		if enc, ok := negotiateEncoding(tc.accept); ok {
+			got = enc.name
+		}
+		if got != tc.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tc.accept, got, tc.want)
+		}
+	}
+}
+
+// serveCompressed 经压缩中间件返回指定类型和内容的响应
+func serveCompressed(minBytes int, contentType, body, acceptEncoding string, header ...string) *httptest.ResponseRecorder {
+	h := compressResponses(minBytes)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		for i := 0; i+1 < len(header); i += 2 {
+			w.Header().Set(header[i], header[i+1])
+		}
+		// 分多次写入，覆盖先缓存后流式写出的路径
+		for len(body) > 0 {
+			n := min(len(body), 100)
+			io.WriteString(w, body[:n])
+			body = body[n:]
+		}
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCompressResponses(t *testing.T) {
+	body := `{"list":[` + strings.Repeat(`{"title":"乡村动态","view_count":12},`, 100) + `{}]}`
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+	}
+	for _, enc := range []string{"gzip", "br"} {
+		rec := serveCompressed(1024, "application/json", body, enc, "ETag", `"abc"`)
+		if got := rec.Header().Get("Content-Encoding"); got != enc {
+			t.Fatalf("%s: Content-Encoding = %q", enc, got)
+		}
+		if rec.Body.Len() >= len(body) {
+			t.Errorf("%s: compressed %d bytes into %d", enc, len(body), rec.Body.Len())
+		}
+		if got := rec.Header().Get("ETag"); got != `W/"abc"` {
+			t.Errorf("%s: ETag = %q, want weak W/\"abc\"", enc, got)
+		}
+		if !strings.Contains(rec.Header().Get("Vary"), "Accept-Encoding") {
+			t.Errorf("%s: Vary = %q, want Accept-Encoding", enc, rec.Header().Get("Vary"))
+		}
+		r, err := decoders[enc](rec.Body)
+		if err != nil {
+			t.Fatalf("%s: open decoder: %v", enc, err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: decode: %v", enc, err)
+		}
+		if string(got) != body {
+			t.Errorf("%s: decoded body differs from original", enc)
+		}
+	}
+}
+
+func TestCompressResponsesSkips(t *testing.T) {
+	large := strings.Repeat("x", 2048)
+	for name, rec := range map[string]*httptest.ResponseRecorder{
+		"below threshold":          serveCompressed(1024, "application/json", `{"code":200}`, "gzip, br"),
+		"image":                    serveCompressed(1024, "image/png", large, "gzip, br"),
+		"not accepted":             serveCompressed(1024, "application/json", large, "identity"),
+		"already encoded":          serveCompressed(1024, "text/plain", large, "gzip", "Content-Encoding", "gzip"),
+		"partial content":          serveCompressed(1024, "text/plain", large, "gzip", "Content-Range", "bytes 0-2047/4096"),
+		"missing media type":       serveCompressed(1024, "", large, "gzip"),
+		"one byte under threshold": serveCompressed(len(large)+1, "text/plain", large, "gzip"),
+	} {
+		if got := rec.Header().Get("Content-Encoding"); got != "" && name != "already encoded" {
+			t.Errorf("%s: Content-Encoding = %q, want none", name, got)
+		}
+		if name != "below threshold" && rec.Body.Len() != len(large) {
+			t.Errorf("%s: body length %d, want %d uncompressed", name, rec.Body.Len(), len(large))
+		}
+	}
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"zxbe_demo/services"
+)
+
+// Config 服务配置。加载优先级：命令行参数 > 环境变量（含 .env 文件）> 配置文件 > 默认值
+// 每个字段通过标签声明：json 为配置文件中的键，env 为环境变量名，flag 为命令行参数名（密钥类不提供命令行参数，
+// 避免出现在进程列表中），default 为默认值，usage 为说明
+type Config struct {
+	Server     ServerConfig     `json:"server"`
+	Database   DatabaseConfig   `json:"database"`
+	Log        LogConfig        `json:"log"`
+	Auth       AuthConfig       `json:"auth"`
+	Upload     UploadConfig     `json:"upload"`
+	Content    ContentConfig    `json:"content"`
+	Cache      CacheConfig      `json:"cache"`
+	Health     HealthConfig     `json:"health"`
+	Validation ValidationConfig `json:"validation"`
+	Wechat     WechatConfig     `json:"wechat"`
+}
+
+// ServerConfig HTTP 服务配置
+type ServerConfig struct {
+	Addr             string   `json:"addr" env:"LISTEN_ADDR" flag:"addr" default:":8080" usage:"监听地址（也可用 PORT 环境变量只指定端口）"`
+	PublicBaseURL    string   `json:"public_base_url" env:"PUBLIC_BASE_URL" flag:"public-url" usage:"对外访问地址，如 https://zx.example.com；为空时按请求推断"`
+	CORSOrigins      []string `json:"cors_origins" env:"CORS_ALLOWED_ORIGINS" flag:"cors-origins" default:"*" usage:"允许跨域访问的来源，逗号分隔，* 表示任意来源"`
+	TrustedProxies   []string `json:"trusted_proxies" env:"TRUSTED_PROXIES" default:"127.0.0.1,::1" usage:"反向代理地址（IP 或 CIDR），逗号分隔，只采信来自这些地址的 X-Forwarded-Host/Proto/For 和 X-Real-IP"`
+	MetricsToken     string   `json:"metrics_token" env:"METRICS_TOKEN" usage:"访问 /metrics 所需的 Bearer 令牌，为空时不校验"`
+	CompressMinBytes int      `json:"compress_min_bytes" env:"COMPRESS_MIN_BYTES" default:"1024" usage:"响应体达到该字节数时按 Accept-Encoding 压缩（br、gzip）"`
+	// 旧版小程序先判断 statusCode == 200 再读取响应体中的 code，全部升级前需要开启
+	LegacyStatusCodes bool `json:"legacy_status_codes" env:"LEGACY_STATUS_CODES" default:"false" usage:"错误响应也返回 HTTP 200，只在响应体 code 中体现错误（兼容旧版小程序）"`
+
+	ReadHeaderTimeoutSeconds int `json:"read_header_timeout_seconds" env:"SERVER_READ_HEADER_TIMEOUT" default:"10" usage:"读取请求头超时（秒）"`
+	ReadTimeoutSeconds       int `json:"read_timeout_seconds" env:"SERVER_READ_TIMEOUT" default:"60" usage:"读取整个请求超时（秒），上传接口单独放宽"`
+	WriteTimeoutSeconds      int `json:"write_timeout_seconds" env:"SERVER_WRITE_TIMEOUT" default:"60" usage:"写入响应超时（秒），文件下载单独放宽"`
+	IdleTimeoutSeconds       int `json:"idle_timeout_seconds" env:"SERVER_IDLE_TIMEOUT" default:"120" usage:"keep-alive 空闲连接超时（秒）"`
+	MaxHeaderBytes           int `json:"max_header_bytes" env:"SERVER_MAX_HEADER_BYTES" default:"65536" usage:"请求头最大字节数"`
+	ShutdownTimeoutSeconds   int `json:"shutdown_timeout_seconds" env:"SERVER_SHUTDOWN_TIMEOUT" default:"30" usage:"收到退出信号后等待请求处理完成的最长时间（秒）"`
+}
+
+// DatabaseConfig 数据库配置
+type DatabaseConfig struct {
+	DSN string `json:"dsn" env:"DATABASE_DSN" flag:"db" default:"./zxbe_new.db?_busy_timeout=10000&_journal_mode=WAL&_synchronous=NORMAL&_cache_size=1000&_foreign_keys=1" usage:"SQLite 数据库 DSN（文件路径及连接参数）"`
+}
+
+// LogConfig 日志配置
+type LogConfig struct {
+	Level  string `json:"level" env:"LOG_LEVEL" flag:"log-level" default:"info" usage:"日志级别：debug、info、warn、error"`
+	Format string `json:"format" env:"LOG_FORMAT" flag:"log-format" default:"text" usage:"日志格式：text 或 json"`
+}
+
+// AuthConfig 认证相关密钥
+type AuthConfig struct {
+	TokenSecret   string `json:"token_secret" env:"TOKEN_SECRET" usage:"登录令牌签名密钥，为空时每次启动随机生成"`
+	AdminPassword string `json:"admin_password" env:"ADMIN_DEFAULT_PASSWORD" default:"123456" usage:"默认管理员账号 admin 首次创建时的密码"`
+}
+
+// UploadConfig 上传文件配置
+type UploadConfig struct {
+	Dir             string `json:"dir" env:"UPLOAD_DIR" flag:"upload-dir" default:"./uploads" usage:"本地上传文件目录"`
+	StorageBackend  string `json:"storage_backend" env:"STORAGE_BACKEND" default:"local" usage:"上传文件存储：local 或 s3"`
+	S3Endpoint      string `json:"s3_endpoint" env:"S3_ENDPOINT" usage:"S3 兼容存储地址"`
+	S3Bucket        string `json:"s3_bucket" env:"S3_BUCKET" usage:"S3 桶名"`
+	S3Region        string `json:"s3_region" env:"S3_REGION" default:"us-east-1" usage:"S3 区域"`
+	S3AccessKey     string `json:"s3_access_key" env:"S3_ACCESS_KEY" usage:"S3 Access Key"`
+	S3SecretKey     string `json:"s3_secret_key" env:"S3_SECRET_KEY" usage:"S3 Secret Key"`
+	S3PublicURL     string `json:"s3_public_url" env:"S3_PUBLIC_URL" usage:"S3 桶的公开访问地址，为空时通过 /uploads/ 代理"`
+	GCGraceHours    int    `json:"gc_grace_hours" env:"UPLOAD_GC_GRACE_HOURS" default:"24" usage:"孤立文件宽限期（小时）"`
+	QuarantineDays  int    `json:"quarantine_days" env:"UPLOAD_QUARANTINE_DAYS" default:"7" usage:"孤立文件隔离期（天）"`
+	SessionTTLHours int    `json:"session_ttl_hours" env:"UPLOAD_SESSION_TTL_HOURS" default:"24" usage:"分片上传会话有效期（小时）"`
+	MaxOpenSessions int    `json:"max_open_sessions" env:"UPLOAD_MAX_OPEN_SESSIONS" default:"5" usage:"每个用户同时进行中的分片上传会话数，0 表示不限制"`
+	QuotaUser       string `json:"quota_user" env:"UPLOAD_QUOTA_USER" default:"50:50:10" usage:"普通用户上传限制 每日MB:每日文件数:每分钟次数"`
+	QuotaVIP        string `json:"quota_vip" env:"UPLOAD_QUOTA_VIP" default:"500:300:30" usage:"VIP 用户上传限制"`
+	QuotaAdmin      string `json:"quota_admin" env:"UPLOAD_QUOTA_ADMIN" default:"0:0:0" usage:"管理员上传限制"`
+}
+
+// ContentConfig 内容相关配置
+type ContentConfig struct {
+	HistoryLimit         int `json:"history_limit" env:"HISTORY_LIMIT" default:"100" usage:"每个用户保留的浏览记录条数"`
+	RecycleRetentionDays int `json:"recycle_retention_days" env:"RECYCLE_RETENTION_DAYS" default:"30" usage:"回收站保留天数"`
+}
+
+// CacheConfig 内存缓存配置（轮播图、分类列表、最新和热门列表）
+type CacheConfig struct {
+	MaxEntries int `json:"max_entries" env:"CACHE_MAX_ENTRIES" default:"1000" usage:"内存缓存最多保留的条目数"`
+	TTLSeconds int `json:"ttl_seconds" env:"CACHE_TTL_SECONDS" default:"60" usage:"缓存有效期（秒），内容修改时立即失效，浏览量等计数最多延迟该时间"`
+}
+
+// HealthConfig 就绪检查（/readyz）阈值
+type HealthConfig struct {
+	MaxWALMB   int `json:"max_wal_mb" env:"HEALTH_MAX_WAL_MB" default:"64" usage:"WAL 文件超过该大小（MB）时就绪检查告警"`
+	WarnFreeMB int `json:"warn_free_mb" env:"HEALTH_WARN_FREE_MB" default:"1024" usage:"上传目录所在磁盘剩余空间低于该值（MB）时告警"`
+	MinFreeMB  int `json:"min_free_mb" env:"HEALTH_MIN_FREE_MB" default:"100" usage:"上传目录所在磁盘剩余空间低于该值（MB）时判定未就绪"`
+}
+
+// ValidationConfig 内容字段的可选值，逗号分隔，为空表示不限制
+type ValidationConfig struct {
+	NewsCategories         []string `json:"news_categories" env:"VALIDATION_NEWS_CATEGORIES" default:"乡村动态,政策解读,产业发展,文化旅游,通知公告,其他" usage:"资讯分类可选值"`
+	PolicyCategories       []string `json:"policy_categories" env:"VALIDATION_POLICY_CATEGORIES" default:"乡村振兴,土地政策,农业补贴,产业扶持,社会保障,其他" usage:"政策分类可选值"`
+	TourismCategories      []string `json:"tourism_categories" env:"VALIDATION_TOURISM_CATEGORIES" default:"自然风光,人文古迹,农家乐,采摘体验,民俗文化,其他" usage:"景区分类可选值"`
+	HelpCategories         []string `json:"help_categories" env:"VALIDATION_HELP_CATEGORIES" default:"农业生产,生活求助,医疗健康,交通出行,其他" usage:"求助分类可选值"`
+	HelpUrgencies          []string `json:"help_urgencies" env:"VALIDATION_HELP_URGENCIES" default:"紧急,一般,urgent,normal" usage:"求助紧急程度可选值"`
+	JobTypes               []string `json:"job_types" env:"VALIDATION_JOB_TYPES" default:"全职,兼职,临时工,实习" usage:"招聘工作类型可选值"`
+	ConsultationCategories []string `json:"consultation_categories" env:"VALIDATION_CONSULTATION_CATEGORIES" default:"政策咨询,技术咨询,市场咨询,其他" usage:"咨询分类可选值"`
+	FeedbackTypes          []string `json:"feedback_types" env:"VALIDATION_FEEDBACK_TYPES" default:"功能建议,问题反馈,内容投诉,其他" usage:"意见反馈类型可选值"`
+}
+
+// WechatConfig 微信小程序配置
+type WechatConfig struct {
+	AppID                   string `json:"app_id" env:"WECHAT_APPID" usage:"小程序 AppID"`
+	AppSecret               string `json:"app_secret" env:"WECHAT_APPSECRET" usage:"小程序 AppSecret"`
+	TplConsultationAnswered string `json:"tpl_consultation_answered" env:"WECHAT_TPL_CONSULTATION_ANSWERED" usage:"咨询已回复订阅消息模板ID"`
+	TplBookingConfirmed     string `json:"tpl_booking_confirmed" env:"WECHAT_TPL_BOOKING_CONFIRMED" usage:"预约已确认订阅消息模板ID"`
+	TplUrgentHelp           string `json:"tpl_urgent_help" env:"WECHAT_TPL_URGENT_HELP" usage:"附近紧急求助订阅消息模板ID"`
+	UrgentHelpRadiusKm      int    `json:"urgent_help_radius_km" env:"URGENT_HELP_RADIUS_KM" default:"10" usage:"紧急求助通知求助位置周围多少公里内的用户"`
+}
+
+// 当前生效的配置
+var config *Config
+
+// loadConfig 依次应用默认值、配置文件（-config 或 CONFIG_FILE 指定的 JSON 文件）、
+// .env 文件与环境变量、命令行参数，并校验结果
+func loadConfig(args []string) (*Config, error) {
+	cfg := &Config{}
+	var errs []error
+	eachConfigField(cfg, func(key string, f reflect.StructField, v reflect.Value) {
+		if def, ok := f.Tag.Lookup("default"); ok {
+			if err := setConfigValue(v, def); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid default %q: %w", key, def, err))
+			}
+		}
+	})
+
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	configFile := fs.String("config", "", "配置文件路径（JSON），也可用 CONFIG_FILE 环境变量指定")
+	flagValues := map[string]*string{}
+	eachConfigField(cfg, func(key string, f reflect.StructField, v reflect.Value) {
+		if name := f.Tag.Get("flag"); name != "" {
+			usage := f.Tag.Get("usage")
+			if def := f.Tag.Get("default"); def != "" {
+				usage += "（默认 " + def + "）"
+			}
+			flagValues[name] = fs.String(name, "", usage)
+		}
+	})
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// .env 中的变量不覆盖已存在的环境变量
+	if err := loadDotEnv(".env"); err != nil {
+		return nil, err
+	}
+
+	path := *configFile
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	eachConfigField(cfg, func(key string, f reflect.StructField, v reflect.Value) {
+		if name := f.Tag.Get("env"); name != "" {
+			if s, ok := os.LookupEnv(name); ok {
+				if err := setConfigValue(v, s); err != nil {
+					errs = append(errs, fmt.Errorf("%s: invalid value %q: %w", name, s, err))
+				}
+			}
+		}
+	})
+	// 容器平台常用 PORT 指定端口
+	if _, ok := os.LookupEnv("LISTEN_ADDR"); !ok {
+		if port := os.Getenv("PORT"); port != "" {
+			cfg.Server.Addr = ":" + port
+		}
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	eachConfigField(cfg, func(key string, f reflect.StructField, v reflect.Value) {
+		if name := f.Tag.Get("flag"); set[name] {
+			if err := setConfigValue(v, *flagValues[name]); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: invalid value %q: %w", name, *flagValues[name], err))
+			}
+		}
+	})
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile 读取 JSON 配置文件，出现未知的键时报错以便发现拼写错误
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open config file: %w", err)
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate 校验配置，返回所有问题
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if _, port, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		fail("server.addr", "%v", err)
+	} else if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		fail("server.addr", "invalid port %q", port)
+	}
+	if c.Server.PublicBaseURL != "" {
+		if u, err := url.Parse(c.Server.PublicBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("server.public_base_url", "must be an absolute http(s) URL, got %q", c.Server.PublicBaseURL)
+		}
+	}
+	for _, p := range c.Server.TrustedProxies {
+		if _, err := parseTrustedProxy(p); err != nil {
+			fail("server.trusted_proxies", "invalid IP or CIDR %q", p)
+		}
+	}
+	if len(c.Server.CORSOrigins) == 0 {
+		fail("server.cors_origins", "must not be empty (use * to allow any origin)")
+	}
+	for _, o := range c.Server.CORSOrigins {
+		if o == "*" {
+			continue
+		}
+		if u, err := url.Parse(o); err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			fail("server.cors_origins", "invalid origin %q (expected scheme://host[:port])", o)
+		}
+	}
+
+	if c.Wechat.UrgentHelpRadiusKm <= 0 {
+		fail("wechat.urgent_help_radius_km", "must be positive, got %d", c.Wechat.UrgentHelpRadiusKm)
+	}
+
+	if c.Database.DSN == "" {
+		fail("database.dsn", "must not be empty")
+	}
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		fail("log.level", "must be one of debug, info, warn, error, got %q", c.Log.Level)
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		fail("log.format", "must be text or json, got %q", c.Log.Format)
+	}
+	if c.Auth.AdminPassword == "" {
+		fail("auth.admin_password", "must not be empty")
+	}
+
+	if c.Upload.Dir == "" {
+		fail("upload.dir", "must not be empty")
+	}
+	switch c.Upload.StorageBackend {
+	case "local":
+	case "s3":
+		if c.Upload.S3Endpoint == "" || c.Upload.S3Bucket == "" {
+			fail("upload", "storage_backend s3 requires s3_endpoint and s3_bucket")
+		}
+	default:
+		fail("upload.storage_backend", "must be local or s3, got %q", c.Upload.StorageBackend)
+	}
+	for _, f := range []struct {
+		key   string
+		value int
+	}{
+		{"server.read_header_timeout_seconds", c.Server.ReadHeaderTimeoutSeconds},
+		{"server.read_timeout_seconds", c.Server.ReadTimeoutSeconds},
+		{"server.write_timeout_seconds", c.Server.WriteTimeoutSeconds},
+		{"server.idle_timeout_seconds", c.Server.IdleTimeoutSeconds},
+		{"server.max_header_bytes", c.Server.MaxHeaderBytes},
+		{"server.shutdown_timeout_seconds", c.Server.ShutdownTimeoutSeconds},
+		{"server.compress_min_bytes", c.Server.CompressMinBytes},
+		{"upload.gc_grace_hours", c.Upload.GCGraceHours},
+		{"upload.quarantine_days", c.Upload.QuarantineDays},
+		{"upload.session_ttl_hours", c.Upload.SessionTTLHours},
+		{"content.history_limit", c.Content.HistoryLimit},
+		{"content.recycle_retention_days", c.Content.RecycleRetentionDays},
+		{"cache.max_entries", c.Cache.MaxEntries},
+		{"cache.ttl_seconds", c.Cache.TTLSeconds},
+		{"health.max_wal_mb", c.Health.MaxWALMB},
+		{"health.warn_free_mb", c.Health.WarnFreeMB},
+		{"health.min_free_mb", c.Health.MinFreeMB},
+	} {
+		if f.value <= 0 {
+			fail(f.key, "must be positive, got %d", f.value)
+		}
+	}
+	if c.Upload.MaxOpenSessions < 0 {
+		fail("upload.max_open_sessions", "must not be negative, got %d", c.Upload.MaxOpenSessions)
+	}
+	if c.Health.WarnFreeMB < c.Health.MinFreeMB {
+		fail("health", "warn_free_mb (%d) must not be less than min_free_mb (%d)", c.Health.WarnFreeMB, c.Health.MinFreeMB)
+	}
+	for _, f := range []struct{ key, value string }{
+		{"upload.quota_user", c.Upload.QuotaUser},
+		{"upload.quota_vip", c.Upload.QuotaVIP},
+		{"upload.quota_admin", c.Upload.QuotaAdmin},
+	} {
+		if _, err := parseUploadQuota(f.value); err != nil {
+			fail(f.key, "%v", err)
+		}
+	}
+	if (c.Wechat.AppID == "") != (c.Wechat.AppSecret == "") {
+		fail("wechat", "app_id and app_secret must be set together")
+	}
+
+	return errors.Join(errs...)
+}
+
+// parseUploadQuota 解析 "每日MB:每日文件数:每分钟次数"，0 表示不限
+func parseUploadQuota(s string) (services.UploadQuota, error) {
+	var mb int64
+	var files, perMinute int
+	if n, err := fmt.Sscanf(s, "%d:%d:%d", &mb, &files, &perMinute); err != nil || n != 3 || mb < 0 || files < 0 || perMinute < 0 {
+		return services.UploadQuota{}, fmt.Errorf("expected daily_mb:daily_files:per_minute, got %q", s)
+	}
+	return services.UploadQuota{DailyBytes: mb << 20, DailyFiles: files, PerMinute: perMinute}, nil
+}
+
+// eachConfigField 遍历各配置分组中的字段，key 为 "分组.字段" 形式（与配置文件的键一致）
+func eachConfigField(cfg *Config, fn func(key string, f reflect.StructField, v reflect.Value)) {
+	root := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Field(i)
+		sectionKey := jsonKey(root.Type().Field(i))
+		for j := 0; j < section.NumField(); j++ {
+			f := section.Type().Field(j)
+			fn(sectionKey+"."+jsonKey(f), f, section.Field(j))
+		}
+	}
+}
+
+func jsonKey(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// setConfigValue 按字段类型解析字符串值，列表以逗号分隔
+func setConfigValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(strings.TrimSpace(s))
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		var list []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported config field type %s", v.Type())
+	}
+	return nil
+}
+
+// loadDotEnv 读取 .env 文件（KEY=VALUE，支持 # 注释、export 前缀和引号），文件不存在时忽略
+func loadDotEnv(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		if _, exists := os.LookupEnv(key); !exists {
+			os.Setenv(key, value)
+		}
+	}
+	return scanner.Err()
+}
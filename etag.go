@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"zxbe_demo/services"
+)
+
+// conditionalGET 为 GET/HEAD 的 200 响应生成 ETag，客户端缓存仍有效时返回 304 不带响应体
+// 处理函数已设置 ETag（如详情接口按内容版本计算）时直接使用，否则取响应体哈希；ETag 使用弱校验，压缩前后保持一致
+// 优先比较 If-None-Match；没有时再按处理函数设置的 Last-Modified 比较 If-Modified-Since
+func conditionalGET(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buf := &bufferedResponse{ResponseWriter: w}
+		next.ServeHTTP(buf, r)
+
+		status := buf.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			w.Write(buf.body.Bytes())
+			return
+		}
+
+		h := w.Header()
+		if h.Get("ETag") == "" {
+			sum := sha256.Sum256(buf.body.Bytes())
+			h.Set("ETag", `W/"`+hex.EncodeToString(sum[:16])+`"`)
+		}
+		if h.Get("Cache-Control") == "" {
+			h.Set("Cache-Control", "private, no-cache")
+		}
+		h.Add("Vary", "X-Wechat-ID")
+
+		if notModified(r, h) {
+			h.Del("Content-Type")
+			h.Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(status)
+		w.Write(buf.body.Bytes())
+	})
+}
+
+// notModified 判断客户端缓存的版本是否仍是最新的
+// Last-Modified 只反映内容本身的修改，带 X-Wechat-ID 的请求中收藏状态随用户变化，只按 ETag 判断
+func notModified(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := strings.TrimPrefix(h.Get("ETag"), "W/")
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if r.Header.Get("X-Wechat-ID") != "" {
+		return false
+	}
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lm, err := http.ParseTime(h.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !lm.After(ims)
+}
+
+// setLastModified 以内容的修改时间设置 Last-Modified 响应头
+func setLastModified(w http.ResponseWriter, t time.Time) {
+	if !t.IsZero() {
+		w.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+	}
+}
+
+// setListLastModified 列表的 Last-Modified 取该内容模块最近一次新增、修改、删除或恢复的时间
+// 按浏览量排序的热门列表不设置：排序随浏览量变化，修改时间不能代表列表内容
+func setListLastModified(w http.ResponseWriter, contentType string) {
+	t, err := services.ContentLastModified(contentType)
+	if err != nil {
+		log.Printf("failed to get last modified time of %s: %v", contentType, err)
+		return
+	}
+	setLastModified(w, t)
+}
+
+// setDetailValidators 设置详情响应的 Last-Modified（内容的 updated_at）和 ETag
+// 详情读取时浏览量加一，若按响应体计算 ETag，连续两次请求的内容总是不同，永远不会返回 304；
+// 因此 ETag 只由内容版本（updated_at）和当前用户的收藏信息计算，不含浏览量
+func setDetailValidators(w http.ResponseWriter, updatedAt time.Time, bookmarked bool, favoriteCount int) {
+	setLastModified(w, updatedAt)
+	sum := sha256.Sum256(fmt.Appendf(nil, "%d:%t:%d", updatedAt.UnixNano(), bookmarked, favoriteCount))
+	w.Header().Set("ETag", `W/"`+hex.EncodeToString(sum[:16])+`"`)
+}
+
+// bufferedResponse 缓存响应状态码和响应体，响应头直接写入下层
+type bufferedResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+// Unwrap 供 http.ResponseController 访问底层连接
+func (b *bufferedResponse) Unwrap() http.ResponseWriter {
+	return b.ResponseWriter
+}
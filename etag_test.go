@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"zxbe_demo/services"
+)
+
+// 详情读取时浏览量加一，内容未修改时第二次请求仍应返回 304
+func TestDetailConsecutiveGETsNotModified(t *testing.T) {
+	h := setupTestServer(t)
+	n := services.News{Title: "n", Category: "其他", PublisherID: "wx_author"}
+	if err := services.CreateNews(&n); err != nil {
+		t.Fatalf("CreateNews: %v", err)
+	}
+	target := "/api/news/" + strconv.Itoa(n.ID)
+
+	for _, user := range []string{"", "wx_reader"} {
+		first := doRequest(h, http.MethodGet, target, "X-Wechat-ID", user)
+		etag := first.Header().Get("ETag")
+		if first.Code != http.StatusOK || etag == "" || first.Header().Get("Last-Modified") == "" {
+			t.Fatalf("first GET (user %q) = %d, ETag %q, Last-Modified %q", user, first.Code, etag, first.Header().Get("Last-Modified"))
+		}
+		second := doRequest(h, http.MethodGet, target, "X-Wechat-ID", user, "If-None-Match", etag)
+		if second.Code != http.StatusNotModified || second.Body.Len() != 0 {
+			t.Errorf("second GET (user %q) = %d with %d body bytes, want 304 without body", user, second.Code, second.Body.Len())
+		}
+	}
+
+	// 内容修改后 ETag 改变
+	first := doRequest(h, http.MethodGet, target)
+	n.Title = "n2"
+	if err := services.UpdateNews(n.ID, &n); err != nil {
+		t.Fatalf("UpdateNews: %v", err)
+	}
+	if rec := doRequest(h, http.MethodGet, target, "If-None-Match", first.Header().Get("ETag")); rec.Code != http.StatusOK {
+		t.Errorf("GET after update = %d, want 200", rec.Code)
+	}
+}
+
+// 收藏状态不同的用户不能共用同一 ETag
+func TestDetailETagFollowsBookmark(t *testing.T) {
+	h := setupTestServer(t)
+	n := services.News{Title: "n", Category: "其他", PublisherID: "wx_author"}
+	if err := services.CreateNews(&n); err != nil {
+		t.Fatalf("CreateNews: %v", err)
+	}
+	services.DB.Create(&services.User{WechatID: "wx_reader"})
+	target := "/api/news/" + strconv.Itoa(n.ID)
+	before := doRequest(h, http.MethodGet, target, "X-Wechat-ID", "wx_reader").Header().Get("ETag")
+	if err := services.AddUserFavorite("wx_reader", "news", n.ID, "n", ""); err != nil {
+		t.Fatalf("AddUserFavorite: %v", err)
+	}
+	rec := doRequest(h, http.MethodGet, target, "X-Wechat-ID", "wx_reader", "If-None-Match", before)
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET after bookmarking = %d, want 200 with is_bookmarked updated", rec.Code)
+	}
+}
+
+func TestIfModifiedSince(t *testing.T) {
+	h := setupTestServer(t)
+	n := services.News{Title: "n", Category: "其他", PublisherID: "wx_author"}
+	if err := services.CreateNews(&n); err != nil {
+		t.Fatalf("CreateNews: %v", err)
+	}
+
+	for _, target := range []string{"/api/news/" + strconv.Itoa(n.ID), "/api/news"} {
+		first := doRequest(h, http.MethodGet, target)
+		lm := first.Header().Get("Last-Modified")
+		if lm == "" {
+			t.Fatalf("GET %s: no Last-Modified", target)
+		}
+		if rec := doRequest(h, http.MethodGet, target, "If-Modified-Since", lm); rec.Code != http.StatusNotModified {
+			t.Errorf("GET %s If-Modified-Since %s = %d, want 304", target, lm, rec.Code)
+		}
+		// 收藏状态随用户变化，带 X-Wechat-ID 时不按修改时间判断
+		if rec := doRequest(h, http.MethodGet, target, "If-Modified-Since", lm, "X-Wechat-ID", "wx_reader"); rec.Code != http.StatusOK {
+			t.Errorf("GET %s as user with If-Modified-Since = %d, want 200", target, rec.Code)
+		}
+		old := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+		if rec := doRequest(h, http.MethodGet, target, "If-Modified-Since", old); rec.Code != http.StatusOK {
+			t.Errorf("GET %s If-Modified-Since an hour ago = %d, want 200", target, rec.Code)
+		}
+	}
+
+	// 删除后列表的修改时间前进，旧的 If-Modified-Since 不再命中
+	lm := doRequest(h, http.MethodGet, "/api/news").Header().Get("Last-Modified")
+	time.Sleep(1100 * time.Millisecond) // Last-Modified 精确到秒
+	if err := services.DeleteNews(n.ID, "admin"); err != nil {
+		t.Fatalf("DeleteNews: %v", err)
+	}
+	if rec := doRequest(h, http.MethodGet, "/api/news", "If-Modified-Since", lm); rec.Code != http.StatusOK {
+		t.Errorf("list after delete = %d, want 200", rec.Code)
+	}
+}
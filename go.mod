@@ -3,6 +3,7 @@ module zxbe_demo
 go 1.24.0
 
 require (
+	github.com/andybalholm/brotli v1.2.0
 	gorm.io/driver/sqlite v1.5.4
 	gorm.io/gorm v1.25.5
 	modernc.org/sqlite v1.40.1
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"zxbe_demo/services"
+)
+
+// initHealth 应用就绪检查阈值
+func initHealth() {
+	services.HealthMaxWALBytes = int64(config.Health.MaxWALMB) << 20
+	services.HealthWarnFreeBytes = int64(config.Health.WarnFreeMB) << 20
+	services.HealthMinFreeBytes = int64(config.Health.MinFreeMB) << 20
+}
+
+// healthzHandler 存活探针：进程能处理请求即返回 200，不检查依赖，避免数据库故障时被反复重启
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeProbe(w, http.StatusOK, map[string]interface{}{
+		"status": "ok",
+		"uptime": time.Since(startTime).String(),
+	})
+}
+
+// readyzHandler 就绪探针：数据库、WAL、迁移版本和上传目录检查均通过时返回 200，否则 503
+// 管理员（X-Wechat-ID）或携带监控令牌的请求可看到各项检查的详细结果，其他请求只返回状态
+// 数据库不可用时无法识别管理员，此时只能通过监控令牌查看详情
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	res := services.CheckReadiness(r.Context())
+	status, code := "ready", http.StatusOK
+	if !res.Ready {
+		status, code = "not_ready", http.StatusServiceUnavailable
+		slog.WarnContext(r.Context(), "readiness check failed", "checks", res.Checks)
+	}
+
+	body := map[string]interface{}{"status": status}
+	if userID := r.Header.Get("X-Wechat-ID"); hasMetricsToken(r) || (userID != "" && isAdmin(userID)) {
+		body["uptime"] = time.Since(startTime).String()
+		body["schema_version"] = res.SchemaVersion
+		body["latest_schema_version"] = res.LatestSchemaVersion
+		body["checks"] = res.Checks
+	}
+	writeProbe(w, code, body)
+}
+
+// healthHandler 兼容旧的健康检查接口，结果与 /readyz 一致
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	res := services.CheckReadiness(r.Context())
+	if !res.Ready {
+		sendErrorData(w, r, errServiceUnavailable, map[string]interface{}{"status": "unhealthy", "uptime": time.Since(startTime).String()})
+		return
+	}
+	sendSuccess(w, map[string]interface{}{"status": "healthy", "uptime": time.Since(startTime).String()})
+}
+
+// writeProbe 输出探针结果，探针响应不应被缓存
+func writeProbe(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
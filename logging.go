@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+var slogLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// initLogger 按配置的级别和格式（text/json）初始化 slog，并将 log 包的输出转到 slog
+// 尚未改为结构化日志的 log.Printf 按配置的级别输出，不会被过滤
+func initLogger() {
+	level := slogLevels[config.Log.Level]
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+
+	var h slog.Handler
+	if config.Log.Format == "json" {
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	} else {
+		h = slog.NewTextHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(requestIDHandler{h}))
+	slog.SetLogLoggerLevel(max(level, slog.LevelInfo))
+}
+
+// 名称中包含这些词的日志字段一律打码
+var sensitiveKeys = []string{"password", "secret", "token", "authorization", "cookie"}
+
+// redactAttr 隐藏密码、密钥、令牌等敏感字段的值
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return slog.String(a.Key, "[REDACTED]")
+		}
+	}
+	return a
+}
+
+type requestIDKey struct{}
+
+// requestID 获取本次请求的请求ID
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestIDHandler 为带请求上下文的日志（slog.InfoContext 等）附加 request_id 字段
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := requestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// withRequestID 为每个请求分配请求ID（沿用反向代理传入的合法 X-Request-ID），写入上下文并在响应头中返回
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// statusRecorder 记录响应状态码和字节数
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap 供 http.ResponseController 访问底层连接
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// logRequests 每个请求完成后记录一条访问日志：方法、路由、状态码、耗时和请求者
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		// 路由匹配后 r.Pattern 为注册的模式（如 GET /api/news/{id}），未匹配时为空
+		slog.LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("route", r.Pattern),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int64("bytes", rec.bytes),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("principal", r.Header.Get("X-Wechat-ID")),
+			slog.String("ip", clientIP(r)),
+		)
+	})
+}
+
+// recoverPanics 错误恢复中间件 - 防止数据不匹配导致的崩溃，只记录错误和调用栈，不记录请求头
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				slog.ErrorContext(r.Context(), "panic recovered",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"panic", err,
+					"stack", string(debug.Stack()),
+				)
+
+				// 发送统一的错误响应，避免暴露内部错误
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(500)
+				json.NewEncoder(w).Encode(errorResponse(r, errInternal, nil))
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedactAttr(t *testing.T) {
+	for _, tc := range []struct {
+		key      string
+		redacted bool
+	}{
+		{"password", true},
+		{"admin_password", true},
+		{"AppSecret", true},
+		{"token", true},
+		{"metrics_token", true},
+		{"Authorization", true},
+		{"cookie", true},
+		{"Set-Cookie", true},
+		{"user_id", false},
+		{"path", false},
+		{"status", false},
+	} {
+		got := redactAttr(nil, slog.String(tc.key, "s3cr3t"))
+		if got.Key != tc.key {
+			t.Errorf("%s: key changed to %q", tc.key, got.Key)
+		}
+		if redacted := got.Value.String() == "[REDACTED]"; redacted != tc.redacted {
+			t.Errorf("%s: value = %q, redacted %v, want %v", tc.key, got.Value.String(), redacted, tc.redacted)
+		}
+	}
+}
+
+// 经 handler 输出时，分组内的敏感字段和非字符串类型的值同样打码
+func TestRedactAttrInHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{ReplaceAttr: redactAttr}))
+	logger.Info("login",
+		"user", "admin",
+		"password", "hunter2",
+		slog.Group("headers", "Authorization", "Bearer abc.def", "Accept", "application/json"),
+		"token_ttl", 3600,
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "Bearer abc.def", "3600"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("log output contains %q: %s", secret, out)
+		}
+	}
+	for _, kept := range []string{`"user":"admin"`, `"Accept":"application/json"`, `"msg":"login"`} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("log output missing %s: %s", kept, out)
+		}
+	}
+}
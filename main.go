@@ -1,17 +1,25 @@
 package main
 
 import (
-	"crypto/md5"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"zxbe_demo/services"
@@ -19,51 +27,14 @@ import (
 
 // 响应结构
 type Response struct {
-	Code    int         `json:"code"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
-}
-
-// 缓存结构
-type CacheItem struct {
-	Data      interface{}
-	ExpiresAt time.Time
-}
-
-type Cache struct {
-	items map[string]CacheItem
-}
-
-func NewCache() *Cache {
-	return &Cache{
-		items: make(map[string]CacheItem),
-	}
-}
-
-func (c *Cache) Set(key string, data interface{}, duration time.Duration) {
-	c.items[key] = CacheItem{
-		Data:      data,
-		ExpiresAt: time.Now().Add(duration),
-	}
-}
-
-func (c *Cache) Get(key string) (interface{}, bool) {
-	item, exists := c.items[key]
-	if !exists {
-		return nil, false
-	}
-
-	if time.Now().After(item.ExpiresAt) {
-		delete(c.items, key)
-		return nil, false
-	}
-
-	return item.Data, true
+	Code      int         `json:"code"`
+	Message   string      `json:"message"`
+	ErrorCode string      `json:"error_code,omitempty"` // 错误码，见 apierror.go
+	Data      interface{} `json:"data,omitempty"`
 }
 
 // 全局数据存储
 var (
-	cache     *Cache
 	startTime time.Time
 )
 
@@ -71,183 +42,224 @@ func main() {
 	// 记录启动时间
 	startTime = time.Now()
 
+	// 加载配置（配置文件、环境变量、命令行参数）
+	cfg, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("❌ 配置无效:\n%v", err)
+	}
+	config = cfg
+	initLogger()
+
 	// 初始化缓存
-	cache = NewCache()
+	services.Cache = services.NewCache(config.Cache.MaxEntries, time.Duration(config.Cache.TTLSeconds)*time.Second)
+
+	// 每个用户保留的浏览记录条数
+	services.HistoryLimit = config.Content.HistoryLimit
+
+	// 初始化上传文件存储（本地目录的旧文件由数据迁移建立哈希索引）和对外访问地址
+	initStorage()
+	initUploadQuotas()
+	initPublicBaseURL()
+	initTokenSecret()
+	initHealth()
+	initValidation()
 
 	// 初始化 SQLite DB
-	if err := services.InitDB(); err != nil {
+	if err := services.InitDB(config.Database.DSN, config.Log.Level); err != nil {
 		log.Fatalf("failed to init db: %v", err)
 	}
 
+	// 启动订阅消息发件箱、回收站清理、孤立文件清理和过期分片上传清理任务
+	stop := make(chan struct{})
+	initNotify(stop)
+	initRecycleBin(stop)
+	initUploadGC(stop)
+	initChunkedUpload(stop)
+
 	// 创建默认管理员账号
-	if err := services.CreateDefaultAdmin(); err != nil {
+	if err := services.CreateDefaultAdmin(config.Auth.AdminPassword); err != nil {
 		log.Printf("❌ 创建默认管理员失败: %v", err)
 	} else {
-		log.Println("✅ 默认管理员账号已就绪 (admin/123456)")
-	}
-
-	// 设置路由（使用数据库驱动的 handler）- 添加错误恢复中间件
-	http.HandleFunc("/api/news", corsHandler(recoverHandler(newsHandler)))
-	http.HandleFunc("/api/news/", corsHandler(recoverHandler(newsDetailHandler)))
-	http.HandleFunc("/api/news/latest", corsHandler(recoverHandler(latestNewsHandler)))
-	http.HandleFunc("/api/farmhouse", corsHandler(recoverHandler(farmhouseHandler)))
-	http.HandleFunc("/api/farmhouse/", corsHandler(recoverHandler(farmhouseDetailHandler)))
-	http.HandleFunc("/api/policy", corsHandler(recoverHandler(policyHandler)))
-	http.HandleFunc("/api/policy/", corsHandler(recoverHandler(policyDetailHandler)))
-	http.HandleFunc("/api/tourism", corsHandler(recoverHandler(tourismHandler)))
-	http.HandleFunc("/api/tourism/", corsHandler(recoverHandler(tourismDetailHandler)))
-	http.HandleFunc("/api/jobs", corsHandler(recoverHandler(jobsHandler)))
-	http.HandleFunc("/api/jobs/", corsHandler(recoverHandler(jobsDetailHandler)))
-	// 权限检查API - 添加错误恢复中间件
-	http.HandleFunc("/api/permission/check", corsHandler(recoverHandler(checkPermissionHandler)))
-	http.HandleFunc("/api/help", corsHandler(recoverHandler(helpHandler)))
-	http.HandleFunc("/api/help/", corsHandler(recoverHandler(helpDetailHandler)))
-	http.HandleFunc("/api/consultation", corsHandler(recoverHandler(consultationHandler)))
-	http.HandleFunc("/api/consultation/", corsHandler(recoverHandler(consultationDetailHandler)))
-	http.HandleFunc("/api/user/profile", corsHandler(recoverHandler(userHandler)))
-	http.HandleFunc("/api/user/login", corsHandler(recoverHandler(loginHandler)))
-	http.HandleFunc("/api/user/register", corsHandler(recoverHandler(registerHandler)))
-	http.HandleFunc("/api/admin/login", corsHandler(recoverHandler(adminLoginHandler)))
-	http.HandleFunc("/api/admin/grant-role", corsHandler(recoverHandler(adminGrantRoleHandler)))
-	http.HandleFunc("/api/user/wechat-login", corsHandler(recoverHandler(wechatLoginHandler)))
-	http.HandleFunc("/api/user/list", corsHandler(recoverHandler(userListHandler)))
-	http.HandleFunc("/api/user/role", corsHandler(recoverHandler(updateRoleHandler)))
-	http.HandleFunc("/api/user/favorite", corsHandler(recoverHandler(favoriteHandler)))
-	http.HandleFunc("/api/user/avatar", corsHandler(recoverHandler(updateAvatarHandler)))
-	http.HandleFunc("/api/user/nickname", corsHandler(recoverHandler(updateNicknameHandler)))
-	http.HandleFunc("/api/my-publish/", corsHandler(recoverHandler(myPublishHandler)))
-	http.HandleFunc("/api/upload", corsHandler(recoverHandler(uploadHandler)))
-	http.HandleFunc("/api/health", corsHandler(recoverHandler(healthHandler)))
-	http.HandleFunc("/api/user/history", corsHandler(recoverHandler(historyHandler)))
-	http.HandleFunc("/api/feedback", corsHandler(recoverHandler(feedbackHandler)))
-	http.HandleFunc("/api/admin/feedback", corsHandler(recoverHandler(adminFeedbackHandler)))
-	http.HandleFunc("/api/admin/feedback/", corsHandler(recoverHandler(adminFeedbackDetailHandler)))
-	http.HandleFunc("/api/settings/banners", corsHandler(recoverHandler(bannersHandler)))
-
-	// 静态文件服务 - 提供上传文件的访问（需要CORS支持）
-	fileServer := http.FileServer(http.Dir("./uploads/"))
-	http.HandleFunc("/uploads/", func(w http.ResponseWriter, r *http.Request) {
-		// 添加CORS头
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-		if r.Method == "OPTIONS" {
-			return
+		log.Println("✅ 默认管理员账号已就绪 (admin)")
+		if config.Auth.AdminPassword == "123456" {
+			log.Println("⚠️  默认管理员使用默认密码，生产环境请通过 ADMIN_DEFAULT_PASSWORD 配置")
 		}
+	}
 
-		// 移除/uploads/前缀并提供文件
-		http.StripPrefix("/uploads/", fileServer).ServeHTTP(w, r)
-	})
+	srv := newHTTPServer(newRouter())
+	go func() {
+		log.Printf("Server starting on %s", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("server failed: %v", err)
+		}
+	}()
 
-	// 创建上传目录
-	os.MkdirAll("./uploads", 0755)
+	// 收到 SIGINT/SIGTERM 后停止接收新请求，等待处理中的请求完成，再停止后台任务并关闭数据库
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	cancel() // 再次收到信号时按默认行为立即退出
+	log.Println("🛑 收到退出信号，正在停止服务...")
 
-	log.Println("Server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), time.Duration(config.Server.ShutdownTimeoutSeconds)*time.Second)
+	defer cancelShutdown()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️ 等待请求处理完成超时: %v", err)
+	}
+	close(stop)
+	if err := services.Close(shutdownCtx); err != nil {
+		log.Printf("❌ 关闭数据库失败: %v", err)
+	}
+	log.Println("👋 服务已停止")
 }
 
-// CORS处理
-func corsHandler(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// newHTTPServer 按配置创建 HTTP 服务，设置各阶段超时，防止慢客户端长期占用连接
+func newHTTPServer(handler http.Handler) *http.Server {
+	c := config.Server
+	return &http.Server{
+		Addr:              c.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: time.Duration(c.ReadHeaderTimeoutSeconds) * time.Second,
+		ReadTimeout:       time.Duration(c.ReadTimeoutSeconds) * time.Second,
+		WriteTimeout:      time.Duration(c.WriteTimeoutSeconds) * time.Second,
+		IdleTimeout:       time.Duration(c.IdleTimeoutSeconds) * time.Second,
+		MaxHeaderBytes:    c.MaxHeaderBytes,
+	}
+}
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+// 上传和下载文件的请求体、响应体较大，弱网下需要比普通接口更长的读写时间
+const transferTimeout = 10 * time.Minute
 
-		// 添加请求日志和性能监控
-		start := time.Now()
-		log.Printf("%s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+// extendReadDeadline 放宽本次请求读取请求体的超时
+func extendReadDeadline(w http.ResponseWriter) {
+	if err := http.NewResponseController(w).SetReadDeadline(time.Now().Add(transferTimeout)); err != nil {
+		log.Printf("⚠️ 设置读取超时失败: %v", err)
+	}
+}
 
-		next(w, r)
+// extendWriteDeadline 放宽本次请求写入响应的超时
+func extendWriteDeadline(w http.ResponseWriter) {
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(transferTimeout)); err != nil {
+		log.Printf("⚠️ 设置写入超时失败: %v", err)
+	}
+}
 
-		// 记录请求处理时间
-		duration := time.Since(start)
-		log.Printf("Request %s %s completed in %v", r.Method, r.URL.Path, duration)
+// setCORSOrigin 按配置的 CORS 来源设置 Access-Control-Allow-Origin，来源不在列表中时不设置
+func setCORSOrigin(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	for _, allowed := range config.Server.CORSOrigins {
+		if allowed == "*" {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			return
+		}
+		if origin != "" && strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+			return
+		}
 	}
 }
 
 // 响应工具函数
+// 数据库中的上传文件地址为相对路径，在这里统一展开为本次请求的对外访问地址
 func sendResponse(w http.ResponseWriter, code int, message string, data interface{}) {
+	writeResponse(w, Response{Code: code, Message: message, Data: data})
+}
+
+// writeResponse 写出响应，HTTP 状态码与响应体中的 code 一致，开启 server.legacy_status_codes 时始终为 200
+func writeResponse(w http.ResponseWriter, response Response) {
 	w.Header().Set("Content-Type", "application/json")
-	response := Response{
-		Code:    code,
-		Message: message,
-		Data:    data,
+	if response.Code != http.StatusOK && !config.Server.LegacyStatusCodes {
+		w.WriteHeader(response.Code)
+	}
+	pw, ok := w.(*publicURLWriter)
+	if !ok {
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(response); err != nil {
+		log.Printf("❌ 响应编码失败: %v", err)
+		return
 	}
-	json.NewEncoder(w).Encode(response)
+	io.WriteString(w, services.ExpandUploadURLs(buf.String(), pw.baseURL))
 }
 
 func sendSuccess(w http.ResponseWriter, data interface{}) {
 	sendResponse(w, 200, "success", data)
 }
 
-func sendError(w http.ResponseWriter, code int, message string) {
-	sendResponse(w, code, message, nil)
-}
-
-// 检查删除权限：作者本人或管理员可删除
+// 检查删除权限：作者本人或管理员可删除，判断过程记录为 debug 日志
 func checkDeletePermission(userID, publisherID string) bool {
-	log.Printf("🔐 检查删除权限 - userID: %s, publisherID: %s", userID, publisherID)
+	allowed, reason := deletePermission(userID, publisherID)
+	slog.Debug("delete permission checked", "user_id", userID, "publisher_id", publisherID, "allowed", allowed, "reason", reason)
+	return allowed
+}
 
+func deletePermission(userID, publisherID string) (bool, string) {
 	if userID == "" {
-		log.Printf("❌ userID为空")
-		return false
+		return false, "empty user id"
 	}
 
 	// 检查是否为管理员账号
 	if strings.HasPrefix(userID, "admin_") {
-		log.Printf("👨‍💼 检测到管理员账号")
 		username := strings.TrimPrefix(userID, "admin_")
 		admin, err := services.GetAdminByUsername(username)
 		if err == nil && (admin.Role == "super_admin" || admin.Role == "admin") {
-			log.Printf("✅ 管理员权限通过 - Role: %s", admin.Role)
-			return true
+			return true, "admin account: " + admin.Role
 		}
 		// 检查是否为管理员发布的内容（publisherID可能是username）
 		if publisherID == username || publisherID == userID {
-			log.Printf("✅ 管理员本人发布的内容")
-			return true
+			return true, "own content"
 		}
-		log.Printf("❌ 管理员账号但无权限")
-	} else {
-		// 检查是否为微信用户
-		log.Printf("👤 检测到微信用户")
+		return false, "admin account without permission"
+	}
 
-		// 先检查是否为作者本人（即使用户不在数据库中）
-		if publisherID == userID {
-			log.Printf("✅ 用户本人发布的内容（ID匹配）")
-			return true
-		}
+	// 先检查是否为作者本人（即使用户不在数据库中）
+	if publisherID == userID {
+		return true, "own content"
+	}
 
-		// 再查询数据库检查用户角色
-		user, err := services.GetUserByWechatID(userID)
-		if err == nil {
-			log.Printf("📋 用户角色: %s", user.Role)
-			if user.Role == "super_admin" || user.Role == "admin" {
-				log.Printf("✅ 微信管理员权限通过")
-				return true
-			}
-		} else {
-			log.Printf("⚠️  用户不在数据库中: %v", err)
-		}
+	// 再查询数据库检查用户角色
+	user, err := services.GetUserByWechatID(userID)
+	if err != nil {
+		return false, "user not found"
 	}
+	if user.Role == "super_admin" || user.Role == "admin" {
+		return true, "wechat user role: " + user.Role
+	}
+	return false, "not publisher"
+}
 
-	log.Printf("❌ 无删除权限")
-	return false
+// 检查是否为管理员（管理员账号或拥有admin角色的微信用户）
+func isAdmin(userID string) bool {
+	role := getOperatorRole(userID)
+	return role == "super_admin" || role == "admin"
 }
 
-// 权限检查API处理函数
-func checkPermissionHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		sendError(w, 405, "Method not allowed")
-		return
+// 获取操作者角色：支持管理员账号(admin_xxx)和微信用户
+func getOperatorRole(userID string) string {
+	if userID == "" {
+		return ""
+	}
+	if strings.HasPrefix(userID, "admin_") {
+		admin, err := services.GetAdminByUsername(strings.TrimPrefix(userID, "admin_"))
+		if err != nil {
+			return ""
+		}
+		return admin.Role
+	}
+	user, err := services.GetUserByWechatID(userID)
+	if err != nil {
+		return ""
 	}
+	return user.Role
+}
 
+// 权限检查API处理函数
+func checkPermissionHandler(w http.ResponseWriter, r *http.Request) {
 	// 解析请求体
 	var req struct {
 		UserID      string `json:"user_id"`      // 当前用户ID (wechat_id 或 admin_xxx)
@@ -256,16 +268,13 @@ func checkPermissionHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("❌ 权限检查 - 解析请求失败: %v", err)
-		sendError(w, 400, "Invalid request body")
+		slog.DebugContext(r.Context(), "permission check: invalid body", "error", err)
+		sendError(w, r, errInvalidRequestBody)
 		return
 	}
 
-	log.Printf("🔍 权限检查请求 - UserID: %s, ContentType: %s, ContentID: %d", req.UserID, req.ContentType, req.ContentID)
-
 	if req.UserID == "" || req.ContentType == "" || req.ContentID == 0 {
-		log.Printf("❌ 权限检查 - 缺少必要字段")
-		sendError(w, 400, "Missing required fields")
+		sendErrorData(w, r, errMissingFields, missingFieldsData("user_id", "content_type", "content_id"))
 		return
 	}
 
@@ -277,59 +286,58 @@ func checkPermissionHandler(w http.ResponseWriter, r *http.Request) {
 	case "policy":
 		policy, err := services.PolicyGetByID(req.ContentID)
 		if err != nil {
-			sendError(w, 404, "Content not found")
+			sendError(w, r, errContentNotFound)
 			return
 		}
 		publisherID = policy.PublisherID
 	case "tourism":
 		tourism, err := services.TourismGetByID(req.ContentID)
 		if err != nil {
-			sendError(w, 404, "Content not found")
+			sendError(w, r, errContentNotFound)
 			return
 		}
 		publisherID = tourism.PublisherID
 	case "job":
 		job, err := services.JobsGetByID(req.ContentID)
 		if err != nil {
-			sendError(w, 404, "Content not found")
+			sendError(w, r, errContentNotFound)
 			return
 		}
 		publisherID = job.PublisherID
 	case "help":
 		help, err := services.HelpGetByID(req.ContentID)
 		if err != nil {
-			sendError(w, 404, "Content not found")
+			sendError(w, r, errContentNotFound)
 			return
 		}
 		publisherID = help.PublisherID
 	case "consultation":
 		consultation, err := services.ConsultationGetByID(req.ContentID)
 		if err != nil {
-			sendError(w, 404, "Content not found")
+			sendError(w, r, errContentNotFound)
 			return
 		}
 		publisherID = consultation.AuthorID
 	case "farmhouse":
 		farmhouse, err := services.FarmhouseGetByID(req.ContentID)
 		if err != nil {
-			sendError(w, 404, "Content not found")
+			sendError(w, r, errContentNotFound)
 			return
 		}
 		publisherID = farmhouse.PublisherID
 	default:
-		sendError(w, 400, "Invalid content type")
+		sendErrorData(w, r, errInvalidParameter, fieldError("content_type"))
 		return
 	}
 
 	if err != nil {
-		sendError(w, 500, "Failed to check permission")
+		sendError(w, r, errInternal)
 		return
 	}
 
 	// 检查权限
-	log.Printf("📌 发布者ID: %s, 当前用户ID: %s", publisherID, req.UserID)
 	canDelete := checkDeletePermission(req.UserID, publisherID)
-	log.Printf("✅ 权限检查结果: %v", canDelete)
+	slog.DebugContext(r.Context(), "permission check", "content_type", req.ContentType, "content_id", req.ContentID, "can_delete", canDelete)
 
 	sendSuccess(w, map[string]interface{}{
 		"can_delete":   canDelete,
@@ -338,640 +346,670 @@ func checkPermissionHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// 数据验证函数
-func validateRequired(fields map[string]string) error {
-	for field, value := range fields {
-		if strings.TrimSpace(value) == "" {
-			return fmt.Errorf("%s不能为空", field)
-		}
+// 详情读取时为已登录用户记录浏览历史（标题和图片取自记录本身）
+func recordView(r *http.Request, itemType string, itemID int, title string, images ...string) {
+	wechatID := r.Header.Get("X-Wechat-ID")
+	if wechatID == "" || strings.HasPrefix(wechatID, "admin_") {
+		return
 	}
-	return nil
-}
-
-// 错误恢复中间件 - 增强版，防止数据不匹配导致的崩溃
-func recoverHandler(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				log.Printf("❌ Panic recovered in %s %s: %v", r.Method, r.URL.Path, err)
-				log.Printf("❌ Request headers: %+v", r.Header)
-				log.Printf("❌ Request from: %s", r.RemoteAddr)
-
-				// 发送统一的错误响应，避免暴露内部错误
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(500)
-				response := map[string]interface{}{
-					"code":    500,
-					"message": "服务器内部错误，请稍后重试",
-					"data":    nil,
-				}
-				json.NewEncoder(w).Encode(response)
-			}
-		}()
-		next(w, r)
+	if err := services.AddUserHistory(wechatID, itemType, itemID, title, services.FirstImage(images...)); err != nil {
+		log.Printf("failed to record history: %v", err)
 	}
 }
 
 // 资讯处理函数（使用 MongoDB）
-func newsHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case "GET":
-		keyword := r.URL.Query().Get("keyword")
-		category := r.URL.Query().Get("category")
-
-		list, err := services.NewsList(keyword, category)
-		if err != nil {
-			sendError(w, 500, "数据库查询错误")
-			return
-		}
-		sendSuccess(w, map[string]interface{}{"list": list, "total": len(list), "page": 1, "page_size": len(list)})
-	case "POST":
-		var n services.News
-		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
-			log.Printf("❌ 资讯创建失败 - JSON解析错误: %v", err)
-			sendError(w, 400, "请求数据格式错误")
-			return
-		}
-
-		// 数据验证，防止空值导致的问题
-		if err := validateRequired(map[string]string{
-			"标题":    n.Title,
-			"分类":    n.Category,
-			"发布者ID": n.PublisherID,
-		}); err != nil {
-			log.Printf("❌ 资讯创建失败 - 数据验证错误: %v", err)
-			sendError(w, 400, err.Error())
-			return
-		}
+func newsListHandler(w http.ResponseWriter, r *http.Request) {
+	keyword := r.URL.Query().Get("keyword")
+	category := r.URL.Query().Get("category")
 
-		log.Printf("📝 创建资讯 - 标题: %s, 分类: %s, 发布者: %s", n.Title, n.Category, n.PublisherID)
-		if err := services.CreateNews(&n); err != nil {
-			log.Printf("❌ 资讯创建失败 - 数据库错误: %v", err)
-			sendError(w, 500, "数据库写入失败")
-			return
-		}
-		log.Printf("✅ 资讯创建成功 - ID: %d", n.ID)
-		sendSuccess(w, n)
-	default:
-		sendError(w, 405, "Method not allowed")
+	list, err := services.NewsList(keyword, category)
+	if err != nil {
+		sendError(w, r, errInternal)
+		return
+	}
+	if err := services.AnnotateFavorites(r.Header.Get("X-Wechat-ID"), list); err != nil {
+		log.Printf("failed to annotate favorites: %v", err)
 	}
+	setListLastModified(w, "news")
+	services.AnnotateThumbnails(list)
+	sendSuccess(w, map[string]interface{}{"list": list, "total": len(list), "page": 1, "page_size": len(list)})
 }
 
-func newsDetailHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
-		sendError(w, 405, "Method not allowed")
+func newsCreateHandler(w http.ResponseWriter, r *http.Request) {
+	var n services.News
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		log.Printf("❌ 资讯创建失败 - JSON解析错误: %v", err)
+		sendError(w, r, errInvalidRequestBody)
 		return
 	}
 
-	path := strings.TrimPrefix(r.URL.Path, "/api/news/")
-	id, err := strconv.Atoi(path)
-	if err != nil {
-		sendError(w, 400, "Invalid ID")
+	log.Printf("📝 创建资讯 - 标题: %s, 分类: %s, 发布者: %s", n.Title, n.Category, n.PublisherID)
+	if err := services.CreateNews(&n); err != nil {
+		sendWriteError(w, r, err)
+		return
+	}
+	log.Printf("✅ 资讯创建成功 - ID: %d", n.ID)
+	sendSuccess(w, n)
+}
+
+func newsDetailHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
 		return
 	}
 	item, err := services.NewsGetByID(id)
 	if err != nil {
-		sendError(w, 404, "News not found")
+		sendError(w, r, errContentNotFound)
 		return
 	}
+	if err := services.AnnotateFavorite(r.Header.Get("X-Wechat-ID"), item); err != nil {
+		log.Printf("failed to annotate favorites: %v", err)
+	}
 	if err := services.IncrementNewsView(id); err != nil {
 		log.Printf("failed to increment view: %v", err)
 	}
+	recordView(r, "news", item.ID, item.Title, item.Image)
+	setDetailValidators(w, item.UpdatedAt, item.IsBookmarked, item.FavoriteCount)
 	sendSuccess(w, item)
 }
 
+// 首页最新、热门列表的默认和最大条数
+const (
+	defaultListCount = 4
+	maxListCount     = 50
+)
+
+// listCount 解析 count 参数，无效时使用默认值，超出范围时截断
+func listCount(r *http.Request) int {
+	v, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || v <= 0 {
+		return defaultListCount
+	}
+	return min(v, maxListCount)
+}
+
 func latestNewsHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
-		sendError(w, 405, "Method not allowed")
+	list, err := services.LatestNews(listCount(r))
+	if err != nil {
+		sendError(w, r, errInternal)
 		return
 	}
-	count := 4
-	if c := r.URL.Query().Get("count"); c != "" {
-		if v, err := strconv.Atoi(c); err == nil {
-			count = v
-		}
+	if err := services.AnnotateFavorites(r.Header.Get("X-Wechat-ID"), list); err != nil {
+		log.Printf("failed to annotate favorites: %v", err)
 	}
-	list, err := services.NewsList("", "")
+	setListLastModified(w, "news")
+	services.AnnotateThumbnails(list)
+	sendSuccess(w, list)
+}
+
+func hotNewsHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := services.HotNews(listCount(r))
 	if err != nil {
-		sendError(w, 500, "数据库查询错误")
+		sendError(w, r, errInternal)
 		return
 	}
-	if len(list) < count {
-		count = len(list)
+	if err := services.AnnotateFavorites(r.Header.Get("X-Wechat-ID"), list); err != nil {
+		log.Printf("failed to annotate favorites: %v", err)
 	}
-	sendSuccess(w, list[:count])
+	services.AnnotateThumbnails(list)
+	sendSuccess(w, list)
 }
 
 // 农家乐处理函数（使用 MongoDB）
-func farmhouseHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case "GET":
-		keyword := r.URL.Query().Get("keyword")
-		list, err := services.FarmhouseList(keyword)
-		if err != nil {
-			sendError(w, 500, "数据库查询错误")
-			return
-		}
-		sendSuccess(w, map[string]interface{}{"list": list, "total": len(list), "page": 1, "page_size": len(list)})
-	case "POST":
-		var f services.Farmhouse
-		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
-			sendError(w, 400, "Invalid JSON")
-			return
-		}
-		if err := services.FarmhouseCreate(&f); err != nil {
-			sendError(w, 500, "数据库写入失败")
-			return
-		}
-		sendSuccess(w, f)
-	default:
-		sendError(w, 405, "Method not allowed")
+func farmhouseListHandler(w http.ResponseWriter, r *http.Request) {
+	keyword := r.URL.Query().Get("keyword")
+	list, err := services.FarmhouseList(keyword)
+	if err != nil {
+		sendError(w, r, errInternal)
+		return
+	}
+	if err := services.AnnotateFavorites(r.Header.Get("X-Wechat-ID"), list); err != nil {
+		log.Printf("failed to annotate favorites: %v", err)
+	}
+	setListLastModified(w, "farmhouse")
+	services.AnnotateThumbnails(list)
+	sendSuccess(w, map[string]interface{}{"list": list, "total": len(list), "page": 1, "page_size": len(list)})
+}
+
+func farmhouseCreateHandler(w http.ResponseWriter, r *http.Request) {
+	var f services.Farmhouse
+	if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+		sendError(w, r, errInvalidRequestBody)
+		return
+	}
+	if err := services.FarmhouseCreate(&f); err != nil {
+		sendWriteError(w, r, err)
+		return
 	}
+	sendSuccess(w, f)
 }
 
 func farmhouseDetailHandler(w http.ResponseWriter, r *http.Request) {
-	path := strings.TrimPrefix(r.URL.Path, "/api/farmhouse/")
-	id, err := strconv.Atoi(path)
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	item, err := services.FarmhouseGetByID(id)
 	if err != nil {
-		sendError(w, 400, "Invalid ID")
+		sendError(w, r, errContentNotFound)
 		return
 	}
-	switch r.Method {
-	case "GET":
-		item, err := services.FarmhouseGetByID(id)
-		if err != nil {
-			sendError(w, 404, "Farmhouse not found")
-			return
-		}
-		sendSuccess(w, item)
-	case "PUT":
-		var f services.Farmhouse
-		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
-			sendError(w, 400, "Invalid JSON")
-			return
-		}
-		if err := services.FarmhouseUpdate(id, &f); err != nil {
-			sendError(w, 500, "更新失败")
-			return
-		}
-		sendSuccess(w, f)
-	case "DELETE":
-		userID := r.Header.Get("X-Wechat-ID")
-		if userID == "" {
-			sendError(w, 401, "请先登录")
-			return
-		}
+	recordView(r, "farmhouse", item.ID, item.Title, item.Image, item.Images)
+	if err := services.AnnotateFavorite(r.Header.Get("X-Wechat-ID"), item); err != nil {
+		log.Printf("failed to annotate favorites: %v", err)
+	}
+	setDetailValidators(w, item.UpdatedAt, item.IsBookmarked, item.FavoriteCount)
+	sendSuccess(w, item)
+}
 
-		farmhouse, err := services.FarmhouseGetByID(id)
-		if err != nil {
-			sendError(w, 404, "农家乐不存在")
-			return
-		}
+func farmhouseUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var f services.Farmhouse
+	if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+		sendError(w, r, errInvalidRequestBody)
+		return
+	}
+	if err := services.FarmhouseUpdate(id, &f); err != nil {
+		sendWriteError(w, r, err)
+		return
+	}
+	sendSuccess(w, f)
+}
 
-		if !checkDeletePermission(userID, farmhouse.PublisherID) {
-			sendError(w, 403, "无权删除此内容")
-			return
-		}
+func farmhouseDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	userID := r.Header.Get("X-Wechat-ID")
 
-		if err := services.FarmhouseDelete(id); err != nil {
-			sendError(w, 500, "删除失败")
-			return
-		}
-		sendSuccess(w, map[string]interface{}{"message": "删除成功"})
-	default:
-		sendError(w, 405, "Method not allowed")
+	farmhouse, err := services.FarmhouseGetByID(id)
+	if err != nil {
+		sendError(w, r, errContentNotFound)
+		return
+	}
+
+	if !checkDeletePermission(userID, farmhouse.PublisherID) {
+		sendError(w, r, errPermissionDenied)
+		return
 	}
+
+	if err := services.FarmhouseDelete(id, userID); err != nil {
+		sendError(w, r, errInternal)
+		return
+	}
+	recordAudit(r, userID, services.AuditContentDelete, "farmhouse", id, farmhouse, nil)
+	sendSuccess(w, map[string]interface{}{"message": "删除成功"})
 }
 
 // 政策处理函数
-func policyHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case "GET":
-		keyword := r.URL.Query().Get("keyword")
-		category := r.URL.Query().Get("category")
-		list, err := services.PolicyList(keyword, category)
-		if err != nil {
-			sendError(w, 500, "数据库查询错误")
-			return
-		}
-		sendSuccess(w, map[string]interface{}{"list": list, "total": len(list), "page": 1, "page_size": len(list)})
-	case "POST":
-		var p services.Policy
-		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-			sendError(w, 400, "Invalid JSON")
-			return
-		}
-		log.Printf("📝 创建政策 - PublisherID: %s, Title: %s", p.PublisherID, p.Title)
-		if err := services.PolicyCreate(&p); err != nil {
-			sendError(w, 500, "数据库写入失败")
-			return
-		}
-		log.Printf("✅ 政策创建成功 - ID: %d, PublisherID: %s", p.ID, p.PublisherID)
-		sendSuccess(w, p)
-	default:
-		sendError(w, 405, "Method not allowed")
+func policyListHandler(w http.ResponseWriter, r *http.Request) {
+	keyword := r.URL.Query().Get("keyword")
+	category := r.URL.Query().Get("category")
+	list, err := services.PolicyList(keyword, category)
+	if err != nil {
+		sendError(w, r, errInternal)
+		return
+	}
+	if err := services.AnnotateFavorites(r.Header.Get("X-Wechat-ID"), list); err != nil {
+		log.Printf("failed to annotate favorites: %v", err)
+	}
+	setListLastModified(w, "policy")
+	services.AnnotateThumbnails(list)
+	sendSuccess(w, map[string]interface{}{"list": list, "total": len(list), "page": 1, "page_size": len(list)})
+}
+
+func policyCreateHandler(w http.ResponseWriter, r *http.Request) {
+	var p services.Policy
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		sendError(w, r, errInvalidRequestBody)
+		return
 	}
+	log.Printf("📝 创建政策 - PublisherID: %s, Title: %s", p.PublisherID, p.Title)
+	if err := services.PolicyCreate(&p); err != nil {
+		sendWriteError(w, r, err)
+		return
+	}
+	log.Printf("✅ 政策创建成功 - ID: %d, PublisherID: %s", p.ID, p.PublisherID)
+	sendSuccess(w, p)
 }
 
 func policyDetailHandler(w http.ResponseWriter, r *http.Request) {
-	path := strings.TrimPrefix(r.URL.Path, "/api/policy/")
-	id, err := strconv.Atoi(path)
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	item, err := services.PolicyGetByID(id)
 	if err != nil {
-		sendError(w, 400, "Invalid ID")
+		sendError(w, r, errContentNotFound)
 		return
 	}
+	if err := services.AnnotateFavorite(r.Header.Get("X-Wechat-ID"), item); err != nil {
+		log.Printf("failed to annotate favorites: %v", err)
+	}
+	_ = services.IncrementPolicyRead(id)
+	recordView(r, "policy", item.ID, item.Title, item.Image, item.Images)
+	setDetailValidators(w, item.UpdatedAt, item.IsBookmarked, item.FavoriteCount)
+	sendSuccess(w, item)
+}
 
-	switch r.Method {
-	case "GET":
-		item, err := services.PolicyGetByID(id)
-		if err != nil {
-			sendError(w, 404, "Policy not found")
-			return
-		}
-		_ = services.IncrementPolicyRead(id)
-		sendSuccess(w, item)
-
-	case "DELETE":
-		userID := r.Header.Get("X-Wechat-ID")
-		if userID == "" {
-			sendError(w, 401, "请先登录")
-			return
-		}
-
-		policy, err := services.PolicyGetByID(id)
-		if err != nil {
-			sendError(w, 404, "政策不存在")
-			return
-		}
+func policyDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	userID := r.Header.Get("X-Wechat-ID")
 
-		if !checkDeletePermission(userID, policy.PublisherID) {
-			sendError(w, 403, "无权删除此内容")
-			return
-		}
+	policy, err := services.PolicyGetByID(id)
+	if err != nil {
+		sendError(w, r, errContentNotFound)
+		return
+	}
 
-		if err := services.PolicyDelete(id); err != nil {
-			sendError(w, 500, "删除失败")
-			return
-		}
-		sendSuccess(w, map[string]interface{}{"message": "删除成功"})
+	if !checkDeletePermission(userID, policy.PublisherID) {
+		sendError(w, r, errPermissionDenied)
+		return
+	}
 
-	default:
-		sendError(w, 405, "Method not allowed")
+	if err := services.PolicyDelete(id, userID); err != nil {
+		sendError(w, r, errInternal)
+		return
 	}
+	recordAudit(r, userID, services.AuditContentDelete, "policy", id, policy, nil)
+	sendSuccess(w, map[string]interface{}{"message": "删除成功"})
 }
 
 // 旅游处理函数
-func tourismHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case "GET":
-		keyword := r.URL.Query().Get("keyword")
-		category := r.URL.Query().Get("category")
-		list, err := services.TourismList(keyword, category)
-		if err != nil {
-			sendError(w, 500, "数据库查询错误")
-			return
-		}
-		sendSuccess(w, map[string]interface{}{"list": list, "total": len(list), "page": 1, "page_size": len(list)})
-	case "POST":
-		var t services.Tourism
-		if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
-			sendError(w, 400, "Invalid JSON")
-			return
-		}
-		if err := services.TourismCreate(&t); err != nil {
-			sendError(w, 500, "数据库写入失败")
-			return
-		}
-		sendSuccess(w, t)
-	default:
-		sendError(w, 405, "Method not allowed")
+func tourismListHandler(w http.ResponseWriter, r *http.Request) {
+	keyword := r.URL.Query().Get("keyword")
+	category := r.URL.Query().Get("category")
+	list, err := services.TourismList(keyword, category)
+	if err != nil {
+		sendError(w, r, errInternal)
+		return
 	}
+	if err := services.AnnotateFavorites(r.Header.Get("X-Wechat-ID"), list); err != nil {
+		log.Printf("failed to annotate favorites: %v", err)
+	}
+	setListLastModified(w, "tourism")
+	services.AnnotateThumbnails(list)
+	sendSuccess(w, map[string]interface{}{"list": list, "total": len(list), "page": 1, "page_size": len(list)})
 }
 
-func tourismDetailHandler(w http.ResponseWriter, r *http.Request) {
-	path := strings.TrimPrefix(r.URL.Path, "/api/tourism/")
-	id, err := strconv.Atoi(path)
+func hotTourismHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := services.HotTourism(listCount(r))
 	if err != nil {
-		sendError(w, 400, "Invalid ID")
+		sendError(w, r, errInternal)
 		return
 	}
+	if err := services.AnnotateFavorites(r.Header.Get("X-Wechat-ID"), list); err != nil {
+		log.Printf("failed to annotate favorites: %v", err)
+	}
+	services.AnnotateThumbnails(list)
+	sendSuccess(w, list)
+}
 
-	switch r.Method {
-	case "GET":
-		item, err := services.TourismGetByID(id)
-		if err != nil {
-			sendError(w, 404, "Tourism not found")
-			return
-		}
-		_ = services.IncrementTourismView(id)
-		sendSuccess(w, item)
+func tourismCreateHandler(w http.ResponseWriter, r *http.Request) {
+	var t services.Tourism
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		sendError(w, r, errInvalidRequestBody)
+		return
+	}
+	if err := services.TourismCreate(&t); err != nil {
+		sendWriteError(w, r, err)
+		return
+	}
+	sendSuccess(w, t)
+}
 
-	case "DELETE":
-		userID := r.Header.Get("X-Wechat-ID")
-		if userID == "" {
-			sendError(w, 401, "请先登录")
-			return
-		}
+func tourismDetailHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	item, err := services.TourismGetByID(id)
+	if err != nil {
+		sendError(w, r, errContentNotFound)
+		return
+	}
+	if err := services.AnnotateFavorite(r.Header.Get("X-Wechat-ID"), item); err != nil {
+		log.Printf("failed to annotate favorites: %v", err)
+	}
+	_ = services.IncrementTourismView(id)
+	recordView(r, "tourism", item.ID, item.Name, item.Image, item.Images)
+	setDetailValidators(w, item.UpdatedAt, item.IsBookmarked, item.FavoriteCount)
+	sendSuccess(w, item)
+}
 
-		tourism, err := services.TourismGetByID(id)
-		if err != nil {
-			sendError(w, 404, "景区不存在")
-			return
-		}
+func tourismDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	userID := r.Header.Get("X-Wechat-ID")
 
-		if !checkDeletePermission(userID, tourism.PublisherID) {
-			sendError(w, 403, "无权删除此内容")
-			return
-		}
+	tourism, err := services.TourismGetByID(id)
+	if err != nil {
+		sendError(w, r, errContentNotFound)
+		return
+	}
 
-		if err := services.TourismDelete(id); err != nil {
-			sendError(w, 500, "删除失败")
-			return
-		}
-		sendSuccess(w, map[string]interface{}{"message": "删除成功"})
+	if !checkDeletePermission(userID, tourism.PublisherID) {
+		sendError(w, r, errPermissionDenied)
+		return
+	}
 
-	default:
-		sendError(w, 405, "Method not allowed")
+	if err := services.TourismDelete(id, userID); err != nil {
+		sendError(w, r, errInternal)
+		return
 	}
+	recordAudit(r, userID, services.AuditContentDelete, "tourism", id, tourism, nil)
+	sendSuccess(w, map[string]interface{}{"message": "删除成功"})
 }
 
 // 招聘处理函数
-func jobsHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case "GET":
-		keyword := r.URL.Query().Get("keyword")
-		location := r.URL.Query().Get("location")
-		list, err := services.JobsList(keyword, location)
-		if err != nil {
-			sendError(w, 500, "数据库查询错误")
-			return
-		}
-		sendSuccess(w, map[string]interface{}{"list": list, "total": len(list), "page": 1, "page_size": len(list)})
-	case "POST":
-		var j services.Job
-		if err := json.NewDecoder(r.Body).Decode(&j); err != nil {
-			sendError(w, 400, "Invalid JSON")
-			return
-		}
-		if err := services.JobsCreate(&j); err != nil {
-			sendError(w, 500, "数据库写入失败")
-			return
-		}
-		sendSuccess(w, j)
-	default:
-		sendError(w, 405, "Method not allowed")
+func jobsListHandler(w http.ResponseWriter, r *http.Request) {
+	keyword := r.URL.Query().Get("keyword")
+	location := r.URL.Query().Get("location")
+	list, err := services.JobsList(keyword, location)
+	if err != nil {
+		sendError(w, r, errInternal)
+		return
+	}
+	if err := services.AnnotateFavorites(r.Header.Get("X-Wechat-ID"), list); err != nil {
+		log.Printf("failed to annotate favorites: %v", err)
+	}
+	setListLastModified(w, "jobs")
+	services.AnnotateThumbnails(list)
+	sendSuccess(w, map[string]interface{}{"list": list, "total": len(list), "page": 1, "page_size": len(list)})
+}
+
+func jobsCreateHandler(w http.ResponseWriter, r *http.Request) {
+	var j services.Job
+	if err := json.NewDecoder(r.Body).Decode(&j); err != nil {
+		sendError(w, r, errInvalidRequestBody)
+		return
 	}
+	if err := services.JobsCreate(&j); err != nil {
+		sendWriteError(w, r, err)
+		return
+	}
+	sendSuccess(w, j)
 }
 
 func jobsDetailHandler(w http.ResponseWriter, r *http.Request) {
-	path := strings.TrimPrefix(r.URL.Path, "/api/jobs/")
-	id, err := strconv.Atoi(path)
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	item, err := services.JobsGetByID(id)
 	if err != nil {
-		sendError(w, 400, "Invalid ID")
+		sendError(w, r, errContentNotFound)
 		return
 	}
+	if err := services.AnnotateFavorite(r.Header.Get("X-Wechat-ID"), item); err != nil {
+		log.Printf("failed to annotate favorites: %v", err)
+	}
+	_ = services.IncrementJobView(id)
+	recordView(r, "jobs", item.ID, item.Title, item.Logo)
+	setDetailValidators(w, item.UpdatedAt, item.IsBookmarked, item.FavoriteCount)
+	sendSuccess(w, item)
+}
 
-	switch r.Method {
-	case "GET":
-		item, err := services.JobsGetByID(id)
-		if err != nil {
-			sendError(w, 404, "Job not found")
-			return
-		}
-		_ = services.IncrementJobView(id)
-		sendSuccess(w, item)
-
-	case "DELETE":
-		userID := r.Header.Get("X-Wechat-ID")
-		if userID == "" {
-			sendError(w, 401, "请先登录")
-			return
-		}
-
-		job, err := services.JobsGetByID(id)
-		if err != nil {
-			sendError(w, 404, "招聘不存在")
-			return
-		}
+func jobsDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	userID := r.Header.Get("X-Wechat-ID")
 
-		if !checkDeletePermission(userID, job.PublisherID) {
-			sendError(w, 403, "无权删除此内容")
-			return
-		}
+	job, err := services.JobsGetByID(id)
+	if err != nil {
+		sendError(w, r, errContentNotFound)
+		return
+	}
 
-		if err := services.JobDelete(id); err != nil {
-			sendError(w, 500, "删除失败")
-			return
-		}
-		sendSuccess(w, map[string]interface{}{"message": "删除成功"})
+	if !checkDeletePermission(userID, job.PublisherID) {
+		sendError(w, r, errPermissionDenied)
+		return
+	}
 
-	default:
-		sendError(w, 405, "Method not allowed")
+	if err := services.JobDelete(id, userID); err != nil {
+		sendError(w, r, errInternal)
+		return
 	}
+	recordAudit(r, userID, services.AuditContentDelete, "jobs", id, job, nil)
+	sendSuccess(w, map[string]interface{}{"message": "删除成功"})
 }
 
 // 求助处理函数
-func helpHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case "GET":
-		keyword := r.URL.Query().Get("keyword")
-		category := r.URL.Query().Get("category")
-		urgency := r.URL.Query().Get("urgency")
-		list, err := services.HelpList(keyword, category, urgency)
-		if err != nil {
-			sendError(w, 500, "数据库查询错误")
-			return
-		}
-		sendSuccess(w, map[string]interface{}{"list": list, "total": len(list), "page": 1, "page_size": len(list)})
-	case "POST":
-		var h services.Help
-		if err := json.NewDecoder(r.Body).Decode(&h); err != nil {
-			sendError(w, 400, "Invalid JSON")
-			return
-		}
-		if err := services.HelpCreate(&h); err != nil {
-			sendError(w, 500, "数据库写入失败")
-			return
-		}
-		sendSuccess(w, h)
-	default:
-		sendError(w, 405, "Method not allowed")
+func helpListHandler(w http.ResponseWriter, r *http.Request) {
+	keyword := r.URL.Query().Get("keyword")
+	category := r.URL.Query().Get("category")
+	urgency := r.URL.Query().Get("urgency")
+	list, err := services.HelpList(keyword, category, urgency)
+	if err != nil {
+		sendError(w, r, errInternal)
+		return
+	}
+	if err := services.AnnotateFavorites(r.Header.Get("X-Wechat-ID"), list); err != nil {
+		log.Printf("failed to annotate favorites: %v", err)
+	}
+	setListLastModified(w, "help")
+	services.AnnotateThumbnails(list)
+	sendSuccess(w, map[string]interface{}{"list": list, "total": len(list), "page": 1, "page_size": len(list)})
+}
+
+func helpCreateHandler(w http.ResponseWriter, r *http.Request) {
+	var h services.Help
+	if err := json.NewDecoder(r.Body).Decode(&h); err != nil {
+		sendError(w, r, errInvalidRequestBody)
+		return
 	}
+	if err := services.HelpCreate(&h); err != nil {
+		sendWriteError(w, r, err)
+		return
+	}
+	notifyUrgentHelp(&h)
+	sendSuccess(w, h)
 }
 
 func helpDetailHandler(w http.ResponseWriter, r *http.Request) {
-	path := strings.TrimPrefix(r.URL.Path, "/api/help/")
-	id, err := strconv.Atoi(path)
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	item, err := services.HelpGetByID(id)
 	if err != nil {
-		sendError(w, 400, "Invalid ID")
+		sendError(w, r, errContentNotFound)
 		return
 	}
+	if err := services.AnnotateFavorite(r.Header.Get("X-Wechat-ID"), item); err != nil {
+		log.Printf("failed to annotate favorites: %v", err)
+	}
+	_ = services.IncrementHelpView(id)
+	recordView(r, "help", item.ID, item.Title, item.Image, item.Images)
+	setDetailValidators(w, item.UpdatedAt, item.IsBookmarked, item.FavoriteCount)
+	sendSuccess(w, item)
+}
 
-	switch r.Method {
-	case "GET":
-		item, err := services.HelpGetByID(id)
-		if err != nil {
-			sendError(w, 404, "Help not found")
-			return
-		}
-		_ = services.IncrementHelpView(id)
-		sendSuccess(w, item)
-
-	case "DELETE":
-		userID := r.Header.Get("X-Wechat-ID")
-		if userID == "" {
-			sendError(w, 401, "请先登录")
-			return
-		}
-
-		help, err := services.HelpGetByID(id)
-		if err != nil {
-			sendError(w, 404, "求助不存在")
-			return
-		}
+func helpDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	userID := r.Header.Get("X-Wechat-ID")
 
-		if !checkDeletePermission(userID, help.PublisherID) {
-			sendError(w, 403, "无权删除此内容")
-			return
-		}
+	help, err := services.HelpGetByID(id)
+	if err != nil {
+		sendError(w, r, errContentNotFound)
+		return
+	}
 
-		if err := services.HelpDelete(id); err != nil {
-			sendError(w, 500, "删除失败")
-			return
-		}
-		sendSuccess(w, map[string]interface{}{"message": "删除成功"})
+	if !checkDeletePermission(userID, help.PublisherID) {
+		sendError(w, r, errPermissionDenied)
+		return
+	}
 
-	default:
-		sendError(w, 405, "Method not allowed")
+	if err := services.HelpDelete(id, userID); err != nil {
+		sendError(w, r, errInternal)
+		return
 	}
+	recordAudit(r, userID, services.AuditContentDelete, "help", id, help, nil)
+	sendSuccess(w, map[string]interface{}{"message": "删除成功"})
 }
 
 // 乡村咨询处理
-func consultationHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case "GET":
-		keyword := r.URL.Query().Get("keyword")
-		category := r.URL.Query().Get("category")
-		list, err := services.ConsultationList(keyword, category)
-		if err != nil {
-			sendError(w, 500, "Failed to fetch consultations")
-			return
-		}
-		sendSuccess(w, list)
+func consultationListHandler(w http.ResponseWriter, r *http.Request) {
+	keyword := r.URL.Query().Get("keyword")
+	category := r.URL.Query().Get("category")
+	list, err := services.ConsultationList(keyword, category)
+	if err != nil {
+		sendError(w, r, errInternal)
+		return
+	}
+	if err := services.AnnotateFavorites(r.Header.Get("X-Wechat-ID"), list); err != nil {
+		log.Printf("failed to annotate favorites: %v", err)
+	}
+	setListLastModified(w, "consultation")
+	services.AnnotateThumbnails(list)
+	sendSuccess(w, list)
+}
+
+func consultationCreateHandler(w http.ResponseWriter, r *http.Request) {
+	var req services.Consultation
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, errInvalidRequestBody)
+		return
+	}
 
-	case "POST":
-		var req services.Consultation
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			sendError(w, 400, "Invalid JSON")
+	// 验证是否为管理员
+	// 检查 author_id 是否以 "admin_" 开头（管理员）
+	if !strings.HasPrefix(req.AuthorID, "admin_") {
+		// 如果不是管理员，检查是否为微信用户且有管理员权限
+		user, err := services.GetUserByWechatID(req.AuthorID)
+		if err != nil || (user.Role != "super_admin" && user.Role != "admin") {
+			sendError(w, r, errAdminRequired)
 			return
 		}
+	}
 
-		// 验证是否为管理员
-		// 检查 author_id 是否以 "admin_" 开头（管理员）
-		if !strings.HasPrefix(req.AuthorID, "admin_") {
-			// 如果不是管理员，检查是否为微信用户且有管理员权限
-			user, err := services.GetUserByWechatID(req.AuthorID)
-			if err != nil || (user.Role != "super_admin" && user.Role != "admin") {
-				sendError(w, 403, "只有管理员可以发布乡村咨询")
-				return
-			}
-		}
+	if err := services.ConsultationCreate(&req); err != nil {
+		log.Printf("创建咨询失败: %v", err)
+		sendWriteError(w, r, err)
+		return
+	}
 
-		if err := services.ConsultationCreate(&req); err != nil {
-			log.Printf("创建咨询失败: %v", err)
-			sendError(w, 500, "Failed to create consultation")
-			return
-		}
+	log.Printf("✅ 咨询发布成功: %s (作者: %s)", req.Title, req.Author)
+	sendSuccess(w, map[string]interface{}{
+		"message": "咨询发布成功",
+		"id":      req.ID,
+	})
+}
+
+func consultationDetailHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	item, err := services.ConsultationGetByID(id)
+	if err != nil {
+		sendError(w, r, errContentNotFound)
+		return
+	}
+	if err := services.AnnotateFavorite(r.Header.Get("X-Wechat-ID"), item); err != nil {
+		log.Printf("failed to annotate favorites: %v", err)
+	}
+	_ = services.IncrementConsultationView(id)
+	recordView(r, "consultation", item.ID, item.Title, item.Images)
+	setDetailValidators(w, item.UpdatedAt, item.IsBookmarked, item.FavoriteCount)
+	sendSuccess(w, item)
+}
 
-		log.Printf("✅ 咨询发布成功: %s (作者: %s)", req.Title, req.Author)
-		sendSuccess(w, map[string]interface{}{
-			"message": "咨询发布成功",
-			"id":      req.ID,
-		})
+// 管理员回复咨询，回复后通知咨询发布者
+func consultationAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req services.ConsultationAnswer
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, errInvalidRequestBody)
+		return
+	}
+	if _, err := services.ConsultationGetByID(id); err != nil {
+		sendError(w, r, errContentNotFound)
+		return
+	}
 
-	default:
-		sendError(w, 405, "Method not allowed")
+	item, err := services.AnswerConsultation(id, req, r.Header.Get("X-Wechat-ID"))
+	if err != nil {
+		sendWriteError(w, r, err)
+		return
 	}
+	notifyConsultationAnswered(item)
+	sendSuccess(w, item)
 }
 
-func consultationDetailHandler(w http.ResponseWriter, r *http.Request) {
-	path := strings.TrimPrefix(r.URL.Path, "/api/consultation/")
-	id, err := strconv.Atoi(path)
+func consultationDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	userID := r.Header.Get("X-Wechat-ID")
+
+	// 获取咨询信息
+	consultation, err := services.ConsultationGetByID(id)
 	if err != nil {
-		sendError(w, 400, "Invalid ID")
+		sendError(w, r, errContentNotFound)
 		return
 	}
 
-	switch r.Method {
-	case "GET":
-		item, err := services.ConsultationGetByID(id)
-		if err != nil {
-			sendError(w, 404, "Consultation not found")
-			return
-		}
-		_ = services.IncrementConsultationView(id)
-		sendSuccess(w, item)
-
-	case "DELETE":
-		// 权限检查：需要登录
-		userID := r.Header.Get("X-Wechat-ID")
-		if userID == "" {
-			sendError(w, 401, "请先登录")
-			return
-		}
+	// 检查权限：作者本人或管理员可删除
+	canDelete := false
 
-		// 获取咨询信息
-		consultation, err := services.ConsultationGetByID(id)
-		if err != nil {
-			sendError(w, 404, "咨询不存在")
-			return
+	// 检查是否为管理员
+	if strings.HasPrefix(userID, "admin_") {
+		username := strings.TrimPrefix(userID, "admin_")
+		admin, err := services.GetAdminByUsername(username)
+		if err == nil && (admin.Role == "super_admin" || admin.Role == "admin") {
+			canDelete = true
 		}
-
-		// 检查权限：作者本人或管理员可删除
-		canDelete := false
-
-		// 检查是否为管理员
-		if strings.HasPrefix(userID, "admin_") {
-			username := strings.TrimPrefix(userID, "admin_")
-			admin, err := services.GetAdminByUsername(username)
-			if err == nil && (admin.Role == "super_admin" || admin.Role == "admin") {
+	} else {
+		// 检查是否为作者或微信管理员
+		user, err := services.GetUserByWechatID(userID)
+		if err == nil {
+			if user.Role == "super_admin" || user.Role == "admin" || consultation.AuthorID == userID {
 				canDelete = true
 			}
-		} else {
-			// 检查是否为作者或微信管理员
-			user, err := services.GetUserByWechatID(userID)
-			if err == nil {
-				if user.Role == "super_admin" || user.Role == "admin" || consultation.AuthorID == userID {
-					canDelete = true
-				}
-			}
-		}
-
-		if !canDelete {
-			sendError(w, 403, "无权删除此内容")
-			return
 		}
+	}
 
-		if err := services.ConsultationDelete(id); err != nil {
-			sendError(w, 500, "删除失败")
-			return
-		}
-		sendSuccess(w, map[string]interface{}{"message": "删除成功"})
+	if !canDelete {
+		sendError(w, r, errPermissionDenied)
+		return
+	}
 
-	default:
-		sendError(w, 405, "Method not allowed")
+	if err := services.ConsultationDelete(id, userID); err != nil {
+		sendError(w, r, errInternal)
+		return
 	}
+	recordAudit(r, userID, services.AuditContentDelete, "consultation", id, consultation, nil)
+	sendSuccess(w, map[string]interface{}{"message": "删除成功"})
 }
 
 // 用户处理函数（注册/登录/获取资料）
 // 旧的登录接口（已废弃，使用微信登录）
 func loginHandler(w http.ResponseWriter, r *http.Request) {
-	sendError(w, 410, "此接口已废弃，请使用微信登录 /api/user/wechat-login")
+	sendError(w, r, errEndpointGone)
 }
 
 // 旧的注册接口（已废弃，使用微信登录）
 func registerHandler(w http.ResponseWriter, r *http.Request) {
-	sendError(w, 410, "此接口已废弃，请使用微信登录 /api/user/wechat-login")
+	sendError(w, r, errEndpointGone)
 }
 
 // 中间件：验证微信ID（替代旧的token验证）
@@ -979,183 +1017,61 @@ func authRequired(next func(http.ResponseWriter, *http.Request, *services.User))
 	return func(w http.ResponseWriter, r *http.Request) {
 		wechatID := r.Header.Get("X-Wechat-ID")
 		if wechatID == "" {
-			sendError(w, 401, "缺少 X-Wechat-ID")
+			sendError(w, r, errUnauthorized)
 			return
 		}
 		u, err := services.GetUserByWechatID(wechatID)
 		if err != nil {
-			sendError(w, 401, "用户不存在")
+			sendError(w, r, errAccountNotFound)
 			return
 		}
 		next(w, r, u)
 	}
 }
 
-func userHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case "GET":
-		handler := authRequired(func(w http.ResponseWriter, r *http.Request, u *services.User) {
-			sendSuccess(w, u)
-		})
-		handler(w, r)
-	case "PUT":
-		handler := authRequired(func(w http.ResponseWriter, r *http.Request, u *services.User) {
-			var payload map[string]interface{}
-			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-				sendError(w, 400, "Invalid JSON")
-				return
-			}
-			if err := services.UpdateUserProfile(u.ID, payload); err != nil {
-				sendError(w, 500, "更新失败")
-				return
-			}
-			sendSuccess(w, nil)
-		})
-		handler(w, r)
-	default:
-		sendError(w, 405, "Method not allowed")
-	}
-}
-
-// 健康检查
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
-		sendError(w, 405, "Method not allowed")
-		return
-	}
-	// 简单返回数据库连接状态与统计
-	sendSuccess(w, map[string]interface{}{"status": "healthy", "uptime": time.Since(startTime).String()})
+func userProfileHandler(w http.ResponseWriter, r *http.Request, u *services.User) {
+	sendSuccess(w, u)
 }
 
-// 文件上传处理函数
-func uploadHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		sendError(w, 405, "Method not allowed")
-		return
-	}
-
-	// 解析multipart form，限制文件大小为10MB
-	err := r.ParseMultipartForm(10 << 20)
-	if err != nil {
-		sendError(w, 400, "File too large")
-		return
-	}
-
-	file, handler, err := r.FormFile("file")
-	if err != nil {
-		sendError(w, 400, "No file uploaded")
+// 用户修改自己的资料，只接受昵称、头像、电话、邮箱，其他字段（如 role）忽略
+func updateUserProfileHandler(w http.ResponseWriter, r *http.Request, u *services.User) {
+	var payload services.UserProfileUpdate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		sendError(w, r, errInvalidRequestBody)
 		return
 	}
-	defer file.Close()
-
-	// 生成唯一文件名，防止重名
-	originalName := handler.Filename
-	ext := strings.ToLower(filepath.Ext(originalName))
-	nameWithoutExt := strings.TrimSuffix(originalName, ext)
-
-	// 检查文件类型
-	allowedTypes := map[string]bool{
-		".jpg":  true,
-		".jpeg": true,
-		".png":  true,
-		".gif":  true,
-		".pdf":  true,
-		".doc":  true,
-		".docx": true,
-	}
-
-	if !allowedTypes[ext] {
-		sendError(w, 400, "File type not allowed")
+	if err := services.UpdateUserProfile(u.ID, payload); err != nil {
+		sendWriteError(w, r, err)
 		return
 	}
+	sendSuccess(w, nil)
+}
 
-	// 使用时间戳 + 随机数 + 原文件名生成唯一文件名
-	timestamp := time.Now().Format("20060102150405")
-	hash := md5.Sum([]byte(timestamp + originalName))
-	uniqueID := hex.EncodeToString(hash[:])[:8]
-	filename := fmt.Sprintf("%s_%s_%s%s", timestamp, uniqueID, nameWithoutExt, ext)
-
-	filePath := filepath.Join("./uploads", filename)
-
-	// 检查文件是否已存在（双重保险）
-	if _, err := os.Stat(filePath); err == nil {
-		// 文件已存在，添加额外的随机后缀
-		filename = fmt.Sprintf("%s_%s_%s_%d%s", timestamp, uniqueID, nameWithoutExt, time.Now().UnixNano(), ext)
-		filePath = filepath.Join("./uploads", filename)
-	}
-
-	// 创建目标文件
-	dst, err := os.Create(filePath)
-	if err != nil {
-		sendError(w, 500, "Failed to create file")
+// initTokenSecret 检查登录令牌签名密钥，未配置时随机生成（重启后已签发的令牌失效）
+func initTokenSecret() {
+	if config.Auth.TokenSecret != "" {
 		return
 	}
-	defer dst.Close()
-
-	// 复制文件内容
-	fileSize, err := io.Copy(dst, file)
-	if err != nil {
-		sendError(w, 500, "Failed to save file")
-		return
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("生成令牌密钥失败: %v", err)
 	}
-
-	// 获取文件类型描述
-	fileType := getFileTypeDescription(ext)
-
-	// 返回详细的文件信息
-	fileURL := "http://localhost:8080/uploads/" + filename
-	sendSuccess(w, map[string]interface{}{
-		"url":         fileURL,
-		"name":        originalName,
-		"size":        fileSize,
-		"path":        filename,
-		"type":        ext,
-		"type_desc":   fileType,
-		"upload_time": time.Now().Format("2006-01-02 15:04:05"),
-	})
+	config.Auth.TokenSecret = hex.EncodeToString(secret)
+	log.Println("⚠️  未配置 TOKEN_SECRET，使用随机密钥，重启后已签发的令牌失效")
 }
 
-// 简单的token生成函数
+// 简单的token生成函数：用户名和签发时间以配置的密钥签名
 func generateToken(username string) string {
-	data := username + time.Now().String()
-	hash := md5.Sum([]byte(data))
-	return hex.EncodeToString(hash[:])
-}
-
-// 获取文件类型描述
-func getFileTypeDescription(ext string) string {
-	typeMap := map[string]string{
-		".pdf":  "PDF文档",
-		".doc":  "Word文档",
-		".docx": "Word文档",
-		".xls":  "Excel表格",
-		".xlsx": "Excel表格",
-		".ppt":  "PPT演示文稿",
-		".pptx": "PPT演示文稿",
-		".txt":  "文本文件",
-		".jpg":  "图片",
-		".jpeg": "图片",
-		".png":  "图片",
-		".gif":  "图片",
-		".zip":  "压缩文件",
-		".rar":  "压缩文件",
-	}
-
-	if desc, ok := typeMap[ext]; ok {
-		return desc
-	}
-	return "未知文件"
+	data := username + "|" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	mac := hmac.New(sha256.New, []byte(config.Auth.TokenSecret))
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
 // ==================== 用户管理 API ====================
 
 // 微信登录处理
 func wechatLoginHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		sendError(w, 405, "Method not allowed")
-		return
-	}
-
 	var req struct {
 		WechatID string `json:"wechat_id"`
 		Nickname string `json:"nickname"`
@@ -1163,18 +1079,18 @@ func wechatLoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendError(w, 400, "Invalid JSON")
+		sendError(w, r, errInvalidRequestBody)
 		return
 	}
 
 	if req.WechatID == "" {
-		sendError(w, 400, "wechat_id is required")
+		sendErrorData(w, r, errMissingFields, missingFieldsData("wechat_id"))
 		return
 	}
 
 	user, err := services.GetOrCreateUserByWechatID(req.WechatID, req.Nickname, req.Avatar)
 	if err != nil {
-		sendError(w, 500, "Failed to login")
+		sendError(w, r, errInternal)
 		return
 	}
 
@@ -1184,41 +1100,6 @@ func wechatLoginHandler(w http.ResponseWriter, r *http.Request) {
 
 // 获取用户列表（需要管理员权限）
 func userListHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
-		sendError(w, 405, "Method not allowed")
-		return
-	}
-
-	// 获取请求头中的微信ID（实际应用中应该从token中解析）
-	adminWechatID := r.Header.Get("X-Wechat-ID")
-	if adminWechatID == "" {
-		sendError(w, 401, "Unauthorized")
-		return
-	}
-
-	// 检查权限：支持管理员账号和微信用户
-	hasPermission := false
-
-	// 如果是管理员登录（wechat_id格式为 admin_xxx）
-	if strings.HasPrefix(adminWechatID, "admin_") {
-		username := strings.TrimPrefix(adminWechatID, "admin_")
-		admin, err := services.GetAdminByUsername(username)
-		if err == nil && (admin.Role == "super_admin" || admin.Role == "admin") {
-			hasPermission = true
-		}
-	} else {
-		// 微信用户
-		permission, err := services.CheckUserPermission(adminWechatID, "admin")
-		if err == nil && permission {
-			hasPermission = true
-		}
-	}
-
-	if !hasPermission {
-		sendError(w, 403, "Forbidden: Admin permission required")
-		return
-	}
-
 	// 获取查询参数
 	page := 1
 	pageSize := 20
@@ -1233,7 +1114,7 @@ func userListHandler(w http.ResponseWriter, r *http.Request) {
 
 	users, total, err := services.GetAllUsers(page, pageSize, role)
 	if err != nil {
-		sendError(w, 500, "Failed to get users")
+		sendError(w, r, errInternal)
 		return
 	}
 
@@ -1247,17 +1128,8 @@ func userListHandler(w http.ResponseWriter, r *http.Request) {
 
 // 更新用户角色（需要管理员权限）
 func updateRoleHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		sendError(w, 405, "Method not allowed")
-		return
-	}
-
 	// 获取请求头中的微信ID
 	adminWechatID := r.Header.Get("X-Wechat-ID")
-	if adminWechatID == "" {
-		sendError(w, 401, "Unauthorized")
-		return
-	}
 
 	var req struct {
 		UserID  int    `json:"user_id"`
@@ -1265,7 +1137,7 @@ func updateRoleHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendError(w, 400, "Invalid JSON")
+		sendError(w, r, errInvalidRequestBody)
 		return
 	}
 
@@ -1277,7 +1149,7 @@ func updateRoleHandler(w http.ResponseWriter, r *http.Request) {
 		username := strings.TrimPrefix(adminWechatID, "admin_")
 		adminAccount, err := services.GetAdminByUsername(username)
 		if err != nil {
-			sendError(w, 401, "Unauthorized")
+			sendError(w, r, errUnauthorized)
 			return
 		}
 		adminRole = adminAccount.Role
@@ -1285,7 +1157,7 @@ func updateRoleHandler(w http.ResponseWriter, r *http.Request) {
 		// 微信用户
 		admin, err := services.GetUserByWechatID(adminWechatID)
 		if err != nil {
-			sendError(w, 401, "Unauthorized")
+			sendError(w, r, errUnauthorized)
 			return
 		}
 		adminRole = admin.Role
@@ -1301,33 +1173,41 @@ func updateRoleHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if !isValidRole {
-		sendError(w, 400, "Invalid role")
+		sendErrorData(w, r, errInvalidParameter, fieldError("role"))
 		return
 	}
 
 	// 不允许设置超级管理员角色（保持唯一性）
 	if req.NewRole == "super_admin" {
-		sendError(w, 403, "Forbidden: Cannot set super_admin role")
+		sendError(w, r, errRoleNotAssignable)
 		return
 	}
 
 	// 只有超级管理员可以设置管理员角色
 	if req.NewRole == "admin" && adminRole != "super_admin" {
-		sendError(w, 403, "Forbidden: Only super_admin can set admin role")
+		sendError(w, r, errSuperAdminRequired)
 		return
 	}
 
 	// 检查是否有权限
 	if adminRole != "super_admin" && adminRole != "admin" {
-		sendError(w, 403, "Forbidden: Admin permission required")
+		sendError(w, r, errAdminRequired)
+		return
+	}
+
+	target, err := services.GetUserProfileByID(req.UserID)
+	if err != nil {
+		sendError(w, r, errUserNotFound)
 		return
 	}
 
 	// 更新角色
 	if err := services.UpdateUserRole(req.UserID, req.NewRole); err != nil {
-		sendError(w, 500, "Failed to update role")
+		sendError(w, r, errInternal)
 		return
 	}
+	recordAudit(r, adminWechatID, services.AuditRoleUpdate, "user", req.UserID,
+		map[string]string{"role": target.Role}, map[string]string{"role": req.NewRole})
 
 	log.Printf("✅ 角色更新: 用户ID %d -> %s (操作者角色: %s)", req.UserID, req.NewRole, adminRole)
 	sendSuccess(w, map[string]interface{}{
@@ -1335,92 +1215,79 @@ func updateRoleHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// 收藏管理
-func favoriteHandler(w http.ResponseWriter, r *http.Request) {
-	wechatID := r.Header.Get("X-Wechat-ID")
-	if wechatID == "" {
-		sendError(w, 401, "Unauthorized")
+// 获取收藏列表
+func favoriteListHandler(w http.ResponseWriter, r *http.Request) {
+	favorites, err := services.GetUserFavorites(r.Header.Get("X-Wechat-ID"))
+	if err != nil {
+		sendError(w, r, errInternal)
 		return
 	}
 
-	switch r.Method {
-	case "POST":
-		// 添加收藏
-		var req struct {
-			ItemType string `json:"item_type"`
-			ItemID   int    `json:"item_id"`
-			Title    string `json:"title"`
-			Image    string `json:"image"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			sendError(w, 400, "Invalid JSON")
-			return
-		}
-
-		if err := services.AddUserFavorite(wechatID, req.ItemType, req.ItemID, req.Title, req.Image); err != nil {
-			sendError(w, 500, "Failed to add favorite")
-			return
-		}
-
-		sendSuccess(w, map[string]interface{}{"message": "Added to favorites"})
-
-	case "DELETE":
-		// 移除收藏
-		var req struct {
-			ItemType string `json:"item_type"`
-			ItemID   int    `json:"item_id"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			sendError(w, 400, "Invalid JSON")
-			return
-		}
-
-		if err := services.RemoveUserFavorite(wechatID, req.ItemType, req.ItemID); err != nil {
-			sendError(w, 500, "Failed to remove favorite")
-			return
-		}
+	sendSuccess(w, map[string]interface{}{"favorites": favorites})
+}
 
-		sendSuccess(w, map[string]interface{}{"message": "Removed from favorites"})
+// 添加收藏
+func addFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ItemType string `json:"item_type"`
+		ItemID   int    `json:"item_id"`
+		Title    string `json:"title"`
+		Image    string `json:"image"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, errInvalidRequestBody)
+		return
+	}
 
-	case "GET":
-		// 获取收藏列表
-		user, err := services.GetUserByWechatID(wechatID)
-		if err != nil {
-			sendError(w, 404, "User not found")
-			return
-		}
+	err := services.AddUserFavorite(r.Header.Get("X-Wechat-ID"), req.ItemType, req.ItemID, req.Title, req.Image)
+	switch {
+	case errors.Is(err, services.ErrUnsupportedContentType):
+		sendErrorData(w, r, errInvalidParameter, fieldError("item_type"))
+		return
+	case errors.Is(err, services.ErrContentNotFound):
+		sendError(w, r, errContentNotFound)
+		return
+	case err != nil:
+		sendError(w, r, errInternal)
+		return
+	}
 
-		var favorites []map[string]interface{}
-		if user.Favorites != "" && user.Favorites != "[]" {
-			json.Unmarshal([]byte(user.Favorites), &favorites)
-		}
+	sendSuccess(w, map[string]interface{}{"message": "Added to favorites"})
+}
 
-		sendSuccess(w, map[string]interface{}{"favorites": favorites})
+// 移除收藏
+func removeFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ItemType string `json:"item_type"`
+		ItemID   int    `json:"item_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, errInvalidRequestBody)
+		return
+	}
 
-	default:
-		sendError(w, 405, "Method not allowed")
+	if err := services.RemoveUserFavorite(r.Header.Get("X-Wechat-ID"), req.ItemType, req.ItemID); err != nil {
+		sendError(w, r, errInternal)
+		return
 	}
+
+	sendSuccess(w, map[string]interface{}{"message": "Removed from favorites"})
 }
 
 // 管理员登录处理
 func adminLoginHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		sendError(w, 405, "Method not allowed")
-		return
-	}
-
 	var req struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendError(w, 400, "Invalid JSON")
+		sendError(w, r, errInvalidRequestBody)
 		return
 	}
 
 	if req.Username == "" || req.Password == "" {
-		sendError(w, 400, "用户名和密码不能为空")
+		sendErrorData(w, r, errMissingFields, missingFieldsData("username", "password"))
 		return
 	}
 
@@ -1428,11 +1295,13 @@ func adminLoginHandler(w http.ResponseWriter, r *http.Request) {
 	admin, err := services.AdminLogin(req.Username, req.Password)
 	if err != nil {
 		log.Printf("管理员登录失败: %v", err)
-		sendError(w, 401, "用户名或密码错误")
+		recordAudit(r, "admin_"+req.Username, services.AuditAdminLogin, "admin", req.Username, nil, map[string]bool{"success": false})
+		sendError(w, r, errInvalidCredentials)
 		return
 	}
 
 	log.Printf("✅ 管理员登录成功: %s (%s)", admin.Username, admin.Nickname)
+	recordAudit(r, "admin_"+admin.Username, services.AuditAdminLogin, "admin", admin.Username, nil, map[string]bool{"success": true})
 
 	sendSuccess(w, map[string]interface{}{
 		"message": "登录成功",
@@ -1442,11 +1311,6 @@ func adminLoginHandler(w http.ResponseWriter, r *http.Request) {
 
 // 管理员赋权处理
 func adminGrantRoleHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		sendError(w, 405, "Method not allowed")
-		return
-	}
-
 	var req struct {
 		AdminUsername string `json:"admin_username"` // 管理员用户名
 		UserWechatID  string `json:"user_wechat_id"` // 要赋权的用户微信ID
@@ -1454,7 +1318,7 @@ func adminGrantRoleHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendError(w, 400, "Invalid JSON")
+		sendError(w, r, errInvalidRequestBody)
 		return
 	}
 
@@ -1462,7 +1326,7 @@ func adminGrantRoleHandler(w http.ResponseWriter, r *http.Request) {
 	admin, err := services.GetAdminByUsername(req.AdminUsername)
 	if err != nil {
 		log.Printf("管理员验证失败: %v", err)
-		sendError(w, 401, "管理员身份验证失败")
+		sendError(w, r, errInvalidCredentials)
 		return
 	}
 
@@ -1476,19 +1340,19 @@ func adminGrantRoleHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if !isValidRole {
-		sendError(w, 400, "无效的角色")
+		sendErrorData(w, r, errInvalidParameter, fieldError("role"))
 		return
 	}
 
 	// 不允许设置超级管理员角色（保持唯一性）
 	if req.NewRole == "super_admin" {
-		sendError(w, 403, "权限不足：不能设置超级管理员角色")
+		sendError(w, r, errRoleNotAssignable)
 		return
 	}
 
 	// 只有超级管理员可以设置管理员角色
 	if req.NewRole == "admin" && admin.Role != "super_admin" {
-		sendError(w, 403, "权限不足：只有超级管理员可以设置管理员角色")
+		sendError(w, r, errSuperAdminRequired)
 		return
 	}
 
@@ -1496,16 +1360,18 @@ func adminGrantRoleHandler(w http.ResponseWriter, r *http.Request) {
 	user, err := services.GetUserByWechatID(req.UserWechatID)
 	if err != nil {
 		log.Printf("用户不存在: %v", err)
-		sendError(w, 404, "用户不存在")
+		sendError(w, r, errUserNotFound)
 		return
 	}
 
 	// 更新角色
 	if err := services.UpdateUserRoleByWechatID(req.UserWechatID, req.NewRole); err != nil {
 		log.Printf("更新角色失败: %v", err)
-		sendError(w, 500, "更新角色失败")
+		sendError(w, r, errInternal)
 		return
 	}
+	recordAudit(r, "admin_"+admin.Username, services.AuditRoleGrant, "user", user.WechatID,
+		map[string]string{"role": user.Role}, map[string]string{"role": req.NewRole})
 
 	log.Printf("✅ 管理员 %s 将用户 %s 的角色更新为 %s", admin.Username, user.Nickname, req.NewRole)
 
@@ -1521,35 +1387,30 @@ func adminGrantRoleHandler(w http.ResponseWriter, r *http.Request) {
 
 // 更新用户头像
 func updateAvatarHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		sendError(w, 405, "Method not allowed")
-		return
-	}
-
 	var req struct {
 		WechatID string `json:"wechat_id"`
 		Avatar   string `json:"avatar"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendError(w, 400, "Invalid JSON")
+		sendError(w, r, errInvalidRequestBody)
 		return
 	}
 
 	if req.WechatID == "" || req.Avatar == "" {
-		sendError(w, 400, "wechat_id 和 avatar 不能为空")
+		sendErrorData(w, r, errMissingFields, missingFieldsData("wechat_id", "avatar"))
 		return
 	}
 
 	if err := services.UpdateUserAvatar(req.WechatID, req.Avatar); err != nil {
-		sendError(w, 500, "更新头像失败")
+		sendWriteError(w, r, err)
 		return
 	}
 
 	// 获取更新后的用户信息
 	user, err := services.GetUserByWechatID(req.WechatID)
 	if err != nil {
-		sendError(w, 500, "获取用户信息失败")
+		sendError(w, r, errInternal)
 		return
 	}
 
@@ -1561,35 +1422,30 @@ func updateAvatarHandler(w http.ResponseWriter, r *http.Request) {
 
 // 更新用户昵称
 func updateNicknameHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		sendError(w, 405, "Method not allowed")
-		return
-	}
-
 	var req struct {
 		WechatID string `json:"wechat_id"`
 		Nickname string `json:"nickname"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendError(w, 400, "Invalid JSON")
+		sendError(w, r, errInvalidRequestBody)
 		return
 	}
 
 	if req.WechatID == "" || req.Nickname == "" {
-		sendError(w, 400, "wechat_id 和 nickname 不能为空")
+		sendErrorData(w, r, errMissingFields, missingFieldsData("wechat_id", "nickname"))
 		return
 	}
 
 	if err := services.UpdateUserNickname(req.WechatID, req.Nickname); err != nil {
-		sendError(w, 500, "更新昵称失败")
+		sendWriteError(w, r, err)
 		return
 	}
 
 	// 获取更新后的用户信息
 	user, err := services.GetUserByWechatID(req.WechatID)
 	if err != nil {
-		sendError(w, 500, "获取用户信息失败")
+		sendError(w, r, errInternal)
 		return
 	}
 
@@ -1601,22 +1457,9 @@ func updateNicknameHandler(w http.ResponseWriter, r *http.Request) {
 
 // 我的发布处理器
 func myPublishHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
-		sendError(w, 405, "Method not allowed")
-		return
-	}
-
-	// 从URL路径中提取模块类型
 	// /api/my-publish/policy -> policy
-	path := strings.TrimPrefix(r.URL.Path, "/api/my-publish/")
-	module := strings.TrimSuffix(path, "/")
-
-	// 获取用户ID
+	module := r.PathValue("module")
 	wechatID := r.Header.Get("X-Wechat-ID")
-	if wechatID == "" {
-		sendError(w, 401, "未登录")
-		return
-	}
 
 	log.Printf("📋 获取我的发布 - 模块: %s, 用户: %s", module, wechatID)
 
@@ -1640,76 +1483,63 @@ func myPublishHandler(w http.ResponseWriter, r *http.Request) {
 	case "news":
 		data, err = services.GetMyPublishNews(wechatID)
 	default:
-		sendError(w, 400, "不支持的模块类型")
+		sendErrorData(w, r, errInvalidParameter, fieldError("module"))
 		return
 	}
 
 	if err != nil {
 		log.Printf("❌ 获取我的发布失败: %v", err)
-		sendError(w, 500, "获取数据失败")
+		sendError(w, r, errInternal)
 		return
 	}
 
 	sendSuccess(w, data)
 }
 
-// 浏览历史处理器
-func historyHandler(w http.ResponseWriter, r *http.Request) {
-	wechatID := r.Header.Get("X-Wechat-ID")
-	if wechatID == "" {
-		sendError(w, 401, "Unauthorized")
+// 获取浏览历史
+func historyListHandler(w http.ResponseWriter, r *http.Request) {
+	history, err := services.GetUserHistory(r.Header.Get("X-Wechat-ID"))
+	if err != nil {
+		sendError(w, r, errInternal)
 		return
 	}
+	sendSuccess(w, map[string]interface{}{"history": history})
+}
 
-	switch r.Method {
-	case "GET":
-		// 获取浏览历史
-		history, err := services.GetUserHistory(wechatID)
-		if err != nil {
-			sendError(w, 500, "Failed to get history")
-			return
-		}
-		sendSuccess(w, map[string]interface{}{"history": history})
-
-	case "POST":
-		// 添加浏览记录
-		var req struct {
-			ItemType string `json:"item_type"`
-			ItemID   int    `json:"item_id"`
-			Title    string `json:"title"`
-			Image    string `json:"image"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			sendError(w, 400, "Invalid JSON")
-			return
-		}
-
-		if err := services.AddUserHistory(wechatID, req.ItemType, req.ItemID, req.Title, req.Image); err != nil {
-			sendError(w, 500, "Failed to add history")
-			return
-		}
-		sendSuccess(w, map[string]interface{}{"message": "History added"})
-
-	case "DELETE":
-		// 清空浏览历史
-		if err := services.ClearUserHistory(wechatID); err != nil {
-			sendError(w, 500, "Failed to clear history")
-			return
-		}
-		sendSuccess(w, map[string]interface{}{"message": "History cleared"})
+// 添加浏览记录（兼容旧版客户端；详情接口已自动记录，标题和图片以服务端记录为准）
+func addHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ItemType string `json:"item_type"`
+		ItemID   int    `json:"item_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, errInvalidRequestBody)
+		return
+	}
 
-	default:
-		sendError(w, 405, "Method not allowed")
+	itemType, ok := services.NormalizeContentType(req.ItemType)
+	if !ok {
+		sendErrorData(w, r, errInvalidParameter, fieldError("item_type"))
+		return
+	}
+	if err := services.RecordUserView(r.Header.Get("X-Wechat-ID"), itemType, req.ItemID); err != nil {
+		sendError(w, r, errContentNotFound)
+		return
 	}
+	sendSuccess(w, map[string]interface{}{"message": "History added"})
 }
 
-// 意见反馈处理器（用户端）
-func feedbackHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		sendError(w, 405, "Method not allowed")
+// 清空浏览历史
+func clearHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if err := services.ClearUserHistory(r.Header.Get("X-Wechat-ID")); err != nil {
+		sendError(w, r, errInternal)
 		return
 	}
+	sendSuccess(w, map[string]interface{}{"message": "History cleared"})
+}
 
+// 意见反馈处理器（用户端）
+func feedbackHandler(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Type     string `json:"type"`
 		Content  string `json:"content"`
@@ -1719,17 +1549,12 @@ func feedbackHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendError(w, 400, "Invalid JSON")
-		return
-	}
-
-	if req.Content == "" {
-		sendError(w, 400, "Content is required")
+		sendError(w, r, errInvalidRequestBody)
 		return
 	}
 
 	if err := services.CreateFeedback(req.Type, req.Content, req.Contact, req.UserID, req.Nickname); err != nil {
-		sendError(w, 500, "Failed to create feedback")
+		sendWriteError(w, r, err)
 		return
 	}
 
@@ -1738,26 +1563,9 @@ func feedbackHandler(w http.ResponseWriter, r *http.Request) {
 
 // 管理员获取反馈列表
 func adminFeedbackHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
-		sendError(w, 405, "Method not allowed")
-		return
-	}
-
-	wechatID := r.Header.Get("X-Wechat-ID")
-	if wechatID == "" {
-		sendError(w, 401, "Unauthorized")
-		return
-	}
-
-	// 验证管理员身份
-	if !strings.HasPrefix(wechatID, "admin_") {
-		sendError(w, 403, "Admin only")
-		return
-	}
-
 	feedbacks, err := services.GetAllFeedback()
 	if err != nil {
-		sendError(w, 500, "Failed to get feedbacks")
+		sendError(w, r, errInternal)
 		return
 	}
 
@@ -1765,35 +1573,14 @@ func adminFeedbackHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // 管理员标记反馈已读
-func adminFeedbackDetailHandler(w http.ResponseWriter, r *http.Request) {
-	// /api/admin/feedback/123/read
-	path := strings.TrimPrefix(r.URL.Path, "/api/admin/feedback/")
-	parts := strings.Split(path, "/")
-
-	if len(parts) < 2 || parts[1] != "read" {
-		sendError(w, 400, "Invalid path")
-		return
-	}
-
-	feedbackID, err := strconv.Atoi(parts[0])
-	if err != nil {
-		sendError(w, 400, "Invalid feedback ID")
-		return
-	}
-
-	if r.Method != "POST" {
-		sendError(w, 405, "Method not allowed")
-		return
-	}
-
-	wechatID := r.Header.Get("X-Wechat-ID")
-	if !strings.HasPrefix(wechatID, "admin_") {
-		sendError(w, 403, "Admin only")
+func adminFeedbackReadHandler(w http.ResponseWriter, r *http.Request) {
+	feedbackID, ok := pathID(w, r)
+	if !ok {
 		return
 	}
 
 	if err := services.MarkFeedbackRead(feedbackID); err != nil {
-		sendError(w, 500, "Failed to mark as read")
+		sendError(w, r, errInternal)
 		return
 	}
 
@@ -1802,49 +1589,30 @@ func adminFeedbackDetailHandler(w http.ResponseWriter, r *http.Request) {
 
 // 轮播图设置处理
 func bannersHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case "GET":
-		banners, err := services.GetBanners()
-		if err != nil {
-			sendError(w, 500, "Failed to get banners")
-			return
-		}
-		sendSuccess(w, banners)
-
-	case "POST":
-		// 验证管理员权限
-		wechatID := r.Header.Get("X-Wechat-ID")
-		isAdmin := false
-		if strings.HasPrefix(wechatID, "admin_") {
-			isAdmin = true
-		} else if wechatID != "" {
-			user, err := services.GetUserByWechatID(wechatID)
-			if err == nil && (user.Role == "super_admin" || user.Role == "admin") {
-				isAdmin = true
-			}
-		}
-
-		if !isAdmin {
-			sendError(w, 403, "Admin only")
-			return
-		}
-
-		var req struct {
-			Banners []services.Banner `json:"banners"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			sendError(w, 400, "Invalid JSON")
-			return
-		}
-
-		if err := services.SaveBanners(req.Banners); err != nil {
-			sendError(w, 500, "Failed to save banners")
-			return
-		}
+	banners, err := services.GetBanners()
+	if err != nil {
+		sendError(w, r, errInternal)
+		return
+	}
+	sendSuccess(w, banners)
+}
 
-		sendSuccess(w, map[string]interface{}{"message": "Banners saved"})
+// 管理员保存轮播图
+func saveBannersHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Banners []services.Banner `json:"banners"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, errInvalidRequestBody)
+		return
+	}
 
-	default:
-		sendError(w, 405, "Method not allowed")
+	before, _ := services.GetBanners()
+	if err := services.SaveBanners(req.Banners); err != nil {
+		sendWriteError(w, r, err)
+		return
 	}
+	recordAudit(r, r.Header.Get("X-Wechat-ID"), services.AuditBannersUpdate, "settings", "banners", before, req.Banners)
+
+	sendSuccess(w, map[string]interface{}{"message": "Banners saved"})
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"zxbe_demo/services"
+)
+
+// setupTestServer 以默认配置、临时数据库和上传目录构建完整路由，测试结束后关闭数据库并恢复全局配置
+func setupTestServer(t *testing.T) http.Handler {
+	t.Helper()
+	oldConfig, oldDB, oldCache := config, services.DB, services.Cache
+	t.Cleanup(func() {
+		if sqlDB, err := services.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+		config, services.DB, services.Cache = oldConfig, oldDB, oldCache
+	})
+
+	dir := t.TempDir()
+	cfg, err := loadConfig([]string{"-db", filepath.Join(dir, "test.db"), "-upload-dir", filepath.Join(dir, "uploads")})
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	config = cfg
+	services.Cache = services.NewCache(100, time.Minute)
+	initStorage()
+	initPublicBaseURL()
+	initValidation()
+	if err := services.InitDB(config.Database.DSN, "error"); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	return newRouter()
+}
+
+// doRequest 发送请求并返回响应记录，headers 为交替的名称和值
+func doRequest(h http.Handler, method, target string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.RemoteAddr = "192.0.2.10:1234"
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// responseCode 返回响应体中的 code，与是否启用 LegacyStatusCodes 无关
+func responseCode(t *testing.T, rec *httptest.ResponseRecorder) int {
+	t.Helper()
+	var resp Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+	return resp.Code
+}
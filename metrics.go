@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"zxbe_demo/services"
+)
+
+// HTTP 和上传指标，与 services 中的数据库、缓存和业务指标一起由 /metrics 输出
+var (
+	httpRequests = services.Metrics.NewCounter("http_requests_total",
+		"HTTP 请求数，按方法、路由和状态码统计", "method", "route", "status")
+	httpRequestDuration = services.Metrics.NewHistogram("http_request_duration_seconds",
+		"HTTP 请求处理耗时（秒），按方法、路由和状态码统计", services.DefBuckets, "method", "route", "status")
+	httpInFlight = services.Metrics.NewGauge("http_requests_in_flight",
+		"正在处理的 HTTP 请求数")
+	uploadBytes = services.Metrics.NewCounter("upload_bytes_total",
+		"成功上传的文件字节数，result 为 stored（新文件）或 deduplicated（重复文件）", "result")
+	uploadFiles = services.Metrics.NewCounter("uploads_total",
+		"成功上传的文件数，result 为 stored（新文件）或 deduplicated（重复文件）", "result")
+)
+
+func init() {
+	services.Metrics.NewGaugeFunc("process_uptime_seconds", "服务已运行时间（秒）", "", func() map[string]float64 {
+		return map[string]float64{"": time.Since(startTime).Seconds()}
+	})
+	services.Metrics.NewGaugeFunc("go_goroutines", "当前 goroutine 数", "", func() map[string]float64 {
+		return map[string]float64{"": float64(runtime.NumGoroutine())}
+	})
+}
+
+// instrumentRequests 统计请求数、耗时和正在处理的请求数
+// 路由标签取注册的路由模式，未匹配任何路由时为 unmatched，避免按原始路径产生大量时间序列
+func instrumentRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpInFlight.Inc()
+		defer httpInFlight.Dec()
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		labels := []string{r.Method, route, strconv.Itoa(status)}
+		httpRequests.Inc(labels...)
+		httpRequestDuration.Observe(time.Since(start).Seconds(), labels...)
+	})
+}
+
+// metricsHandler 以 Prometheus 文本格式输出指标
+// 配置了 server.metrics_token 时需携带 Authorization: Bearer <token>
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if config.Server.MetricsToken != "" && !hasMetricsToken(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := services.Metrics.WriteMetrics(w); err != nil {
+		log.Printf("❌ 输出指标失败: %v", err)
+	}
+}
+
+// hasMetricsToken 请求是否携带了配置的监控令牌，未配置令牌时返回 false
+func hasMetricsToken(r *http.Request) bool {
+	token := config.Server.MetricsToken
+	return token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) == 1
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// middleware 包装 handler 的中间件
+type middleware func(http.Handler) http.Handler
+
+// chain 按顺序组合中间件，第一个中间件在最外层（最先执行）
+func chain(h http.Handler, mws ...middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// cors 设置跨域响应头，并在路由匹配之前直接应答 OPTIONS 预检请求
+func cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setCORSOrigin(w, r)
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// publicURL 为响应附加本次请求的对外访问地址，供 sendResponse 展开上传文件地址
+func publicURL(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(withPublicURL(w, r), r)
+	})
+}
+
+// requireUser 要求请求携带 X-Wechat-ID（微信用户或 admin_ 管理员账号）
+func requireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Wechat-ID") == "" {
+			sendError(w, r, errUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAdmin 要求请求者为管理员（管理员账号或 admin/super_admin 角色的微信用户）
+func requireAdmin(next http.Handler) http.Handler {
+	return requireUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAdmin(r.Header.Get("X-Wechat-ID")) {
+			sendError(w, r, errAdminRequired)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// requireSuperAdmin 要求请求者为超级管理员
+func requireSuperAdmin(next http.Handler) http.Handler {
+	return requireUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if getOperatorRole(r.Header.Get("X-Wechat-ID")) != "super_admin" {
+			sendError(w, r, errSuperAdminRequired)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// rateLimiter 按客户端IP统计每分钟请求数（X-Wechat-ID 可由客户端任意填写，不作为限流依据；
+// 转发头只采信来自可信代理的请求，见 clientIP）
+// 计数保存在内存中，多实例部署时每个实例分别限制
+type rateLimiter struct {
+	perMinute int
+	mu        sync.Mutex
+	hits      map[string][]time.Time
+}
+
+// rateLimit 每个客户端每分钟最多 perMinute 次请求，超出时返回 429 并通过 Retry-After 告知等待秒数
+func rateLimit(perMinute int) middleware {
+	l := &rateLimiter{perMinute: perMinute, hits: map[string][]time.Time{}}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if retryAfter := l.allow(clientIP(r)); retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				sendError(w, r, errRateLimited)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// allow 记录一次请求，未超限时返回 0，否则返回需等待的秒数
+func (l *rateLimiter) allow(key string) int {
+	now := time.Now()
+	windowStart := now.Add(-time.Minute)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// 请求者较多时顺带清理已过窗口的记录
+	if len(l.hits) > 10000 {
+		for k, hits := range l.hits {
+			if len(hits) == 0 || hits[len(hits)-1].Before(windowStart) {
+				delete(l.hits, k)
+			}
+		}
+	}
+
+	hits := l.hits[key]
+	i := 0
+	for i < len(hits) && !hits[i].After(windowStart) {
+		i++
+	}
+	hits = hits[i:]
+
+	if len(hits) >= l.perMinute {
+		l.hits[key] = hits
+		return int(time.Until(hits[0].Add(time.Minute)).Seconds()) + 1
+	}
+	l.hits[key] = append(hits, now)
+	return 0
+}
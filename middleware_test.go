@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// 直连客户端每次伪造不同的 X-Forwarded-For 也不能绕过登录限流
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	h := setupTestServer(t)
+	for i := range loginRateLimit {
+		rec := doRequest(h, http.MethodPost, "/api/admin/login", "X-Forwarded-For", "203.0.113."+strconv.Itoa(i))
+		if responseCode(t, rec) == http.StatusTooManyRequests {
+			t.Fatalf("request %d rate limited before reaching the limit", i+1)
+		}
+	}
+	rec := doRequest(h, http.MethodPost, "/api/admin/login", "X-Forwarded-For", "203.0.113.250", "X-Real-IP", "203.0.113.251")
+	if code := responseCode(t, rec); code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("request over the limit with spoofed headers = %d (Retry-After %q), want 429", code, rec.Header().Get("Retry-After"))
+	}
+}
+
+// 经可信代理转发时按 X-Forwarded-For 中的客户端地址分别限流
+func TestRateLimitPerClientBehindTrustedProxy(t *testing.T) {
+	h := setupTestServer(t)
+	send := func(client string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/feedback", nil)
+		req.RemoteAddr = "127.0.0.1:40000"
+		req.Header.Set("X-Forwarded-For", client)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return responseCode(t, rec)
+	}
+	for range feedbackRateLimit {
+		send("203.0.113.1")
+	}
+	if code := send("203.0.113.1"); code != http.StatusTooManyRequests {
+		t.Errorf("client over the limit = %d, want 429", code)
+	}
+	if code := send("203.0.113.2"); code == http.StatusTooManyRequests {
+		t.Errorf("other client behind the same proxy was rate limited")
+	}
+}
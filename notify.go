@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"zxbe_demo/services"
+)
+
+// 订阅消息发送器（未配置小程序密钥时使用本地假实现）
+var subscribeSender services.SubscribeSender
+
+// initNotify 根据配置设置订阅消息模板和发送器，并启动发件箱投递
+func initNotify(stop <-chan struct{}) {
+	c := config.Wechat
+	services.SetNotifyTemplate(services.EventConsultationAnswered, c.TplConsultationAnswered)
+	services.SetNotifyTemplate(services.EventBookingConfirmed, c.TplBookingConfirmed)
+	services.SetNotifyTemplate(services.EventUrgentHelpNearby, c.TplUrgentHelp)
+
+	if c.AppID != "" && c.AppSecret != "" {
+		subscribeSender = services.NewWechatSubscribeSender(c.AppID, c.AppSecret)
+		log.Println("✅ 订阅消息使用微信接口发送")
+	} else {
+		subscribeSender = &services.FakeSubscribeSender{}
+		log.Println("⚠️  未配置 WECHAT_APPID/WECHAT_APPSECRET，订阅消息使用本地假发送器")
+	}
+
+	services.StartOutboxWorker(subscribeSender, 10*time.Second, stop)
+}
+
+// 查询订阅消息模板及用户授权情况
+func subscribeListHandler(w http.ResponseWriter, r *http.Request) {
+	consents, err := services.GetUserSubscribeConsents(r.Header.Get("X-Wechat-ID"))
+	if err != nil {
+		sendError(w, r, errInternal)
+		return
+	}
+	sendSuccess(w, map[string]interface{}{
+		"templates": services.GetNotifyTemplates(),
+		"consents":  consents,
+	})
+}
+
+// 订阅消息授权处理（小程序调用 wx.requestSubscribeMessage 后上报结果）
+func subscribeHandler(w http.ResponseWriter, r *http.Request) {
+	wechatID := r.Header.Get("X-Wechat-ID")
+
+	// results 格式与 wx.requestSubscribeMessage 回调一致: {"模板ID": "accept"}
+	// latitude/longitude 可选，为用户接收附近紧急求助通知的位置
+	var req struct {
+		Results   map[string]string `json:"results"`
+		Latitude  *float64          `json:"latitude"`
+		Longitude *float64          `json:"longitude"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, r, errInvalidRequestBody)
+		return
+	}
+	if len(req.Results) == 0 {
+		sendErrorData(w, r, errMissingFields, missingFieldsData("results"))
+		return
+	}
+
+	for templateID, status := range req.Results {
+		if err := services.RecordSubscribeConsent(wechatID, templateID, status); err != nil {
+			log.Printf("❌ 记录订阅授权失败: %v", err)
+			sendErrorData(w, r, errInvalidParameter, fieldError("results"))
+			return
+		}
+	}
+	if req.Latitude != nil && req.Longitude != nil {
+		loc := services.SubscribeLocation{Latitude: *req.Latitude, Longitude: *req.Longitude}
+		if err := services.UpdateSubscribeLocation(wechatID, loc); err != nil {
+			sendWriteError(w, r, err)
+			return
+		}
+	}
+	sendSuccess(w, map[string]interface{}{"message": "Subscription recorded"})
+}
+
+// 管理员查看订阅消息发件箱
+func adminNotifyOutboxHandler(w http.ResponseWriter, r *http.Request) {
+	page := 1
+	pageSize := 20
+	if p := r.URL.Query().Get("page"); p != "" {
+		fmt.Sscanf(p, "%d", &page)
+	}
+	if ps := r.URL.Query().Get("page_size"); ps != "" {
+		fmt.Sscanf(ps, "%d", &pageSize)
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	items, total, err := services.GetOutbox(r.URL.Query().Get("status"), page, pageSize)
+	if err != nil {
+		sendError(w, r, errInternal)
+		return
+	}
+
+	sendSuccess(w, map[string]interface{}{
+		"list":      items,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// 管理员重试发送失败的订阅消息
+func adminNotifyOutboxRetryHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	wechatID := r.Header.Get("X-Wechat-ID")
+	if err := services.RetryFailedOutbox(id); err != nil {
+		sendError(w, r, errNotifyMessageNotFound)
+		return
+	}
+	recordAudit(r, wechatID, services.AuditNotifyRetry, "notify_outbox", id, nil, nil)
+	sendSuccess(w, map[string]interface{}{"message": "Requeued"})
+}
+
+// notifyUrgentHelp 紧急求助发布后通知求助位置附近已订阅的用户，求助未带位置时不通知
+func notifyUrgentHelp(h *services.Help) {
+	if h.Urgency != "紧急" && h.Urgency != "urgent" {
+		return
+	}
+	if h.Latitude == 0 && h.Longitude == 0 {
+		log.Printf("⚠️ 紧急求助 %d 未提供位置，不发送附近通知", h.ID)
+		return
+	}
+	count, err := services.BroadcastNearby(services.EventUrgentHelpNearby,
+		fmt.Sprintf("pages/help/detail?id=%d", h.ID),
+		map[string]string{
+			"thing1": truncateRunes(h.Title, 20),
+			"thing2": truncateRunes(h.Location, 20),
+			"time3":  time.Now().Format("2006-01-02 15:04"),
+		}, h.PublisherID, h.Latitude, h.Longitude, float64(config.Wechat.UrgentHelpRadiusKm))
+	if err != nil {
+		log.Printf("❌ 紧急求助通知入队失败: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("📨 紧急求助 %d 已通知附近 %d 位用户", h.ID, count)
+	}
+}
+
+// notifyConsultationAnswered 咨询回复后通知咨询发布者
+func notifyConsultationAnswered(c *services.Consultation) {
+	_, err := services.EnqueueNotification(services.EventConsultationAnswered, c.AuthorID,
+		fmt.Sprintf("pages/consultation/detail?id=%d", c.ID),
+		map[string]string{
+			"thing1": truncateRunes(c.Title, 20),
+			"thing2": truncateRunes(c.Answer, 20),
+			"time3":  time.Now().Format("2006-01-02 15:04"),
+		})
+	if err != nil {
+		log.Printf("❌ 咨询回复通知入队失败: %v", err)
+	}
+}
+
+// truncateRunes 按字符截断（订阅消息 thing 类型限制20个字符）
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
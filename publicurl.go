@@ -0,0 +1,118 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"strings"
+
+	"zxbe_demo/services"
+)
+
+// trustedProxies 可信反向代理地址，只有来自这些地址的请求才采信 X-Forwarded-* 和 X-Real-IP 头
+var trustedProxies []netip.Prefix
+
+// initPublicBaseURL 应用配置的对外访问地址（如 https://zx.example.com）和可信代理（格式已在加载配置时校验）
+// 未配置对外地址时按请求的 X-Forwarded-Proto / X-Forwarded-Host（可信代理）或 Host 推断
+func initPublicBaseURL() {
+	trustedProxies = trustedProxies[:0]
+	for _, p := range config.Server.TrustedProxies {
+		if prefix, err := parseTrustedProxy(p); err == nil {
+			trustedProxies = append(trustedProxies, prefix)
+		}
+	}
+
+	v := config.Server.PublicBaseURL
+	if v == "" {
+		return
+	}
+	services.SetPublicBaseURL(v)
+	log.Printf("🌐 对外访问地址: %s", services.PublicBaseURL)
+}
+
+// parseTrustedProxy 解析单个 IP 或 CIDR
+func parseTrustedProxy(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// fromTrustedProxy 请求是否直接来自可信反向代理
+func fromTrustedProxy(r *http.Request) bool {
+	ap, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	return isTrustedProxy(ap.Addr())
+}
+
+// isTrustedProxy 地址是否属于可信反向代理
+func isTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+var forwardedHostPattern = regexp.MustCompile(`^[A-Za-z0-9.\-]+(:\d+)?$`)
+
+// requestBaseURL 返回本次请求的对外访问地址，用于展开上传文件的相对路径
+func requestBaseURL(r *http.Request) string {
+	if services.PublicBaseURL != "" {
+		return services.PublicBaseURL
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+	if !fromTrustedProxy(r) {
+		// 直连请求的 Host 由客户端任意填写，只用于本次响应，不登记为本服务的主机名
+		return scheme + "://" + host
+	}
+
+	if p := firstHeaderValue(r, "X-Forwarded-Proto"); p == "http" || p == "https" {
+		scheme = p
+	}
+	if h := firstHeaderValue(r, "X-Forwarded-Host"); forwardedHostPattern.MatchString(h) {
+		host = h
+	}
+	services.RegisterUploadHost(host)
+	return scheme + "://" + host
+}
+
+// firstHeaderValue 多级代理时取逗号分隔的第一个值（最靠近客户端的代理写入）
+func firstHeaderValue(r *http.Request, name string) string {
+	v, _, _ := strings.Cut(r.Header.Get(name), ",")
+	return strings.ToLower(strings.TrimSpace(v))
+}
+
+// publicURLWriter 携带本次请求的对外访问地址，sendResponse 据此将上传文件相对路径展开为绝对地址
+type publicURLWriter struct {
+	http.ResponseWriter
+	baseURL string
+}
+
+// Unwrap 供 http.ResponseController 访问底层连接（设置读写超时等）
+func (w *publicURLWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// withPublicURL 为响应附加本次请求的对外访问地址
+func withPublicURL(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	if _, ok := w.(*publicURLWriter); ok {
+		return w
+	}
+	return &publicURLWriter{ResponseWriter: w, baseURL: requestBaseURL(r)}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"zxbe_demo/services"
+)
+
+// initRecycleBin 应用回收站保留天数并启动清理任务
+func initRecycleBin(stop <-chan struct{}) {
+	services.RecycleRetention = time.Duration(config.Content.RecycleRetentionDays) * 24 * time.Hour
+	services.StartPurgeWorker(time.Hour, stop)
+}
+
+// 管理员查看回收站
+func adminRecycleBinHandler(w http.ResponseWriter, r *http.Request) {
+	page := 1
+	pageSize := 20
+	if p := r.URL.Query().Get("page"); p != "" {
+		fmt.Sscanf(p, "%d", &page)
+	}
+	if ps := r.URL.Query().Get("page_size"); ps != "" {
+		fmt.Sscanf(ps, "%d", &pageSize)
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	contentType := r.URL.Query().Get("type")
+	if _, ok := services.NormalizeContentType(contentType); contentType != "" && !ok {
+		sendErrorData(w, r, errInvalidParameter, fieldError("type"))
+		return
+	}
+	items, total, err := services.RecycleBinList(contentType, page, pageSize)
+	if err != nil {
+		log.Printf("❌ 获取回收站失败: %v", err)
+		sendError(w, r, errInternal)
+		return
+	}
+
+	sendSuccess(w, map[string]interface{}{
+		"list":           items,
+		"total":          total,
+		"page":           page,
+		"page_size":      pageSize,
+		"retention_days": int(services.RecycleRetention.Hours() / 24),
+	})
+}
+
+// 管理员从回收站恢复内容
+func adminRecycleBinRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	// /api/admin/recycle-bin/policy/123/restore
+	contentType := r.PathValue("type")
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	wechatID := r.Header.Get("X-Wechat-ID")
+	if err := services.RestoreContent(contentType, id); err != nil {
+		sendError(w, r, errContentNotFound)
+		return
+	}
+
+	log.Printf("♻️ 内容已恢复: %s %d (操作者: %s)", contentType, id, wechatID)
+	recordAudit(r, wechatID, services.AuditContentRestore, contentType, id, nil, nil)
+	sendSuccess(w, map[string]interface{}{"message": "Restored"})
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// 登录和反馈接口每个客户端每分钟的请求次数上限
+const (
+	loginRateLimit    = 10
+	feedbackRateLimit = 5
+)
+
+// newRouter 注册所有路由，路由模式带 HTTP 方法（Go 1.22 ServeMux），方法不匹配时返回 405 和 Allow 头
+// 中间件顺序：请求ID → 指标统计 → 访问日志 → 错误恢复 → 压缩 → ETag/304 → CORS（预检请求不进入路由）→ 对外访问地址 → 路由级的认证和限流
+func newRouter() http.Handler {
+	api := http.NewServeMux()
+	handle := func(pattern string, h http.HandlerFunc, mws ...middleware) {
+		api.Handle(pattern, chain(h, mws...))
+	}
+
+	// 内容模块
+	handle("GET /api/news", newsListHandler)
+	handle("POST /api/news", newsCreateHandler)
+	handle("GET /api/news/latest", latestNewsHandler)
+	handle("GET /api/news/hot", hotNewsHandler)
+	handle("GET /api/news/{id}", newsDetailHandler)
+	handle("GET /api/farmhouse", farmhouseListHandler)
+	handle("POST /api/farmhouse", farmhouseCreateHandler)
+	handle("GET /api/farmhouse/{id}", farmhouseDetailHandler)
+	handle("PUT /api/farmhouse/{id}", farmhouseUpdateHandler)
+	handle("DELETE /api/farmhouse/{id}", farmhouseDeleteHandler, requireUser)
+	handle("GET /api/policy", policyListHandler)
+	handle("POST /api/policy", policyCreateHandler)
+	handle("GET /api/policy/{id}", policyDetailHandler)
+	handle("DELETE /api/policy/{id}", policyDeleteHandler, requireUser)
+	handle("GET /api/tourism", tourismListHandler)
+	handle("POST /api/tourism", tourismCreateHandler)
+	handle("GET /api/tourism/hot", hotTourismHandler)
+	handle("GET /api/tourism/{id}", tourismDetailHandler)
+	handle("DELETE /api/tourism/{id}", tourismDeleteHandler, requireUser)
+	handle("GET /api/jobs", jobsListHandler)
+	handle("POST /api/jobs", jobsCreateHandler)
+	handle("GET /api/jobs/{id}", jobsDetailHandler)
+	handle("DELETE /api/jobs/{id}", jobsDeleteHandler, requireUser)
+	handle("GET /api/help", helpListHandler)
+	handle("POST /api/help", helpCreateHandler)
+	handle("GET /api/help/{id}", helpDetailHandler)
+	handle("DELETE /api/help/{id}", helpDeleteHandler, requireUser)
+	handle("GET /api/consultation", consultationListHandler)
+	handle("POST /api/consultation", consultationCreateHandler)
+	handle("GET /api/consultation/{id}", consultationDetailHandler)
+	handle("DELETE /api/consultation/{id}", consultationDeleteHandler, requireUser)
+	handle("POST /api/admin/consultation/{id}/answer", consultationAnswerHandler, requireAdmin)
+	handle("POST /api/permission/check", checkPermissionHandler)
+	handle("GET /api/settings/banners", bannersHandler)
+	handle("POST /api/settings/banners", saveBannersHandler, requireAdmin)
+
+	// 用户
+	handle("/api/user/login", loginHandler)
+	handle("/api/user/register", registerHandler)
+	handle("POST /api/user/wechat-login", wechatLoginHandler, rateLimit(loginRateLimit))
+	handle("GET /api/user/profile", authRequired(userProfileHandler))
+	handle("PUT /api/user/profile", authRequired(updateUserProfileHandler))
+	handle("POST /api/user/avatar", updateAvatarHandler)
+	handle("POST /api/user/nickname", updateNicknameHandler)
+	handle("GET /api/user/favorite", favoriteListHandler, requireUser)
+	handle("POST /api/user/favorite", addFavoriteHandler, requireUser)
+	handle("DELETE /api/user/favorite", removeFavoriteHandler, requireUser)
+	handle("GET /api/user/history", historyListHandler, requireUser)
+	handle("POST /api/user/history", addHistoryHandler, requireUser)
+	handle("DELETE /api/user/history", clearHistoryHandler, requireUser)
+	handle("GET /api/my-publish/{module}", myPublishHandler, requireUser)
+	handle("POST /api/feedback", feedbackHandler, rateLimit(feedbackRateLimit))
+	handle("GET /api/notify/subscribe", subscribeListHandler, requireUser)
+	handle("POST /api/notify/subscribe", subscribeHandler, requireUser)
+
+	// 文件上传
+	handle("POST /api/upload", uploadHandler)
+	handle("POST /api/upload/chunked", chunkedUploadHandler)
+	handle("GET /api/upload/chunked/{id}", withUploadSession(chunkedUploadStatusHandler))
+	handle("DELETE /api/upload/chunked/{id}", withUploadSession(chunkedUploadAbortHandler))
+	handle("PUT /api/upload/chunked/{id}/parts/{n}", withUploadSession(chunkedUploadPartHandler))
+	handle("POST /api/upload/chunked/{id}/parts/{n}", withUploadSession(chunkedUploadPartHandler))
+	handle("POST /api/upload/chunked/{id}/complete", withUploadSession(chunkedUploadCompleteHandler))
+
+	// 管理后台
+	handle("POST /api/admin/login", adminLoginHandler, rateLimit(loginRateLimit))
+	handle("POST /api/admin/grant-role", adminGrantRoleHandler)
+	handle("GET /api/user/list", userListHandler, requireAdmin)
+	handle("POST /api/user/role", updateRoleHandler, requireUser)
+	handle("GET /api/admin/feedback", adminFeedbackHandler, requireAdmin)
+	handle("POST /api/admin/feedback/{id}/read", adminFeedbackReadHandler, requireAdmin)
+	handle("GET /api/admin/uploads/gc", adminUploadGCHandler, requireAdmin)
+	handle("POST /api/admin/uploads/gc", adminUploadGCHandler, requireAdmin)
+	handle("GET /api/admin/uploads/usage", adminUploadUsageHandler, requireAdmin)
+	handle("GET /api/admin/audit-logs", auditLogsHandler, requireSuperAdmin)
+	handle("GET /api/admin/recycle-bin", adminRecycleBinHandler, requireAdmin)
+	handle("POST /api/admin/recycle-bin/{type}/{id}/restore", adminRecycleBinRestoreHandler, requireAdmin)
+	handle("GET /api/admin/notify/outbox", adminNotifyOutboxHandler, requireAdmin)
+	handle("POST /api/admin/notify/outbox/{id}/retry", adminNotifyOutboxRetryHandler, requireAdmin)
+
+	handle("GET /api/health", healthHandler)
+
+	compress := compressResponses(config.Server.CompressMinBytes)
+	root := http.NewServeMux()
+	root.Handle("/api/", chain(jsonFallback(api), compress, conditionalGET, cors, publicURL))
+	// 静态文件服务 - 提供上传文件的访问（自行处理 CORS、允许的方法和条件请求，图片等不再压缩）
+	root.Handle("/uploads/", uploadsFileHandler())
+	root.Handle("GET /metrics", chain(http.HandlerFunc(metricsHandler), compress))
+	// 存活和就绪探针，供负载均衡和容器编排使用
+	root.HandleFunc("GET /healthz", healthzHandler)
+	root.HandleFunc("GET /readyz", readyzHandler)
+	return chain(jsonFallback(root), withRequestID, instrumentRequests, logRequests, recoverPanics)
+}
+
+// jsonFallback 未匹配任何路由时，将 ServeMux 默认的纯文本 404/405 响应改为统一的 JSON 格式
+// 405 响应保留 ServeMux 生成的 Allow 头，HTTP 状态码与 code 一致
+func jsonFallback(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, pattern := mux.Handler(r)
+		if pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		r.Pattern = "" // 外层路由已记录的前缀模式不代表实际路由
+		rec := &headerRecorder{header: http.Header{}}
+		h.ServeHTTP(rec, r)
+		status := rec.status
+		if status == 0 {
+			status = http.StatusNotFound
+		}
+		apiErr := errNotFound
+		if status == http.StatusMethodNotAllowed {
+			apiErr = errMethodNotAllowed
+			w.Header().Set("Allow", rec.header.Get("Allow"))
+		}
+
+		// 路由错误一直返回真实状态码，不受 server.legacy_status_codes 影响
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(apiErr.Status)
+		json.NewEncoder(w).Encode(errorResponse(r, apiErr, nil))
+	})
+}
+
+// headerRecorder 只记录响应头和状态码，丢弃响应体
+type headerRecorder struct {
+	header http.Header
+	status int
+}
+
+func (rec *headerRecorder) Header() http.Header { return rec.header }
+
+func (rec *headerRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (rec *headerRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+// pathID 解析路由中的 {id}，无效时写入 400 响应并返回 false
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		sendError(w, r, errInvalidID)
+		return 0, false
+	}
+	return id, true
+}
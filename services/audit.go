@@ -0,0 +1,96 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 审计操作类型
+const (
+	AuditRoleUpdate     = "role.update"
+	AuditRoleGrant      = "role.grant"
+	AuditBannersUpdate  = "banners.update"
+	AuditContentDelete  = "content.delete"
+	AuditContentRestore = "content.restore"
+	AuditContentPurge   = "content.purge"
+	AuditNotifyRetry    = "notify.retry"
+	AuditAdminLogin     = "admin.login"
+	AuditUploadsGC      = "uploads.gc"
+)
+
+// AuditLog 管理和破坏性操作的审计记录
+type AuditLog struct {
+	ID         int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	ActorID    string    `gorm:"index" json:"actor_id"` // 操作者（wechat_id 或 admin_xxx，系统任务为 system）
+	ActorRole  string    `json:"actor_role"`
+	Action     string    `gorm:"index" json:"action"`
+	TargetType string    `gorm:"index:idx_audit_target" json:"target_type"`
+	TargetID   string    `gorm:"index:idx_audit_target" json:"target_id"`
+	Before     string    `json:"before"` // 操作前快照JSON
+	After      string    `json:"after"`  // 操作后快照JSON
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+// AuditFilter 审计日志查询条件
+type AuditFilter struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	Since      time.Time
+	Until      time.Time
+}
+
+// AuditSnapshot 将任意数据序列化为快照JSON，nil 返回空字符串
+func AuditSnapshot(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%q", fmt.Sprint(v))
+	}
+	return string(data)
+}
+
+// RecordAudit 写入审计日志
+func RecordAudit(entry *AuditLog) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	return DB.Create(entry).Error
+}
+
+// QueryAuditLogs 按条件分页查询审计日志
+func QueryAuditLogs(f AuditFilter, page, pageSize int) ([]AuditLog, int64, error) {
+	var logs []AuditLog
+	var total int64
+
+	query := DB.Model(&AuditLog{})
+	if f.ActorID != "" {
+		query = query.Where("actor_id = ?", f.ActorID)
+	}
+	if f.Action != "" {
+		query = query.Where("action = ?", f.Action)
+	}
+	if f.TargetType != "" {
+		query = query.Where("target_type = ?", f.TargetType)
+	}
+	if f.TargetID != "" {
+		query = query.Where("target_id = ?", f.TargetID)
+	}
+	if !f.Since.IsZero() {
+		query = query.Where("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		query = query.Where("created_at < ?", f.Until)
+	}
+
+	query.Count(&total)
+
+	offset := (page - 1) * pageSize
+	err := query.Offset(offset).Limit(pageSize).Order("id DESC").Find(&logs).Error
+	return logs, total, err
+}
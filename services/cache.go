@@ -0,0 +1,237 @@
+package services
+
+import (
+	"container/list"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Cache 首页和列表等热点读取使用的内存缓存（LRU + TTL），并发安全
+// 写入内容的服务函数按 key 或标签（内容类型）失效对应条目；同一 key 并发未命中时只加载一次，避免击穿数据库
+var Cache = NewCache(1000, time.Minute)
+
+var (
+	cacheRequests = Metrics.NewCounter("cache_requests_total",
+		"内存缓存查询次数，result 为 hit（命中）、miss（未命中并加载）或 shared（等待其他请求的加载结果）", "result")
+	cacheEvictions = Metrics.NewCounter("cache_evictions_total",
+		"内存缓存因容量上限淘汰的条目数")
+)
+
+func init() {
+	Metrics.NewGaugeFunc("cache_entries", "内存缓存当前条目数", "", func() map[string]float64 {
+		return map[string]float64{"": float64(Cache.Len())}
+	})
+	Metrics.NewGaugeFunc("cache_hit_ratio", "内存缓存命中率（启动以来，shared 计为命中）", "", func() map[string]float64 {
+		hits := cacheRequests.Value("hit") + cacheRequests.Value("shared")
+		total := hits + cacheRequests.Value("miss")
+		if total == 0 {
+			return map[string]float64{"": 0}
+		}
+		return map[string]float64{"": hits / total}
+	})
+}
+
+// errCacheLoadPanicked 加载函数 panic 时返回给等待同一 key 的请求
+var errCacheLoadPanicked = errors.New("cache load panicked")
+
+type cacheEntry struct {
+	key       string
+	value     interface{}
+	tags      []string
+	expiresAt time.Time
+}
+
+// cacheCall 正在进行的加载
+// 加载期间 key 被失效时标记 invalidated 并从 calls 中移除：结果不再写入缓存，之后的请求重新加载
+type cacheCall struct {
+	done        chan struct{}
+	value       interface{}
+	err         error
+	tags        []string
+	invalidated bool
+}
+
+// LRUCache 按最近使用淘汰、按 TTL 过期的缓存，缓存的值视为只读
+type LRUCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	order      *list.List // 最近使用的在前
+	items      map[string]*list.Element
+	tags       map[string]map[string]struct{} // 标签 -> key 集合
+	calls      map[string]*cacheCall
+}
+
+// NewCache 创建缓存，maxEntries 为条目数上限，ttl 为默认有效期
+func NewCache(maxEntries int, ttl time.Duration) *LRUCache {
+	return &LRUCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+		tags:       make(map[string]map[string]struct{}),
+		calls:      make(map[string]*cacheCall),
+	}
+}
+
+// Get 获取未过期的缓存值
+func (c *LRUCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.get(key)
+	if ok {
+		cacheRequests.Inc("hit")
+	} else {
+		cacheRequests.Inc("miss")
+	}
+	return v, ok
+}
+
+// Set 写入缓存，ttl 为 0 时使用默认有效期；tags 用于按内容类型批量失效
+func (c *LRUCache) Set(key string, value interface{}, ttl time.Duration, tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(key, value, ttl, tags)
+}
+
+// GetOrLoad 命中时直接返回，否则调用 load 加载并写入缓存（出错时不缓存）
+// 同一 key 同时只有一个请求执行 load，其他请求等待并共享结果
+func (c *LRUCache) GetOrLoad(key string, ttl time.Duration, tags []string, load func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	if v, ok := c.get(key); ok {
+		c.mu.Unlock()
+		cacheRequests.Inc("hit")
+		return v, nil
+	}
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		cacheRequests.Inc("shared")
+		<-call.done
+		return call.value, call.err
+	}
+	call := &cacheCall{done: make(chan struct{}), tags: tags}
+	c.calls[key] = call
+	c.mu.Unlock()
+	cacheRequests.Inc("miss")
+
+	defer func() {
+		c.mu.Lock()
+		if c.calls[key] == call {
+			delete(c.calls, key)
+		}
+		if call.err == nil && !call.invalidated {
+			c.set(key, call.value, ttl, tags)
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	call.err = errCacheLoadPanicked
+	call.value, call.err = load()
+	return call.value, call.err
+}
+
+// Invalidate 删除指定 key
+func (c *LRUCache) Invalidate(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.remove(el)
+		}
+		if call, ok := c.calls[key]; ok {
+			call.invalidated = true
+			delete(c.calls, key)
+		}
+	}
+}
+
+// InvalidateTag 删除带有指定标签的所有条目
+func (c *LRUCache) InvalidateTag(tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			c.remove(c.items[key])
+		}
+		for key, call := range c.calls {
+			if slices.Contains(call.tags, tag) {
+				call.invalidated = true
+				delete(c.calls, key)
+			}
+		}
+	}
+}
+
+// Len 返回当前条目数（含尚未清理的过期条目）
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+func (c *LRUCache) get(key string) (interface{}, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if time.Now().After(e.expiresAt) {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+func (c *LRUCache) set(key string, value interface{}, ttl time.Duration, tags []string) {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	e := &cacheEntry{key: key, value: value, tags: tags, expiresAt: time.Now().Add(ttl)}
+	c.items[key] = c.order.PushFront(e)
+	for _, tag := range tags {
+		if c.tags[tag] == nil {
+			c.tags[tag] = make(map[string]struct{})
+		}
+		c.tags[tag][key] = struct{}{}
+	}
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+		cacheEvictions.Inc()
+	}
+}
+
+func (c *LRUCache) remove(el *list.Element) {
+	e := c.order.Remove(el).(*cacheEntry)
+	delete(c.items, e.key)
+	for _, tag := range e.tags {
+		delete(c.tags[tag], e.key)
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
+
+// cachedList 通过缓存加载列表，返回副本，调用方可以修改元素（如填充收藏状态）而不影响缓存
+func cachedList[T any](key string, tags []string, load func() ([]T, error)) ([]T, error) {
+	v, err := Cache.GetOrLoad(key, 0, tags, func() (interface{}, error) {
+		return load()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(v.([]T)), nil
+}
+
+// invalidateContent 写入成功时失效该内容类型的所有缓存条目，原样返回 err
+func invalidateContent(contentType string, err error) error {
+	if err == nil {
+		Cache.InvalidateTag(contentType)
+	}
+	return err
+}
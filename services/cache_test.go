@@ -0,0 +1,308 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		max     int
+		ttl     time.Duration
+		run     func(c *LRUCache)
+		present []string
+		absent  []string
+	}{
+		{
+			name: "evicts least recently used",
+			max:  2,
+			run: func(c *LRUCache) {
+				c.Set("a", 1, 0)
+				c.Set("b", 2, 0)
+				c.Get("a") // a 变为最近使用
+				c.Set("c", 3, 0)
+			},
+			present: []string{"a", "c"},
+			absent:  []string{"b"},
+		},
+		{
+			name: "overwrite does not evict",
+			max:  2,
+			run: func(c *LRUCache) {
+				c.Set("a", 1, 0)
+				c.Set("b", 2, 0)
+				c.Set("a", 10, 0)
+			},
+			present: []string{"a", "b"},
+		},
+		{
+			name: "per-entry ttl expires",
+			max:  10,
+			run: func(c *LRUCache) {
+				c.Set("short", 1, 10*time.Millisecond)
+				c.Set("long", 2, time.Hour)
+				time.Sleep(30 * time.Millisecond)
+			},
+			present: []string{"long"},
+			absent:  []string{"short"},
+		},
+		{
+			name: "default ttl expires",
+			max:  10,
+			ttl:  10 * time.Millisecond,
+			run: func(c *LRUCache) {
+				c.Set("a", 1, 0)
+				time.Sleep(30 * time.Millisecond)
+			},
+			absent: []string{"a"},
+		},
+		{
+			name: "invalidate tag",
+			max:  10,
+			run: func(c *LRUCache) {
+				c.Set("news:latest", 1, 0, "news")
+				c.Set("news:hot", 2, 0, "news")
+				c.Set("home", 3, 0, "news", "jobs")
+				c.Set("jobs:list", 4, 0, "jobs")
+				c.InvalidateTag("news")
+			},
+			present: []string{"jobs:list"},
+			absent:  []string{"news:latest", "news:hot", "home"},
+		},
+		{
+			name: "invalidate key",
+			max:  10,
+			run: func(c *LRUCache) {
+				c.Set("a", 1, 0, "news")
+				c.Set("b", 2, 0, "news")
+				c.Invalidate("a", "missing")
+			},
+			present: []string{"b"},
+			absent:  []string{"a"},
+		},
+		{
+			name: "evicted entry leaves its tags",
+			max:  1,
+			run: func(c *LRUCache) {
+				c.Set("a", 1, 0, "news")
+				c.Set("b", 2, 0, "jobs")
+				c.InvalidateTag("news") // a 已被淘汰，不能误删 b
+			},
+			present: []string{"b"},
+			absent:  []string{"a"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ttl := tc.ttl
+			if ttl == 0 {
+				ttl = time.Hour
+			}
+			c := NewCache(tc.max, ttl)
+			tc.run(c)
+			for _, key := range tc.present {
+				if _, ok := c.Get(key); !ok {
+					t.Errorf("%s missing", key)
+				}
+			}
+			for _, key := range tc.absent {
+				if v, ok := c.Get(key); ok {
+					t.Errorf("%s = %v, want absent", key, v)
+				}
+			}
+			if c.Len() != len(tc.present) {
+				t.Errorf("Len = %d, want %d", c.Len(), len(tc.present))
+			}
+		})
+	}
+}
+
+func TestGetOrLoad(t *testing.T) {
+	c := NewCache(10, time.Hour)
+	loads := 0
+	load := func() (interface{}, error) {
+		loads++
+		return loads, nil
+	}
+	for range 3 {
+		if v, err := c.GetOrLoad("k", 0, nil, load); err != nil || v != 1 {
+			t.Fatalf("GetOrLoad = %v, %v; want 1 from the first load", v, err)
+		}
+	}
+
+	// 加载出错时不缓存
+	fail := errors.New("db down")
+	if _, err := c.GetOrLoad("e", 0, nil, func() (interface{}, error) { return nil, fail }); !errors.Is(err, fail) {
+		t.Fatalf("err = %v, want %v", err, fail)
+	}
+	if _, ok := c.Get("e"); ok {
+		t.Errorf("failed load was cached")
+	}
+
+	// 加载 panic 时不缓存，panic 继续向上传递
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("panic in load was swallowed")
+			}
+		}()
+		c.GetOrLoad("p", 0, nil, func() (interface{}, error) { panic("boom") })
+	}()
+	if _, ok := c.Get("p"); ok {
+		t.Errorf("panicked load was cached")
+	}
+	if v, err := c.GetOrLoad("p", 0, nil, func() (interface{}, error) { return "ok", nil }); err != nil || v != "ok" {
+		t.Errorf("GetOrLoad after panic = %v, %v", v, err)
+	}
+}
+
+// waitShared 等待 n 个请求加入正在进行的加载
+func waitShared(t *testing.T, before float64, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for cacheRequests.Value("shared") < before+float64(n) {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %v of %d requests joined the in-flight load", cacheRequests.Value("shared")-before, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// 同一 key 并发未命中时只加载一次，所有请求得到同一结果（配合 -race 运行）
+func TestGetOrLoadConcurrentSameKey(t *testing.T) {
+	c := NewCache(10, time.Hour)
+	const n = 50
+	var loads atomic.Int32
+	release := make(chan struct{})
+	shared := cacheRequests.Value("shared")
+
+	results := make([]interface{}, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetOrLoad("k", 0, []string{"news"}, func() (interface{}, error) {
+				loads.Add(1)
+				<-release
+				return []int{1, 2, 3}, nil
+			})
+			if err != nil {
+				t.Errorf("GetOrLoad: %v", err)
+			}
+			results[i] = v
+		}()
+	}
+	waitShared(t, shared, n-1)
+	close(release)
+	wg.Wait()
+
+	if got := loads.Load(); got != 1 {
+		t.Errorf("load called %d times, want 1", got)
+	}
+	for i, v := range results {
+		if fmt.Sprint(v) != "[1 2 3]" {
+			t.Errorf("result %d = %v", i, v)
+		}
+	}
+}
+
+// 等待中的请求共享加载错误，错误不缓存
+func TestGetOrLoadSharesError(t *testing.T) {
+	c := NewCache(10, time.Hour)
+	fail := errors.New("db down")
+	release := make(chan struct{})
+	shared := cacheRequests.Value("shared")
+
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := c.GetOrLoad("k", 0, nil, func() (interface{}, error) {
+				<-release
+				return nil, fail
+			})
+			errs <- err
+		}()
+	}
+	waitShared(t, shared, 1)
+	close(release)
+	for range 2 {
+		if err := <-errs; !errors.Is(err, fail) {
+			t.Errorf("err = %v, want %v", err, fail)
+		}
+	}
+	if c.Len() != 0 {
+		t.Errorf("Len = %d, want 0", c.Len())
+	}
+}
+
+// 加载期间内容被修改（标签失效）时，旧结果不写入缓存，之后的请求重新加载
+func TestGetOrLoadInvalidatedDuringLoad(t *testing.T) {
+	c := NewCache(10, time.Hour)
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan interface{})
+	go func() {
+		v, _ := c.GetOrLoad("news:latest", 0, []string{"news"}, func() (interface{}, error) {
+			close(started)
+			<-release
+			return "stale", nil
+		})
+		done <- v
+	}()
+	<-started
+	c.InvalidateTag("news")
+
+	// 失效后的请求不等待旧的加载
+	if v, err := c.GetOrLoad("news:latest", 0, []string{"news"}, func() (interface{}, error) { return "fresh", nil }); err != nil || v != "fresh" {
+		t.Errorf("GetOrLoad after invalidation = %v, %v; want fresh", v, err)
+	}
+	close(release)
+	if v := <-done; v != "stale" {
+		t.Errorf("in-flight load returned %v", v)
+	}
+	if v, _ := c.Get("news:latest"); v != "fresh" {
+		t.Errorf("cached = %v, want fresh (stale result must not overwrite)", v)
+	}
+}
+
+// cachedList 返回副本，调用方修改元素不影响缓存
+func TestCachedListReturnsCopy(t *testing.T) {
+	old := Cache
+	Cache = NewCache(10, time.Hour)
+	t.Cleanup(func() { Cache = old })
+
+	loads := 0
+	load := func() ([]News, error) {
+		loads++
+		return []News{{ID: 1, Title: "a"}, {ID: 2, Title: "b"}}, nil
+	}
+	first, err := cachedList("news:latest", []string{"news"}, load)
+	if err != nil {
+		t.Fatalf("cachedList: %v", err)
+	}
+	first[0].IsBookmarked = true
+	first[1].Title = "changed"
+	first = append(first[:1], News{ID: 3})
+
+	second, err := cachedList("news:latest", []string{"news"}, load)
+	if err != nil {
+		t.Fatalf("cachedList: %v", err)
+	}
+	if loads != 1 {
+		t.Errorf("load called %d times, want 1", loads)
+	}
+	if len(second) != 2 || second[0].IsBookmarked || second[1].Title != "b" {
+		t.Errorf("cached list modified through returned slice: %+v", second)
+	}
+
+	if err := invalidateContent("news", nil); err != nil {
+		t.Fatalf("invalidateContent: %v", err)
+	}
+	if _, err := cachedList("news:latest", []string{"news"}, load); err != nil || loads != 2 {
+		t.Errorf("after invalidateContent loads = %d (%v), want 2", loads, err)
+	}
+}
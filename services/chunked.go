@@ -0,0 +1,316 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 分片上传：初始化会话 -> 逐个上传分片（可断点续传）-> 合并并校验整体哈希
+// 分片保存在上传文件存储的 .chunks/<会话ID>/ 下，多实例部署时任一实例都能合并
+var (
+	ChunkedUploadTTL      = 24 * time.Hour   // 会话最后一次活动后的有效期
+	DefaultChunkSize      = int64(2 << 20)   // 默认分片大小 2MB，弱网下单片失败重传代价小
+	MinChunkSize          = int64(256 << 10) // 客户端可指定的最小分片
+	MaxChunkSize          = int64(10 << 20)  // 客户端可指定的最大分片
+	MaxChunkedUploadSize  = int64(200 << 20) // 分片上传的单文件上限
+	MaxUploadFileSize     = int64(10 << 20)  // 普通（非分片）上传的单文件上限，更大的文件使用分片上传
+	MaxOpenUploadSessions = 5                // 每个用户同时进行中的分片上传会话数，0 表示不限制
+)
+
+// 分片上传错误
+var (
+	ErrInvalidUploadSession  = errors.New("invalid upload session parameters")
+	ErrUploadSessionNotFound = errors.New("upload session not found or expired")
+	ErrInvalidPartNumber     = errors.New("invalid part number")
+	ErrPartSizeMismatch      = errors.New("part size mismatch")
+	ErrPartChecksumMismatch  = errors.New("part checksum mismatch")
+	ErrUploadIncomplete      = errors.New("upload incomplete")
+	ErrTooManyUploadSessions = errors.New("too many open upload sessions")
+)
+
+const uploadChunksDir = ".chunks"
+
+// UploadSession 分片上传会话
+type UploadSession struct {
+	ID          string    `gorm:"primaryKey;size:32" json:"upload_id"`
+	UploaderID  string    `gorm:"index" json:"-"`
+	FileName    string    `json:"file_name"`
+	Size        int64     `json:"size"`
+	ChunkSize   int64     `json:"chunk_size"`
+	TotalChunks int       `json:"total_chunks"`
+	SHA256      string    `json:"sha256"`           // 客户端声明的整体哈希，合并后校验
+	QuotaDay    string    `gorm:"size:10" json:"-"` // 初始化时按声明大小占用配额的日期，取消或过期时按该日期归还
+	ExpiresAt   time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// UploadSessionPart 已接收的分片
+type UploadSessionPart struct {
+	SessionID  string    `gorm:"primaryKey;size:32" json:"-"`
+	PartNumber int       `gorm:"primaryKey;autoIncrement:false" json:"part_number"`
+	Size       int64     `json:"size"`
+	SHA256     string    `json:"sha256"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CreateUploadSession 初始化分片上传会话，chunkSize 为 0 时使用默认分片大小
+// 按声明的 size 占用当天上传配额（超出时返回 *QuotaError），进行中的会话数超出上限时返回 ErrTooManyUploadSessions
+func CreateUploadSession(uploaderID, role, fileName string, size, chunkSize int64, sha string) (*UploadSession, error) {
+	sha = strings.ToLower(strings.TrimSpace(sha))
+	if b, err := hex.DecodeString(sha); err != nil || len(b) != sha256.Size {
+		return nil, fmt.Errorf("%w: sha256 must be a hex-encoded SHA-256 digest", ErrInvalidUploadSession)
+	}
+	if size <= 0 || size > MaxChunkedUploadSize {
+		return nil, fmt.Errorf("%w: size must be between 1 and %d bytes", ErrInvalidUploadSession, MaxChunkedUploadSize)
+	}
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkSize < MinChunkSize || chunkSize > MaxChunkSize {
+		return nil, fmt.Errorf("%w: chunk_size must be between %d and %d bytes", ErrInvalidUploadSession, MinChunkSize, MaxChunkSize)
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	s := &UploadSession{
+		ID:          hex.EncodeToString(id),
+		UploaderID:  uploaderID,
+		FileName:    fileName,
+		Size:        size,
+		ChunkSize:   chunkSize,
+		TotalChunks: int((size + chunkSize - 1) / chunkSize),
+		SHA256:      sha,
+		ExpiresAt:   now.Add(ChunkedUploadTTL),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if MaxOpenUploadSessions > 0 {
+			var open int64
+			if err := tx.Model(&UploadSession{}).Where("uploader_id = ? AND expires_at > ?", uploaderID, now).Count(&open).Error; err != nil {
+				return err
+			}
+			if open >= int64(MaxOpenUploadSessions) {
+				return ErrTooManyUploadSessions
+			}
+		}
+		day, err := reserveUploadQuota(tx, uploaderID, role, size)
+		if err != nil {
+			return err
+		}
+		s.QuotaDay = day
+		return tx.Create(s).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetUploadSession 获取未过期的上传会话
+func GetUploadSession(id string) (*UploadSession, error) {
+	var s UploadSession
+	err := DB.Where("id = ? AND expires_at > ?", id, time.Now()).First(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUploadSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// PartSize 第 n 个分片（从 1 开始）应有的大小，最后一片可能较小
+func (s *UploadSession) PartSize(n int) int64 {
+	if n < s.TotalChunks {
+		return s.ChunkSize
+	}
+	return s.Size - s.ChunkSize*int64(s.TotalChunks-1)
+}
+
+func chunkKey(sessionID string, n int) string {
+	return uploadChunksDir + "/" + sessionID + "/" + strconv.Itoa(n)
+}
+
+// SaveUploadPart 保存一个分片，重复上传同一分片会覆盖；expectedSHA 非空时校验分片哈希
+// 每次成功上传都会延长会话有效期，便于长时间断线后续传
+func SaveUploadPart(s *UploadSession, n int, r io.Reader, expectedSHA string) (*UploadSessionPart, error) {
+	if n < 1 || n > s.TotalChunks {
+		return nil, ErrInvalidPartNumber
+	}
+	want := s.PartSize(n)
+
+	// 多读一个字节以判断分片是否超长
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, io.LimitReader(r, want+1)); err != nil {
+		return nil, err
+	}
+	if int64(buf.Len()) != want {
+		return nil, fmt.Errorf("%w: got %d bytes, want %d", ErrPartSizeMismatch, buf.Len(), want)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	partSHA := hex.EncodeToString(sum[:])
+	if expectedSHA != "" && !strings.EqualFold(expectedSHA, partSHA) {
+		return nil, ErrPartChecksumMismatch
+	}
+
+	if err := UploadStorage.Put(chunkKey(s.ID, n), &buf, want, "application/octet-stream"); err != nil {
+		return nil, err
+	}
+
+	part := &UploadSessionPart{SessionID: s.ID, PartNumber: n, Size: want, SHA256: partSHA, CreatedAt: time.Now()}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(part).Error; err != nil {
+			return err
+		}
+		return tx.Model(s).Updates(map[string]interface{}{
+			"expires_at": time.Now().Add(ChunkedUploadTTL),
+			"updated_at": time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return part, nil
+}
+
+// UploadSessionParts 返回已接收的分片（按序号排列）
+func UploadSessionParts(sessionID string) ([]UploadSessionPart, error) {
+	var parts []UploadSessionPart
+	err := DB.Where("session_id = ?", sessionID).Order("part_number").Find(&parts).Error
+	return parts, err
+}
+
+// MissingParts 返回尚未上传的分片序号
+func (s *UploadSession) MissingParts(parts []UploadSessionPart) []int {
+	have := make(map[int]bool, len(parts))
+	for _, p := range parts {
+		have[p.PartNumber] = true
+	}
+	missing := []int{}
+	for n := 1; n <= s.TotalChunks; n++ {
+		if !have[n] {
+			missing = append(missing, n)
+		}
+	}
+	return missing
+}
+
+// AssembleUpload 按顺序合并所有分片写入 dst，返回合并后内容的 SHA-256
+// 调用方需将结果与 s.SHA256 比较
+func AssembleUpload(s *UploadSession, dst io.Writer) (string, error) {
+	parts, err := UploadSessionParts(s.ID)
+	if err != nil {
+		return "", err
+	}
+	if missing := s.MissingParts(parts); len(missing) > 0 {
+		return "", fmt.Errorf("%w: missing parts %v", ErrUploadIncomplete, missing)
+	}
+
+	h := sha256.New()
+	w := io.MultiWriter(dst, h)
+	for n := 1; n <= s.TotalChunks; n++ {
+		r, _, err := UploadStorage.Get(chunkKey(s.ID, n))
+		if err != nil {
+			if errors.Is(err, ErrObjectNotFound) {
+				return "", fmt.Errorf("%w: part %d lost", ErrUploadIncomplete, n)
+			}
+			return "", err
+		}
+		_, err = io.Copy(w, r)
+		r.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ReleaseUploadSessionQuota 归还会话初始化时占用的配额
+func ReleaseUploadSessionQuota(s *UploadSession) error {
+	return releaseUploadQuota(s.UploaderID, s.QuotaDay, 1, s.Size)
+}
+
+// SettleUploadSessionQuota 合并完成后按实际保存的大小（清理元数据后可能变化）调整会话占用的配额，并更新 s.Size
+func SettleUploadSessionQuota(s *UploadSession, size int64) error {
+	if err := releaseUploadQuota(s.UploaderID, s.QuotaDay, 0, s.Size-size); err != nil {
+		return err
+	}
+	s.Size = size
+	return nil
+}
+
+// AbortUploadSession 取消上传：删除会话及其分片并归还占用的配额
+func AbortUploadSession(s *UploadSession) error {
+	if err := DeleteUploadSession(s); err != nil {
+		return err
+	}
+	return ReleaseUploadSessionQuota(s)
+}
+
+// DeleteUploadSession 删除会话及其分片，占用的配额不归还（合并完成后转为文件的配额）
+func DeleteUploadSession(s *UploadSession) error {
+	for n := 1; n <= s.TotalChunks; n++ {
+		if err := UploadStorage.Delete(chunkKey(s.ID, n)); err != nil {
+			return err
+		}
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id = ?", s.ID).Delete(&UploadSessionPart{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(s).Error
+	})
+}
+
+// PurgeExpiredUploadSessions 清理过期的上传会话并归还占用的配额，返回清理数量
+func PurgeExpiredUploadSessions() (int, error) {
+	var expired []UploadSession
+	if err := DB.Where("expires_at <= ?", time.Now()).Find(&expired).Error; err != nil {
+		return 0, err
+	}
+	for i := range expired {
+		if err := AbortUploadSession(&expired[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(expired), nil
+}
+
+// StartUploadSessionWorker 定期清理过期的分片上传会话，关闭 stop 通道即可停止
+func StartUploadSessionWorker(interval time.Duration, stop <-chan struct{}) {
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if n, err := PurgeExpiredUploadSessions(); err != nil {
+					slog.Error("purge expired upload sessions failed", "error", err)
+				} else if n > 0 {
+					slog.Info("expired upload sessions purged", "count", n)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSHA = strings.Repeat("ab", 32)
+
+func uploadUsageBytes(t *testing.T, uploaderID string) (int64, int) {
+	t.Helper()
+	usage, err := GetUploadUsage(uploaderID)
+	if err != nil {
+		t.Fatalf("GetUploadUsage: %v", err)
+	}
+	return usage.Bytes, usage.Files
+}
+
+// 初始化时按声明大小占用配额，取消和过期时归还
+func TestUploadSessionReservesQuota(t *testing.T) {
+	setupTestDB(t)
+
+	s, err := CreateUploadSession("u1", "user", "a.pdf", 30<<20, 0, testSHA)
+	if err != nil {
+		t.Fatalf("CreateUploadSession: %v", err)
+	}
+	if b, f := uploadUsageBytes(t, "u1"); b != 30<<20 || f != 1 {
+		t.Fatalf("usage after initiate = %d bytes, %d files; want %d, 1", b, f, 30<<20)
+	}
+
+	// 配额 50MB，再声明 30MB 超额
+	var qerr *QuotaError
+	if _, err := CreateUploadSession("u1", "user", "b.pdf", 30<<20, 0, testSHA); !errors.As(err, &qerr) || qerr.Limit != QuotaDailyBytes {
+		t.Fatalf("second initiate err = %v, want daily_bytes QuotaError", err)
+	}
+	var n int64
+	DB.Model(&UploadSession{}).Count(&n)
+	if n != 1 {
+		t.Errorf("sessions = %d, want 1 (rejected session not created)", n)
+	}
+
+	if err := AbortUploadSession(s); err != nil {
+		t.Fatalf("AbortUploadSession: %v", err)
+	}
+	if b, f := uploadUsageBytes(t, "u1"); b != 0 || f != 0 {
+		t.Errorf("usage after abort = %d bytes, %d files; want 0", b, f)
+	}
+
+	s, err = CreateUploadSession("u1", "user", "c.pdf", 20<<20, 0, testSHA)
+	if err != nil {
+		t.Fatalf("CreateUploadSession: %v", err)
+	}
+	DB.Model(s).Update("expires_at", time.Now().Add(-time.Minute))
+	if purged, err := PurgeExpiredUploadSessions(); err != nil || purged != 1 {
+		t.Fatalf("PurgeExpiredUploadSessions = %d, %v; want 1", purged, err)
+	}
+	if b, f := uploadUsageBytes(t, "u1"); b != 0 || f != 0 {
+		t.Errorf("usage after expiry = %d bytes, %d files; want 0", b, f)
+	}
+}
+
+// 合并完成后按实际大小调整，之后保存失败时归还调整后的配额
+func TestSettleUploadSessionQuota(t *testing.T) {
+	setupTestDB(t)
+	s, err := CreateUploadSession("u1", "user", "a.jpg", 1000, MinChunkSize, testSHA)
+	if err != nil {
+		t.Fatalf("CreateUploadSession: %v", err)
+	}
+	if err := SettleUploadSessionQuota(s, 800); err != nil {
+		t.Fatalf("SettleUploadSessionQuota: %v", err)
+	}
+	if b, f := uploadUsageBytes(t, "u1"); b != 800 || f != 1 {
+		t.Errorf("usage after settle = %d bytes, %d files; want 800, 1", b, f)
+	}
+	if err := ReleaseUploadSessionQuota(s); err != nil {
+		t.Fatalf("ReleaseUploadSessionQuota: %v", err)
+	}
+	if b, f := uploadUsageBytes(t, "u1"); b != 0 || f != 0 {
+		t.Errorf("usage after release = %d bytes, %d files; want 0", b, f)
+	}
+}
+
+func TestUploadSessionLimitPerUser(t *testing.T) {
+	setupTestDB(t)
+	old := MaxOpenUploadSessions
+	MaxOpenUploadSessions = 2
+	t.Cleanup(func() { MaxOpenUploadSessions = old })
+
+	var sessions []*UploadSession
+	for range 2 {
+		s, err := CreateUploadSession("admin_a", "admin", "a.pdf", 1000, 0, testSHA)
+		if err != nil {
+			t.Fatalf("CreateUploadSession: %v", err)
+		}
+		sessions = append(sessions, s)
+	}
+	if _, err := CreateUploadSession("admin_a", "admin", "a.pdf", 1000, 0, testSHA); !errors.Is(err, ErrTooManyUploadSessions) {
+		t.Fatalf("third session err = %v, want ErrTooManyUploadSessions", err)
+	}
+	if _, err := CreateUploadSession("admin_b", "admin", "a.pdf", 1000, 0, testSHA); err != nil {
+		t.Errorf("other user's session: %v", err)
+	}
+
+	// 过期未清理的会话不计入
+	DB.Model(sessions[0]).Update("expires_at", time.Now().Add(-time.Minute))
+	if _, err := CreateUploadSession("admin_a", "admin", "a.pdf", 1000, 0, testSHA); err != nil {
+		t.Errorf("session after one expired: %v", err)
+	}
+}
@@ -5,11 +5,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 	_ "modernc.org/sqlite"
 )
 
@@ -17,151 +22,197 @@ var DB *gorm.DB
 
 // GORM 模型
 type News struct {
-	ID          int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	Title       string    `json:"title"`
-	Category    string    `json:"category"`
-	PublishTime string    `json:"publish_time"`
-	Author      string    `json:"author"`
-	Summary     string    `json:"summary"`
-	Content     string    `json:"content"`
-	Image       string    `json:"image"`
-	ViewCount   int       `json:"view_count"`
-	LikeCount   int       `json:"like_count"`
-	Tags        string    `json:"tags"`
-	IsHot       bool      `json:"is_hot"`
-	PublisherID string    `json:"publisher_id"`
-	CreatedAt   time.Time `json:"-"`
+	ID            int            `gorm:"primaryKey;autoIncrement" json:"id"`
+	Title         string         `json:"title" validate:"required,max=100"`
+	Category      string         `json:"category" validate:"required,max=20,enum=news_category"`
+	PublishTime   string         `json:"publish_time"`
+	Author        string         `json:"author" validate:"max=50"`
+	Summary       string         `json:"summary" validate:"max=500"`
+	Content       string         `json:"content" validate:"max=20000"`
+	Image         string         `json:"image" validate:"max=500,url"`
+	ViewCount     int            `json:"view_count"`
+	LikeCount     int            `json:"like_count"`
+	Tags          string         `json:"tags" validate:"max=200"`
+	IsHot         bool           `json:"is_hot"`
+	PublisherID   string         `json:"publisher_id" validate:"required,max=64"`
+	CreatedAt     time.Time      `json:"-"`
+	UpdatedAt     time.Time      `json:"updated_at"`             // 内容修改时间
+	IsBookmarked  bool           `gorm:"-" json:"is_bookmarked"` // 当前用户是否已收藏
+	FavoriteCount int            `gorm:"-" json:"favorite_count"`
+	Thumbnail     string         `gorm:"-" json:"thumbnail"` // 列表缩略图地址
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	DeletedBy     string         `json:"-"` // 删除操作者ID
 }
 
 type Farmhouse struct {
-	ID           int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	Title        string    `json:"title"`
-	Address      string    `json:"address"`
-	Description  string    `json:"description"`
-	Image        string    `json:"image"`
-	Images       string    `json:"images"` // 多张图片，逗号分隔
-	PublishTime  string    `json:"publish_time"`
-	Author       string    `json:"author"`
-	AuthorAvatar string    `json:"author_avatar"`
-	PublisherID  string    `json:"publisher_id"` // 发布者ID（wechat_id或admin_username）
-	Phone        string    `json:"phone"`
-	Price        string    `json:"price"`
-	Rating       float64   `json:"rating"`
-	ReviewCount  int       `json:"review_count"`
-	ViewCount    int       `json:"view_count"`
-	Facilities   string    `json:"facilities"` // 服务设施，逗号分隔
-	Features     string    `json:"features"`   // 特色亮点，逗号分隔
-	OpenTime     string    `json:"open_time"`  // 营业时间
-	IsBookmarked bool      `json:"is_bookmarked"`
-	CreatedAt    time.Time `json:"-"`
+	ID            int            `gorm:"primaryKey;autoIncrement" json:"id"`
+	Title         string         `json:"title" validate:"required,max=100"`
+	Address       string         `json:"address" validate:"max=200"`
+	Description   string         `json:"description" validate:"max=5000"`
+	Image         string         `json:"image" validate:"max=500,url"`
+	Images        string         `json:"images" validate:"max=5000,urls"` // 多张图片，逗号分隔
+	PublishTime   string         `json:"publish_time"`
+	Author        string         `json:"author" validate:"max=50"`
+	AuthorAvatar  string         `json:"author_avatar" validate:"max=500,url"`
+	PublisherID   string         `json:"publisher_id" validate:"max=64"` // 发布者ID（wechat_id或admin_username）
+	Phone         string         `json:"phone" validate:"phone"`
+	Price         string         `json:"price" validate:"max=50"`
+	Rating        float64        `json:"rating" validate:"min=0,max=5"`
+	ReviewCount   int            `json:"review_count" validate:"min=0"`
+	ViewCount     int            `json:"view_count"`
+	Facilities    string         `json:"facilities" validate:"max=500"` // 服务设施，逗号分隔
+	Features      string         `json:"features" validate:"max=500"`   // 特色亮点，逗号分隔
+	OpenTime      string         `json:"open_time" validate:"max=100"`  // 营业时间
+	CreatedAt     time.Time      `json:"-"`
+	UpdatedAt     time.Time      `json:"updated_at"`             // 内容修改时间
+	IsBookmarked  bool           `gorm:"-" json:"is_bookmarked"` // 当前用户是否已收藏
+	FavoriteCount int            `gorm:"-" json:"favorite_count"`
+	Thumbnail     string         `gorm:"-" json:"thumbnail"` // 列表缩略图地址
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	DeletedBy     string         `json:"-"` // 删除操作者ID
 }
 
 type Policy struct {
-	ID          int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	Title       string    `json:"title"`
-	Category    string    `json:"category"`
-	Department  string    `json:"department"`
-	Author      string    `json:"author"`
-	PublisherID string    `json:"publisher_id"` // 发布者ID
-	Content     string    `json:"content"`
-	Summary     string    `json:"summary"`
-	Image       string    `json:"image"`
-	Images      string    `json:"images"`      // 多张图片，逗号分隔
-	Attachments string    `json:"attachments"` // 附件JSON数组
-	Tags        string    `json:"tags"`
-	IsImportant bool      `json:"is_important"`
-	ReadCount   int       `json:"read_count"`
-	PublishTime string    `json:"publish_time"`
-	CreatedAt   time.Time `json:"-"`
+	ID            int            `gorm:"primaryKey;autoIncrement" json:"id"`
+	Title         string         `json:"title" validate:"required,max=100"`
+	Category      string         `json:"category" validate:"max=20,enum=policy_category"`
+	Department    string         `json:"department" validate:"max=100"`
+	Author        string         `json:"author" validate:"max=50"`
+	PublisherID   string         `json:"publisher_id" validate:"max=64"` // 发布者ID
+	Content       string         `json:"content" validate:"max=20000"`
+	Summary       string         `json:"summary" validate:"max=500"`
+	Image         string         `json:"image" validate:"max=500,url"`
+	Images        string         `json:"images" validate:"max=5000,urls"`  // 多张图片，逗号分隔
+	Attachments   string         `json:"attachments" validate:"max=10000"` // 附件JSON数组
+	Tags          string         `json:"tags" validate:"max=200"`
+	IsImportant   bool           `json:"is_important"`
+	ReadCount     int            `json:"read_count"`
+	PublishTime   string         `json:"publish_time"`
+	CreatedAt     time.Time      `json:"-"`
+	UpdatedAt     time.Time      `json:"updated_at"`             // 内容修改时间
+	IsBookmarked  bool           `gorm:"-" json:"is_bookmarked"` // 当前用户是否已收藏
+	FavoriteCount int            `gorm:"-" json:"favorite_count"`
+	Thumbnail     string         `gorm:"-" json:"thumbnail"` // 列表缩略图地址
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	DeletedBy     string         `json:"-"` // 删除操作者ID
 }
 
 type Tourism struct {
-	ID              int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name            string    `json:"name"`
-	Category        string    `json:"category"`
-	Location        string    `json:"location"`
-	Address         string    `json:"address"`
-	Latitude        float64   `json:"latitude"`
-	Longitude       float64   `json:"longitude"`
-	Phone           string    `json:"phone"`
-	Rating          float64   `json:"rating"`
-	ReviewCount     int       `json:"review_count"`
-	Price           int       `json:"price"`
-	PriceUnit       string    `json:"price_unit"`
-	Distance        string    `json:"distance"`
-	OpenTime        string    `json:"open_time"`
-	Tags            string    `json:"tags"`
-	Image           string    `json:"image"`
-	Images          string    `json:"images"`
-	Description     string    `json:"description"`
-	IsHot           bool      `json:"is_hot"`
-	ViewCount       int       `json:"view_count"`
-	PublisherID     string    `json:"publisher_id"`     // 发布者微信ID
-	PublisherName   string    `json:"publisher_name"`   // 发布者昵称
-	PublisherAvatar string    `json:"publisher_avatar"` // 发布者头像
-	CreatedAt       time.Time `json:"-"`
+	ID              int            `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name            string         `json:"name" validate:"required,max=100"`
+	Category        string         `json:"category" validate:"max=20,enum=tourism_category"`
+	Location        string         `json:"location" validate:"max=100"`
+	Address         string         `json:"address" validate:"max=200"`
+	Latitude        float64        `json:"latitude" validate:"min=-90,max=90"`
+	Longitude       float64        `json:"longitude" validate:"min=-180,max=180"`
+	Phone           string         `json:"phone" validate:"phone"`
+	Rating          float64        `json:"rating" validate:"min=0,max=5"`
+	ReviewCount     int            `json:"review_count" validate:"min=0"`
+	Price           int            `json:"price" validate:"min=0,max=1000000"`
+	PriceUnit       string         `json:"price_unit" validate:"max=20"`
+	Distance        string         `json:"distance" validate:"max=50"`
+	OpenTime        string         `json:"open_time" validate:"max=100"`
+	Tags            string         `json:"tags" validate:"max=200"`
+	Image           string         `json:"image" validate:"max=500,url"`
+	Images          string         `json:"images" validate:"max=5000,urls"`
+	Description     string         `json:"description" validate:"max=5000"`
+	IsHot           bool           `json:"is_hot"`
+	ViewCount       int            `json:"view_count"`
+	PublisherID     string         `json:"publisher_id" validate:"max=64"`          // 发布者微信ID
+	PublisherName   string         `json:"publisher_name" validate:"max=50"`        // 发布者昵称
+	PublisherAvatar string         `json:"publisher_avatar" validate:"max=500,url"` // 发布者头像
+	CreatedAt       time.Time      `json:"-"`
+	UpdatedAt       time.Time      `json:"updated_at"`             // 内容修改时间
+	IsBookmarked    bool           `gorm:"-" json:"is_bookmarked"` // 当前用户是否已收藏
+	FavoriteCount   int            `gorm:"-" json:"favorite_count"`
+	Thumbnail       string         `gorm:"-" json:"thumbnail"` // 列表缩略图地址
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+	DeletedBy       string         `json:"-"` // 删除操作者ID
 }
 
 type Job struct {
-	ID               int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	Title            string    `json:"title"`
-	Company          string    `json:"company"`
-	Location         string    `json:"location"`
-	Salary           string    `json:"salary"`
-	Experience       string    `json:"experience"`
-	Education        string    `json:"education"`
-	JobType          string    `json:"job_type"`
-	PublishTime      string    `json:"publish_time"`
-	Tags             string    `json:"tags"`
-	Logo             string    `json:"logo"`
-	Description      string    `json:"description"`
-	Requirements     string    `json:"requirements"`
-	Responsibilities string    `json:"responsibilities"`
-	IsUrgent         bool      `json:"is_urgent"`
-	ViewCount        int       `json:"view_count"`
-	ApplicantCount   int       `json:"applicant_count"`
-	PublisherID      string    `json:"publisher_id"`     // 发布者微信ID
-	PublisherName    string    `json:"publisher_name"`   // 发布者昵称
-	PublisherAvatar  string    `json:"publisher_avatar"` // 发布者头像
-	CreatedAt        time.Time `json:"-"`
+	ID               int            `gorm:"primaryKey;autoIncrement" json:"id"`
+	Title            string         `json:"title" validate:"required,max=100"`
+	Company          string         `json:"company" validate:"max=100"`
+	Location         string         `json:"location" validate:"max=100"`
+	Salary           string         `json:"salary" validate:"max=50"`
+	Experience       string         `json:"experience" validate:"max=50"`
+	Education        string         `json:"education" validate:"max=50"`
+	JobType          string         `json:"job_type" validate:"enum=job_type"`
+	PublishTime      string         `json:"publish_time"`
+	Tags             string         `json:"tags" validate:"max=200"`
+	Logo             string         `json:"logo" validate:"max=500,url"`
+	Description      string         `json:"description" validate:"max=5000"`
+	Requirements     string         `json:"requirements" validate:"max=5000"`
+	Responsibilities string         `json:"responsibilities" validate:"max=5000"`
+	IsUrgent         bool           `json:"is_urgent"`
+	ViewCount        int            `json:"view_count"`
+	ApplicantCount   int            `json:"applicant_count"`
+	PublisherID      string         `json:"publisher_id" validate:"max=64"`          // 发布者微信ID
+	PublisherName    string         `json:"publisher_name" validate:"max=50"`        // 发布者昵称
+	PublisherAvatar  string         `json:"publisher_avatar" validate:"max=500,url"` // 发布者头像
+	CreatedAt        time.Time      `json:"-"`
+	UpdatedAt        time.Time      `json:"updated_at"`             // 内容修改时间
+	IsBookmarked     bool           `gorm:"-" json:"is_bookmarked"` // 当前用户是否已收藏
+	FavoriteCount    int            `gorm:"-" json:"favorite_count"`
+	Thumbnail        string         `gorm:"-" json:"thumbnail"` // 列表缩略图地址
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+	DeletedBy        string         `json:"-"` // 删除操作者ID
 }
 
 type Help struct {
-	ID          int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	Title       string    `json:"title"`
-	Category    string    `json:"category"`
-	Location    string    `json:"location"`
-	Urgency     string    `json:"urgency"`
-	PublishTime string    `json:"publish_time"`
-	Author      string    `json:"author"`
-	PublisherID string    `json:"publisher_id"` // 发布者ID
-	Phone       string    `json:"phone"`
-	Description string    `json:"description"`
-	Reward      string    `json:"reward"`
-	Image       string    `json:"image"`
-	Images      string    `json:"images"`
-	ViewCount   int       `json:"view_count"`
-	HelpCount   int       `json:"help_count"`
-	Tags        string    `json:"tags"`
-	Status      string    `json:"status"`
-	CreatedAt   time.Time `json:"-"`
+	ID            int            `gorm:"primaryKey;autoIncrement" json:"id"`
+	Title         string         `json:"title" validate:"required,max=100"`
+	Category      string         `json:"category" validate:"max=20,enum=help_category"`
+	Location      string         `json:"location" validate:"max=100"`
+	Urgency       string         `json:"urgency" validate:"enum=help_urgency"`
+	Latitude      float64        `json:"latitude" validate:"min=-90,max=90"` // 求助位置，紧急求助据此通知附近的用户
+	Longitude     float64        `json:"longitude" validate:"min=-180,max=180"`
+	PublishTime   string         `json:"publish_time"`
+	Author        string         `json:"author" validate:"max=50"`
+	PublisherID   string         `json:"publisher_id" validate:"max=64"` // 发布者ID
+	Phone         string         `json:"phone" validate:"phone"`
+	Description   string         `json:"description" validate:"max=5000"`
+	Reward        string         `json:"reward" validate:"max=100"`
+	Image         string         `json:"image" validate:"max=500,url"`
+	Images        string         `json:"images" validate:"max=5000,urls"`
+	ViewCount     int            `json:"view_count"`
+	HelpCount     int            `json:"help_count"`
+	Tags          string         `json:"tags" validate:"max=200"`
+	Status        string         `json:"status"`
+	CreatedAt     time.Time      `json:"-"`
+	UpdatedAt     time.Time      `json:"updated_at"`             // 内容修改时间
+	IsBookmarked  bool           `gorm:"-" json:"is_bookmarked"` // 当前用户是否已收藏
+	FavoriteCount int            `gorm:"-" json:"favorite_count"`
+	Thumbnail     string         `gorm:"-" json:"thumbnail"` // 列表缩略图地址
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	DeletedBy     string         `json:"-"` // 删除操作者ID
 }
 
 // Consultation 乡村咨询
 type Consultation struct {
-	ID          int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	Title       string    `json:"title"`
-	Content     string    `json:"content"`
-	Category    string    `json:"category"` // 政策咨询、技术咨询、市场咨询等
-	Author      string    `json:"author"`
-	AuthorID    string    `json:"author_id"` // 管理员username或用户wechat_id
-	Avatar      string    `json:"avatar"`
-	Images      string    `json:"images"`
-	ViewCount   int       `json:"view_count"`
-	ReplyCount  int       `json:"reply_count"`
-	Status      string    `json:"status"` // 待回复、已回复、已解决
-	PublishTime string    `json:"publish_time"`
-	CreatedAt   time.Time `json:"-"`
+	ID            int            `gorm:"primaryKey;autoIncrement" json:"id"`
+	Title         string         `json:"title" validate:"required,max=100"`
+	Content       string         `json:"content" validate:"required,max=5000"`
+	Category      string         `json:"category" validate:"enum=consultation_category"` // 政策咨询、技术咨询、市场咨询等
+	Author        string         `json:"author" validate:"max=50"`
+	AuthorID      string         `json:"author_id" validate:"required,max=64"` // 管理员username或用户wechat_id
+	Avatar        string         `json:"avatar" validate:"max=500,url"`
+	Images        string         `json:"images" validate:"max=5000,urls"`
+	ViewCount     int            `json:"view_count"`
+	ReplyCount    int            `json:"reply_count"`
+	Status        string         `json:"status" validate:"enum=consultation_status"` // 待回复、已回复、已解决
+	Answer        string         `json:"answer"`                                     // 管理员的回复
+	AnsweredBy    string         `json:"answered_by"`
+	AnsweredAt    *time.Time     `json:"answered_at"`
+	PublishTime   string         `json:"publish_time"`
+	CreatedAt     time.Time      `json:"-"`
+	UpdatedAt     time.Time      `json:"updated_at"`             // 内容修改时间
+	IsBookmarked  bool           `gorm:"-" json:"is_bookmarked"` // 当前用户是否已收藏
+	FavoriteCount int            `gorm:"-" json:"favorite_count"`
+	Thumbnail     string         `gorm:"-" json:"thumbnail"` // 列表缩略图地址
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	DeletedBy     string         `json:"-"` // 删除操作者ID
 }
 
 type User struct {
@@ -173,7 +224,7 @@ type User struct {
 	Phone        string    `json:"phone"`
 	Email        string    `json:"email"`
 	Role         string    `gorm:"default:'user'" json:"role"` // super_admin, admin, vip, user, banned
-	Favorites    string    `json:"favorites"`                  // JSON数组存储收藏的内容ID
+	Favorites    string    `json:"-"`                          // 旧版JSON收藏，已迁移到 Favorite 表
 	PublishedIDs string    `json:"published_ids"`              // JSON数组存储发布的内容ID
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
@@ -194,9 +245,9 @@ type Admin struct {
 // History 浏览历史
 type History struct {
 	ID       int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID   string    `gorm:"index" json:"user_id"` // wechat_id
-	ItemType string    `json:"type"`                 // tourism, farmhouse, jobs, help, policy
-	ItemID   int       `json:"item_id"`
+	UserID   string    `gorm:"index:idx_history_user_item" json:"user_id"` // wechat_id
+	ItemType string    `gorm:"index:idx_history_user_item" json:"type"`    // tourism, farmhouse, jobs, help, policy
+	ItemID   int       `gorm:"index:idx_history_user_item" json:"item_id"`
 	Title    string    `json:"title"`
 	Image    string    `json:"image"`
 	ViewTime time.Time `json:"view_time"`
@@ -205,21 +256,24 @@ type History struct {
 // Feedback 意见反馈
 type Feedback struct {
 	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	Type      string    `json:"type"` // 功能建议、问题反馈、内容投诉、其他
-	Content   string    `json:"content"`
-	Contact   string    `json:"contact"`
-	UserID    string    `json:"user_id"`
-	Nickname  string    `json:"nickname"`
+	Type      string    `json:"type" validate:"enum=feedback_type"` // 功能建议、问题反馈、内容投诉、其他
+	Content   string    `json:"content" validate:"required,max=1000"`
+	Contact   string    `json:"contact" validate:"max=100"`
+	UserID    string    `json:"user_id" validate:"max=64"`
+	Nickname  string    `json:"nickname" validate:"max=50"`
 	Status    string    `gorm:"default:'unread'" json:"status"` // unread, read
 	CreatedAt time.Time `json:"created_at"`
 }
 
 // Banner 轮播图
 type Banner struct {
-	URL   string `json:"url"`
-	Title string `json:"title"`
+	URL   string `json:"url" validate:"required,max=500,url"`
+	Title string `json:"title" validate:"max=50"`
 }
 
+// MaxBanners 轮播图最多张数
+const MaxBanners = 10
+
 // Settings 系统设置
 type Settings struct {
 	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
@@ -228,18 +282,39 @@ type Settings struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// InitDB 初始化 sqlite 数据库
-func InitDB() error {
+// newDBLogger 按日志级别配置 GORM 日志：debug 打印所有 SQL，其余级别只记录慢查询和错误（不含记录不存在）
+func newDBLogger(level string) logger.Interface {
+	dbLevel := logger.Warn
+	switch level {
+	case "debug":
+		dbLevel = logger.Info
+	case "error":
+		dbLevel = logger.Error
+	}
+	return logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+		SlowThreshold:             200 * time.Millisecond,
+		LogLevel:                  dbLevel,
+		IgnoreRecordNotFoundError: level != "debug",
+		Colorful:                  true,
+	})
+}
+
+// InitDB 初始化 sqlite 数据库，logLevel 为 debug 时打印所有 SQL
+func InitDB(dsn, logLevel string) error {
 	var err error
 	// 使用纯Go SQLite驱动 (modernc.org/sqlite) - 增强并发处理
 	DB, err = gorm.Open(sqlite.Dialector{
 		DriverName: "sqlite",
-		DSN:        "./zxbe_new.db?_busy_timeout=10000&_journal_mode=WAL&_synchronous=NORMAL&_cache_size=1000&_foreign_keys=1",
-	}, &gorm.Config{})
+		DSN:        dsn,
+	}, &gorm.Config{Logger: newDBLogger(logLevel)})
 	if err != nil {
 		return err
 	}
 
+	if err := instrumentDB(DB); err != nil {
+		return err
+	}
+
 	// 获取底层数据库连接并配置连接池
 	sqlDB, err := DB.DB()
 	if err != nil {
@@ -251,10 +326,14 @@ func InitDB() error {
 	sqlDB.SetMaxOpenConns(1)    // 最大打开连接数，SQLite建议为1
 	sqlDB.SetConnMaxLifetime(0) // 连接最大生存时间
 	// 自动迁移
-	err = DB.AutoMigrate(&News{}, &Farmhouse{}, &Policy{}, &Tourism{}, &Job{}, &Help{}, &Consultation{}, &User{}, &Admin{}, &History{}, &Feedback{}, &Settings{})
+	err = DB.AutoMigrate(&News{}, &Farmhouse{}, &Policy{}, &Tourism{}, &Job{}, &Help{}, &Consultation{}, &User{}, &Admin{}, &History{}, &Feedback{}, &Settings{}, &SubscribeConsent{}, &NotifyOutbox{}, &AuditLog{}, &Favorite{}, &UploadFile{}, &QuarantinedUpload{}, &UploadSession{}, &UploadSessionPart{}, &UploadUsage{})
 	if err != nil {
 		return err
 	}
+	// 数据迁移
+	if err := runMigrations(); err != nil {
+		return err
+	}
 	// 种子数据（如果表为空）
 	var cnt int64
 	DB.Model(&News{}).Count(&cnt)
@@ -270,13 +349,21 @@ func seedData() {
 }
 
 // ---------------- News ----------------
+// NewsList 资讯列表，不带关键词时（首页和分类浏览）走缓存
 func NewsList(keyword, category string) ([]News, error) {
+	if keyword == "" {
+		return cachedList("news:list:"+category, []string{"news"}, func() ([]News, error) { return newsList("", category) })
+	}
+	return newsList(keyword, category)
+}
+
+func newsList(keyword, category string) ([]News, error) {
 	var list []News
 
 	// 防止SQL注入和数据库查询错误
 	defer func() {
 		if r := recover(); r != nil {
-			fmt.Printf("❌ NewsList panic recovered: %v\n", r)
+			slog.Error("NewsList panic recovered", "panic", r)
 		}
 	}()
 
@@ -300,7 +387,7 @@ func NewsList(keyword, category string) ([]News, error) {
 	}
 
 	if err := q.Order("id desc").Find(&list).Error; err != nil {
-		fmt.Printf("❌ NewsList database error: %v\n", err)
+		slog.Error("NewsList database error", "error", err)
 		return []News{}, err // 返回空数组而不是nil，防止前端处理错误
 	}
 
@@ -312,6 +399,24 @@ func NewsList(keyword, category string) ([]News, error) {
 	return list, nil
 }
 
+// LatestNews 最新发布的 count 条资讯（走缓存）
+func LatestNews(count int) ([]News, error) {
+	return cachedList(fmt.Sprintf("news:latest:%d", count), []string{"news"}, func() ([]News, error) {
+		var list []News
+		err := DB.Order("id desc").Limit(count).Find(&list).Error
+		return list, err
+	})
+}
+
+// HotNews 热门资讯：标记为热门的在前，其余按浏览量排序（走缓存，浏览量最多延迟一个缓存有效期）
+func HotNews(count int) ([]News, error) {
+	return cachedList(fmt.Sprintf("news:hot:%d", count), []string{"news"}, func() ([]News, error) {
+		var list []News
+		err := DB.Order("is_hot desc, view_count desc, id desc").Limit(count).Find(&list).Error
+		return list, err
+	})
+}
+
 func NewsGetByID(id int) (*News, error) {
 	var n News
 	if err := DB.First(&n, id).Error; err != nil {
@@ -321,6 +426,9 @@ func NewsGetByID(id int) (*News, error) {
 }
 
 func CreateNews(n *News) error {
+	if err := Validate(n); err != nil {
+		return err
+	}
 	n.PublishTime = time.Now().Format("2006-01-02")
 	n.CreatedAt = time.Now()
 
@@ -329,11 +437,12 @@ func CreateNews(n *News) error {
 	for i := 0; i < maxRetries; i++ {
 		err := DB.Create(n).Error
 		if err == nil {
+			Cache.InvalidateTag("news")
 			return nil
 		}
 
 		if strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "SQLITE_BUSY") {
-			fmt.Printf("⚠️ Database busy, retrying... (attempt %d/%d)\n", i+1, maxRetries)
+			slog.Warn("database busy, retrying", "attempt", i+1, "max_attempts", maxRetries)
 			time.Sleep(time.Duration(100*(i+1)) * time.Millisecond)
 			continue
 		}
@@ -345,23 +454,19 @@ func CreateNews(n *News) error {
 }
 
 func UpdateNews(id int, n *News) error {
+	if err := Validate(n); err != nil {
+		return err
+	}
 	var existing News
 	if err := DB.First(&existing, id).Error; err != nil {
 		return err
 	}
 	n.ID = id
-	return DB.Save(n).Error
+	return invalidateContent("news", DB.Save(n).Error)
 }
 
-func DeleteNews(id int) error {
-	res := DB.Delete(&News{}, id)
-	if res.Error != nil {
-		return res.Error
-	}
-	if res.RowsAffected == 0 {
-		return errors.New("not found")
-	}
-	return nil
+func DeleteNews(id int, deletedBy string) error {
+	return softDelete("news", id, deletedBy)
 }
 
 func IncrementNewsView(id int) error {
@@ -369,7 +474,15 @@ func IncrementNewsView(id int) error {
 }
 
 // ---------------- Farmhouse ----------------
+// FarmhouseList 农家乐列表，不带关键词时走缓存
 func FarmhouseList(keyword string) ([]Farmhouse, error) {
+	if keyword == "" {
+		return cachedList("farmhouse:list", []string{"farmhouse"}, func() ([]Farmhouse, error) { return farmhouseList("") })
+	}
+	return farmhouseList(keyword)
+}
+
+func farmhouseList(keyword string) ([]Farmhouse, error) {
 	var list []Farmhouse
 	q := DB.Model(&Farmhouse{})
 	if keyword != "" {
@@ -391,39 +504,46 @@ func FarmhouseGetByID(id int) (*Farmhouse, error) {
 }
 
 func FarmhouseCreate(f *Farmhouse) error {
+	if err := Validate(f); err != nil {
+		return err
+	}
 	f.PublishTime = time.Now().Format("2006-01-02")
 	f.CreatedAt = time.Now()
-	return DB.Create(f).Error
+	return invalidateContent("farmhouse", DB.Create(f).Error)
 }
 
 func FarmhouseUpdate(id int, f *Farmhouse) error {
+	if err := Validate(f); err != nil {
+		return err
+	}
 	var existing Farmhouse
 	if err := DB.First(&existing, id).Error; err != nil {
 		return err
 	}
 	f.ID = id
-	return DB.Save(f).Error
+	return invalidateContent("farmhouse", DB.Save(f).Error)
 }
 
-func FarmhouseDelete(id int) error {
-	res := DB.Delete(&Farmhouse{}, id)
-	if res.Error != nil {
-		return res.Error
-	}
-	if res.RowsAffected == 0 {
-		return errors.New("not found")
-	}
-	return nil
+func FarmhouseDelete(id int, deletedBy string) error {
+	return softDelete("farmhouse", id, deletedBy)
 }
 
 // ---------------- Policy ----------------
+// PolicyList 政策列表，不带关键词时走缓存
 func PolicyList(keyword, category string) ([]Policy, error) {
+	if keyword == "" {
+		return cachedList("policy:list:"+category, []string{"policy"}, func() ([]Policy, error) { return policyList("", category) })
+	}
+	return policyList(keyword, category)
+}
+
+func policyList(keyword, category string) ([]Policy, error) {
 	var list []Policy
 
 	// 防止数据库查询错误
 	defer func() {
 		if r := recover(); r != nil {
-			fmt.Printf("❌ PolicyList panic recovered: %v\n", r)
+			slog.Error("PolicyList panic recovered", "panic", r)
 		}
 	}()
 
@@ -445,7 +565,7 @@ func PolicyList(keyword, category string) ([]Policy, error) {
 	}
 
 	if err := q.Order("id desc").Find(&list).Error; err != nil {
-		fmt.Printf("❌ PolicyList database error: %v\n", err)
+		slog.Error("PolicyList database error", "error", err)
 		return []Policy{}, err
 	}
 
@@ -465,6 +585,9 @@ func PolicyGetByID(id int) (*Policy, error) {
 }
 
 func PolicyCreate(p *Policy) error {
+	if err := Validate(p); err != nil {
+		return err
+	}
 	p.PublishTime = time.Now().Format("2006-01-02")
 	p.CreatedAt = time.Now()
 
@@ -473,12 +596,13 @@ func PolicyCreate(p *Policy) error {
 	for i := 0; i < maxRetries; i++ {
 		err := DB.Create(p).Error
 		if err == nil {
+			Cache.InvalidateTag("policy")
 			return nil
 		}
 
 		// 如果是数据库忙碌错误，等待后重试
 		if strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "SQLITE_BUSY") {
-			fmt.Printf("⚠️ Database busy, retrying... (attempt %d/%d)\n", i+1, maxRetries)
+			slog.Warn("database busy, retrying", "attempt", i+1, "max_attempts", maxRetries)
 			time.Sleep(time.Duration(100*(i+1)) * time.Millisecond) // 递增等待时间
 			continue
 		}
@@ -494,19 +618,20 @@ func IncrementPolicyRead(id int) error {
 	return DB.Model(&Policy{}).Where("id = ?", id).UpdateColumn("read_count", gorm.Expr("read_count + ?", 1)).Error
 }
 
-func PolicyDelete(id int) error {
-	res := DB.Delete(&Policy{}, id)
-	if res.Error != nil {
-		return res.Error
-	}
-	if res.RowsAffected == 0 {
-		return errors.New("not found")
-	}
-	return nil
+func PolicyDelete(id int, deletedBy string) error {
+	return softDelete("policy", id, deletedBy)
 }
 
 // ---------------- Tourism ----------------
+// TourismList 景区列表，不带关键词时走缓存
 func TourismList(keyword, category string) ([]Tourism, error) {
+	if keyword == "" {
+		return cachedList("tourism:list:"+category, []string{"tourism"}, func() ([]Tourism, error) { return tourismList("", category) })
+	}
+	return tourismList(keyword, category)
+}
+
+func tourismList(keyword, category string) ([]Tourism, error) {
 	var list []Tourism
 	q := DB.Model(&Tourism{})
 	if keyword != "" {
@@ -522,6 +647,15 @@ func TourismList(keyword, category string) ([]Tourism, error) {
 	return list, nil
 }
 
+// HotTourism 热门景区：标记为热门的在前，其余按浏览量排序（走缓存）
+func HotTourism(count int) ([]Tourism, error) {
+	return cachedList(fmt.Sprintf("tourism:hot:%d", count), []string{"tourism"}, func() ([]Tourism, error) {
+		var list []Tourism
+		err := DB.Order("is_hot desc, view_count desc, id desc").Limit(count).Find(&list).Error
+		return list, err
+	})
+}
+
 func TourismGetByID(id int) (*Tourism, error) {
 	var t Tourism
 	if err := DB.First(&t, id).Error; err != nil {
@@ -531,27 +665,31 @@ func TourismGetByID(id int) (*Tourism, error) {
 }
 
 func TourismCreate(t *Tourism) error {
+	if err := Validate(t); err != nil {
+		return err
+	}
 	t.CreatedAt = time.Now()
-	return DB.Create(t).Error
+	return invalidateContent("tourism", DB.Create(t).Error)
 }
 
 func IncrementTourismView(id int) error {
 	return DB.Model(&Tourism{}).Where("id = ?", id).UpdateColumn("view_count", gorm.Expr("view_count + ?", 1)).Error
 }
 
-func TourismDelete(id int) error {
-	res := DB.Delete(&Tourism{}, id)
-	if res.Error != nil {
-		return res.Error
-	}
-	if res.RowsAffected == 0 {
-		return errors.New("not found")
-	}
-	return nil
+func TourismDelete(id int, deletedBy string) error {
+	return softDelete("tourism", id, deletedBy)
 }
 
 // ---------------- Jobs ----------------
+// JobsList 招聘列表，不带关键词时（按地区浏览）走缓存
 func JobsList(keyword, location string) ([]Job, error) {
+	if keyword == "" {
+		return cachedList("jobs:list:"+location, []string{"jobs"}, func() ([]Job, error) { return jobsList("", location) })
+	}
+	return jobsList(keyword, location)
+}
+
+func jobsList(keyword, location string) ([]Job, error) {
 	var list []Job
 	q := DB.Model(&Job{})
 	if keyword != "" {
@@ -577,28 +715,32 @@ func JobsGetByID(id int) (*Job, error) {
 }
 
 func JobsCreate(j *Job) error {
+	if err := Validate(j); err != nil {
+		return err
+	}
 	j.PublishTime = time.Now().Format("2006-01-02")
 	j.CreatedAt = time.Now()
-	return DB.Create(j).Error
+	return invalidateContent("jobs", DB.Create(j).Error)
 }
 
 func IncrementJobView(id int) error {
 	return DB.Model(&Job{}).Where("id = ?", id).UpdateColumn("view_count", gorm.Expr("view_count + ?", 1)).Error
 }
 
-func JobDelete(id int) error {
-	res := DB.Delete(&Job{}, id)
-	if res.Error != nil {
-		return res.Error
-	}
-	if res.RowsAffected == 0 {
-		return errors.New("not found")
-	}
-	return nil
+func JobDelete(id int, deletedBy string) error {
+	return softDelete("jobs", id, deletedBy)
 }
 
 // ---------------- Help ----------------
+// HelpList 求助列表，不带关键词时走缓存
 func HelpList(keyword, category, urgency string) ([]Help, error) {
+	if keyword == "" {
+		return cachedList("help:list:"+category+":"+urgency, []string{"help"}, func() ([]Help, error) { return helpList("", category, urgency) })
+	}
+	return helpList(keyword, category, urgency)
+}
+
+func helpList(keyword, category, urgency string) ([]Help, error) {
 	var list []Help
 	q := DB.Model(&Help{})
 	if keyword != "" {
@@ -626,25 +768,21 @@ func HelpGetByID(id int) (*Help, error) {
 }
 
 func HelpCreate(h *Help) error {
+	if err := Validate(h); err != nil {
+		return err
+	}
 	h.PublishTime = time.Now().Format("2006-01-02")
 	h.Status = "求助中"
 	h.CreatedAt = time.Now()
-	return DB.Create(h).Error
+	return invalidateContent("help", DB.Create(h).Error)
 }
 
 func IncrementHelpView(id int) error {
 	return DB.Model(&Help{}).Where("id = ?", id).UpdateColumn("view_count", gorm.Expr("view_count + ?", 1)).Error
 }
 
-func HelpDelete(id int) error {
-	res := DB.Delete(&Help{}, id)
-	if res.Error != nil {
-		return res.Error
-	}
-	if res.RowsAffected == 0 {
-		return errors.New("not found")
-	}
-	return nil
+func HelpDelete(id int, deletedBy string) error {
+	return softDelete("help", id, deletedBy)
 }
 
 // ---------------- User ----------------
@@ -659,24 +797,58 @@ func GetUserProfileByID(id int) (*User, error) {
 	return &u, nil
 }
 
-func UpdateUserProfile(id int, payload map[string]interface{}) error {
-	return DB.Model(&User{}).Where("id = ?", id).Updates(payload).Error
+// UserProfileUpdate 用户可自行修改的资料，nil 表示不修改；角色等其他字段只能通过管理员接口修改
+type UserProfileUpdate struct {
+	Nickname *string `json:"nickname" validate:"max=50"`
+	Avatar   *string `json:"avatar" validate:"max=500,url"`
+	Phone    *string `json:"phone" validate:"phone"`
+	Email    *string `json:"email" validate:"max=100,email"`
+}
+
+// UpdateUserProfile 校验并更新用户资料中提交的字段
+func UpdateUserProfile(id int, p UserProfileUpdate) error {
+	if err := Validate(&p); err != nil {
+		return err
+	}
+	updates := map[string]interface{}{}
+	if p.Nickname != nil {
+		updates["nickname"] = *p.Nickname
+	}
+	if p.Avatar != nil {
+		updates["avatar"] = RelativizeUploadURLs(*p.Avatar)
+	}
+	if p.Phone != nil {
+		updates["phone"] = *p.Phone
+	}
+	if p.Email != nil {
+		updates["email"] = *p.Email
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return DB.Model(&User{}).Where("id = ?", id).Updates(updates).Error
 }
 
-// UpdateUserAvatar 更新用户头像
+// UpdateUserAvatar 校验并更新用户头像
 func UpdateUserAvatar(wechatID, avatar string) error {
-	return DB.Model(&User{}).Where("wechat_id = ?", wechatID).Update("avatar", avatar).Error
+	if err := Validate(&UserProfileUpdate{Avatar: &avatar}); err != nil {
+		return err
+	}
+	return DB.Model(&User{}).Where("wechat_id = ?", wechatID).Update("avatar", RelativizeUploadURLs(avatar)).Error
 }
 
-// UpdateUserNickname 更新用户昵称
+// UpdateUserNickname 校验并更新用户昵称
 func UpdateUserNickname(wechatID, nickname string) error {
+	if err := Validate(&UserProfileUpdate{Nickname: &nickname}); err != nil {
+		return err
+	}
 	return DB.Model(&User{}).Where("wechat_id = ?", wechatID).Update("nickname", nickname).Error
 }
 
 // ==================== 管理员相关函数 ====================
 
-// CreateDefaultAdmin 创建默认超级管理员账号，并删除其他管理员
-func CreateDefaultAdmin() error {
+// CreateDefaultAdmin 创建默认超级管理员账号（password 仅在首次创建时使用），并删除其他管理员
+func CreateDefaultAdmin(password string) error {
 	// 删除所有非admin的管理员账号
 	DB.Where("username != ?", "admin").Delete(&Admin{})
 
@@ -685,10 +857,9 @@ func CreateDefaultAdmin() error {
 
 	if count == 0 {
 		// 密码使用简单的MD5加密（实际项目应该使用bcrypt）
-		password := fmt.Sprintf("%x", md5.Sum([]byte("123456")))
 		admin := Admin{
 			Username:  "admin",
-			Password:  password,
+			Password:  fmt.Sprintf("%x", md5.Sum([]byte(password))),
 			Nickname:  "超级管理员",
 			Role:      "super_admin",
 			CreatedAt: time.Now(),
@@ -775,7 +946,7 @@ func GetOrCreateUserByWechatID(wechatID, nickname, avatar string) (*User, error)
 
 		// 只有当传入的头像有效且不是默认值时才更新
 		if avatar != "" && !strings.Contains(avatar, "unsplash.com") {
-			updates["avatar"] = avatar
+			updates["avatar"] = RelativizeUploadURLs(avatar)
 		}
 
 		DB.Model(&user).Updates(updates)
@@ -836,71 +1007,16 @@ func CheckUserPermission(wechatID string, requiredRole string) (bool, error) {
 	return userLevel >= requiredLevel, nil
 }
 
-// AddUserFavorite 添加用户收藏
-func AddUserFavorite(wechatID string, itemType string, itemID int, title string, image string) error {
-	user, err := GetUserByWechatID(wechatID)
-	if err != nil {
-		return err
-	}
-
-	// 解析现有收藏
-	var favorites []map[string]interface{}
-	if user.Favorites != "" && user.Favorites != "[]" {
-		json.Unmarshal([]byte(user.Favorites), &favorites)
-	}
-
-	// 检查是否已收藏，避免重复
-	for _, fav := range favorites {
-		if fav["type"] == itemType && int(fav["id"].(float64)) == itemID {
-			// 已经收藏，更新信息
-			fav["title"] = title
-			fav["image"] = image
-			fav["time"] = time.Now().Unix()
-			favoritesJSON, _ := json.Marshal(favorites)
-			return DB.Model(&User{}).Where("wechat_id = ?", wechatID).Update("favorites", string(favoritesJSON)).Error
-		}
-	}
-
-	// 添加新收藏
-	favorites = append(favorites, map[string]interface{}{
-		"type":  itemType,
-		"id":    itemID,
-		"title": title,
-		"image": image,
-		"time":  time.Now().Unix(),
-	})
-
-	favoritesJSON, _ := json.Marshal(favorites)
-	return DB.Model(&User{}).Where("wechat_id = ?", wechatID).Update("favorites", string(favoritesJSON)).Error
-}
-
-// RemoveUserFavorite 移除用户收藏
-func RemoveUserFavorite(wechatID string, itemType string, itemID int) error {
-	user, err := GetUserByWechatID(wechatID)
-	if err != nil {
-		return err
-	}
-
-	// 解析现有收藏
-	var favorites []map[string]interface{}
-	if user.Favorites != "" && user.Favorites != "[]" {
-		json.Unmarshal([]byte(user.Favorites), &favorites)
-	}
-
-	// 移除指定收藏
-	var newFavorites []map[string]interface{}
-	for _, fav := range favorites {
-		if fav["type"] != itemType || int(fav["id"].(float64)) != itemID {
-			newFavorites = append(newFavorites, fav)
-		}
+// ---------------- Consultation ----------------
+// ConsultationList 咨询列表，不带关键词时走缓存
+func ConsultationList(keyword, category string) ([]Consultation, error) {
+	if keyword == "" {
+		return cachedList("consultation:list:"+category, []string{"consultation"}, func() ([]Consultation, error) { return consultationList("", category) })
 	}
-
-	favoritesJSON, _ := json.Marshal(newFavorites)
-	return DB.Model(&User{}).Where("wechat_id = ?", wechatID).Update("favorites", string(favoritesJSON)).Error
+	return consultationList(keyword, category)
 }
 
-// ---------------- Consultation ----------------
-func ConsultationList(keyword, category string) ([]Consultation, error) {
+func consultationList(keyword, category string) ([]Consultation, error) {
 	var list []Consultation
 	q := DB.Model(&Consultation{})
 	if keyword != "" {
@@ -928,22 +1044,47 @@ func ConsultationCreate(c *Consultation) error {
 	c.PublishTime = time.Now().Format("2006-01-02 15:04")
 	c.Status = "待回复"
 	c.CreatedAt = time.Now()
-	return DB.Create(c).Error
+	if err := Validate(c); err != nil {
+		return err
+	}
+	return invalidateContent("consultation", DB.Create(c).Error)
 }
 
-func IncrementConsultationView(id int) error {
-	return DB.Model(&Consultation{}).Where("id = ?", id).UpdateColumn("view_count", gorm.Expr("view_count + ?", 1)).Error
+// ConsultationAnswer 管理员对咨询的回复
+type ConsultationAnswer struct {
+	Answer string `json:"answer" validate:"required,max=5000"`
 }
 
-func ConsultationDelete(id int) error {
-	res := DB.Delete(&Consultation{}, id)
-	if res.Error != nil {
-		return res.Error
+// AnswerConsultation 保存回复并将咨询标记为已回复，重复回复时覆盖上一次的内容
+func AnswerConsultation(id int, a ConsultationAnswer, answeredBy string) (*Consultation, error) {
+	if err := Validate(&a); err != nil {
+		return nil, err
 	}
-	if res.RowsAffected == 0 {
-		return errors.New("not found")
+	c, err := ConsultationGetByID(id)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	now := time.Now()
+	err = DB.Model(c).Updates(map[string]interface{}{
+		"answer":      a.Answer,
+		"answered_by": answeredBy,
+		"answered_at": now,
+		"status":      "已回复",
+		"reply_count": gorm.Expr("reply_count + ?", 1),
+		"updated_at":  now,
+	}).Error
+	if err := invalidateContent("consultation", err); err != nil {
+		return nil, err
+	}
+	return ConsultationGetByID(id)
+}
+
+func IncrementConsultationView(id int) error {
+	return DB.Model(&Consultation{}).Where("id = ?", id).UpdateColumn("view_count", gorm.Expr("view_count + ?", 1)).Error
+}
+
+func ConsultationDelete(id int, deletedBy string) error {
+	return softDelete("consultation", id, deletedBy)
 }
 
 // 我的发布相关函数
@@ -990,26 +1131,52 @@ func GetMyPublishNews(publisherID string) ([]News, error) {
 }
 
 // ---------------- History 浏览历史 ----------------
+// HistoryLimit 每个用户保留的浏览记录条数，超出部分在写入时清理
+var HistoryLimit = 100
+
 func GetUserHistory(wechatID string) ([]History, error) {
 	var history []History
-	err := DB.Where("user_id = ?", wechatID).Order("view_time DESC").Limit(100).Find(&history).Error
+	err := DB.Where("user_id = ?", wechatID).Order("view_time DESC").Limit(HistoryLimit).Find(&history).Error
 	return history, err
 }
 
+// AddUserHistory 写入浏览记录：已存在则刷新时间，并清理超出上限的旧记录
 func AddUserHistory(wechatID, itemType string, itemID int, title, image string) error {
-	// 先删除同类型同ID的旧记录（避免重复）
-	DB.Where("user_id = ? AND item_type = ? AND item_id = ?", wechatID, itemType, itemID).Delete(&History{})
+	return DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&History{}).
+			Where("user_id = ? AND item_type = ? AND item_id = ?", wechatID, itemType, itemID).
+			Updates(map[string]interface{}{"title": title, "image": image, "view_time": time.Now()})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			history := History{
+				UserID:   wechatID,
+				ItemType: itemType,
+				ItemID:   itemID,
+				Title:    title,
+				Image:    image,
+				ViewTime: time.Now(),
+			}
+			if err := tx.Create(&history).Error; err != nil {
+				return err
+			}
+		}
 
-	// 添加新记录
-	history := History{
-		UserID:   wechatID,
-		ItemType: itemType,
-		ItemID:   itemID,
-		Title:    title,
-		Image:    image,
-		ViewTime: time.Now(),
+		// 只保留最近 HistoryLimit 条
+		return tx.Exec(`DELETE FROM histories WHERE user_id = ? AND id NOT IN (
+			SELECT id FROM histories WHERE user_id = ? ORDER BY view_time DESC, id DESC LIMIT ?)`,
+			wechatID, wechatID, HistoryLimit).Error
+	})
+}
+
+// RecordUserView 根据内容记录本身的标题和图片写入浏览记录
+func RecordUserView(wechatID, itemType string, itemID int) error {
+	title, image, err := ContentSummary(itemType, itemID)
+	if err != nil {
+		return err
 	}
-	return DB.Create(&history).Error
+	return AddUserHistory(wechatID, itemType, itemID, title, image)
 }
 
 func ClearUserHistory(wechatID string) error {
@@ -1027,6 +1194,9 @@ func CreateFeedback(feedbackType, content, contact, userID, nickname string) err
 		Status:    "unread",
 		CreatedAt: time.Now(),
 	}
+	if err := Validate(&feedback); err != nil {
+		return err
+	}
 	return DB.Create(&feedback).Error
 }
 
@@ -1041,7 +1211,14 @@ func MarkFeedbackRead(id int) error {
 }
 
 // ---------------- Settings 系统设置 ----------------
+const bannersCacheKey = "settings:banners"
+
+// GetBanners 获取首页轮播图（走缓存），未设置时返回默认轮播图
 func GetBanners() ([]Banner, error) {
+	return cachedList(bannersCacheKey, nil, getBanners)
+}
+
+func getBanners() ([]Banner, error) {
 	var setting Settings
 	err := DB.Where("key = ?", "banners").First(&setting).Error
 	if err != nil {
@@ -1060,7 +1237,32 @@ func GetBanners() ([]Banner, error) {
 	return banners, nil
 }
 
+// SaveBanners 校验并保存首页轮播图，字段错误的 field 为 banners[序号].字段名
 func SaveBanners(banners []Banner) error {
+	var errs []FieldError
+	if len(banners) > MaxBanners {
+		errs = append(errs, FieldError{Field: "banners", Rule: RuleMax, Param: strconv.Itoa(MaxBanners)})
+	}
+	for i := range banners {
+		err := Validate(&banners[i])
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			for _, f := range verr.Fields {
+				f.Field = fmt.Sprintf("banners[%d].%s", i, f.Field)
+				errs = append(errs, f)
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Fields: errs}
+	}
+
+	defer Cache.Invalidate(bannersCacheKey)
+	for i := range banners {
+		banners[i].URL = RelativizeUploadURLs(banners[i].URL)
+	}
 	data, err := json.Marshal(banners)
 	if err != nil {
 		return err
@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd)
+
+package services
+
+// diskFree 当前平台不支持获取磁盘剩余空间，就绪检查只确认目录可写
+func diskFree(dir string) (int64, error) {
+	return 0, errDiskFreeUnsupported
+}
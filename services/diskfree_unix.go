@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd
+
+package services
+
+import "syscall"
+
+// diskFree 返回 dir 所在文件系统对普通用户可用的剩余字节数
+func diskFree(dir string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return int64(uint64(st.Bavail) * uint64(st.Bsize)), nil
+}
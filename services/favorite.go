@@ -0,0 +1,252 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Favorite 用户收藏
+type Favorite struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"-"`
+	UserID    string    `gorm:"uniqueIndex:idx_favorite_user_item;not null" json:"-"` // wechat_id
+	ItemType  string    `gorm:"uniqueIndex:idx_favorite_user_item;index:idx_favorite_item;not null" json:"type"`
+	ItemID    int       `gorm:"uniqueIndex:idx_favorite_user_item;index:idx_favorite_item;not null" json:"id"`
+	Title     string    `json:"title"`
+	Image     string    `json:"image"`
+	CreatedAt time.Time `json:"-"`
+	Time      int64     `gorm:"-" json:"time"` // 收藏时间（Unix秒），与旧版JSON格式保持一致
+}
+
+// 收藏错误
+var (
+	ErrUnsupportedContentType = errors.New("unsupported content type")
+	ErrContentNotFound        = errors.New("content not found")
+)
+
+// AddUserFavorite 添加用户收藏，已收藏时更新标题和图片
+// 内容类型按 NormalizeContentType 规范化（job 与 jobs 为同一收藏），不支持的类型返回 ErrUnsupportedContentType，
+// 内容不存在或已删除时返回 ErrContentNotFound；未提供标题或图片时使用内容本身的
+func AddUserFavorite(wechatID string, itemType string, itemID int, title string, image string) error {
+	if _, err := GetUserByWechatID(wechatID); err != nil {
+		return err
+	}
+	itemType, ok := NormalizeContentType(itemType)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedContentType, itemType)
+	}
+	itemTitle, itemImage, err := ContentSummary(itemType, itemID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrContentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if title == "" {
+		title = itemTitle
+	}
+	if image == "" {
+		image = itemImage
+	}
+
+	fav := Favorite{
+		UserID:    wechatID,
+		ItemType:  itemType,
+		ItemID:    itemID,
+		Title:     title,
+		Image:     image,
+		CreatedAt: time.Now(),
+	}
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "item_type"}, {Name: "item_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"title", "image", "created_at"}),
+	}).Create(&fav).Error
+}
+
+// RemoveUserFavorite 移除用户收藏，内容类型按 NormalizeContentType 规范化
+func RemoveUserFavorite(wechatID string, itemType string, itemID int) error {
+	itemType, _ = NormalizeContentType(itemType)
+	return DB.Where("user_id = ? AND item_type = ? AND item_id = ?", wechatID, itemType, itemID).Delete(&Favorite{}).Error
+}
+
+// GetUserFavorites 获取用户收藏列表（最近收藏在前）
+func GetUserFavorites(wechatID string) ([]Favorite, error) {
+	var favorites []Favorite
+	if err := DB.Where("user_id = ?", wechatID).Order("created_at DESC").Find(&favorites).Error; err != nil {
+		return nil, err
+	}
+	for i := range favorites {
+		favorites[i].Time = favorites[i].CreatedAt.Unix()
+	}
+	if favorites == nil {
+		favorites = []Favorite{}
+	}
+	return favorites, nil
+}
+
+// FavoriteCount 获取内容被收藏的次数
+func FavoriteCount(itemType string, itemID int) (int64, error) {
+	var count int64
+	err := DB.Model(&Favorite{}).Where("item_type = ? AND item_id = ?", itemType, itemID).Count(&count).Error
+	return count, err
+}
+
+// FavoriteCounts 批量获取内容被收藏的次数
+func FavoriteCounts(itemType string, itemIDs []int) (map[int]int, error) {
+	counts := make(map[int]int, len(itemIDs))
+	if len(itemIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		ItemID int
+		Count  int
+	}
+	err := DB.Model(&Favorite{}).
+		Select("item_id, COUNT(*) AS count").
+		Where("item_type = ? AND item_id IN ?", itemType, itemIDs).
+		Group("item_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.ItemID] = row.Count
+	}
+	return counts, nil
+}
+
+// userFavoriteSet 返回用户在指定内容中已收藏的ID集合
+func userFavoriteSet(wechatID, itemType string, itemIDs []int) (map[int]bool, error) {
+	set := make(map[int]bool)
+	if wechatID == "" || len(itemIDs) == 0 {
+		return set, nil
+	}
+	var ids []int
+	err := DB.Model(&Favorite{}).
+		Where("user_id = ? AND item_type = ? AND item_id IN ?", wechatID, itemType, itemIDs).
+		Pluck("item_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set, nil
+}
+
+// Bookmarkable 可收藏的内容，用于填充 is_bookmarked 和 favorite_count
+type Bookmarkable interface {
+	favoriteKey() (itemType string, itemID int)
+	setFavorite(bookmarked bool, count int)
+}
+
+func (n *News) favoriteKey() (string, int)         { return "news", n.ID }
+func (f *Farmhouse) favoriteKey() (string, int)    { return "farmhouse", f.ID }
+func (p *Policy) favoriteKey() (string, int)       { return "policy", p.ID }
+func (t *Tourism) favoriteKey() (string, int)      { return "tourism", t.ID }
+func (j *Job) favoriteKey() (string, int)          { return "jobs", j.ID }
+func (h *Help) favoriteKey() (string, int)         { return "help", h.ID }
+func (c *Consultation) favoriteKey() (string, int) { return "consultation", c.ID }
+
+func (n *News) setFavorite(b bool, c int)         { n.IsBookmarked, n.FavoriteCount = b, c }
+func (f *Farmhouse) setFavorite(b bool, c int)    { f.IsBookmarked, f.FavoriteCount = b, c }
+func (p *Policy) setFavorite(b bool, c int)       { p.IsBookmarked, p.FavoriteCount = b, c }
+func (t *Tourism) setFavorite(b bool, c int)      { t.IsBookmarked, t.FavoriteCount = b, c }
+func (j *Job) setFavorite(b bool, c int)          { j.IsBookmarked, j.FavoriteCount = b, c }
+func (h *Help) setFavorite(b bool, c int)         { h.IsBookmarked, h.FavoriteCount = b, c }
+func (c *Consultation) setFavorite(b bool, n int) { c.IsBookmarked, c.FavoriteCount = b, n }
+
+// AnnotateFavorites 为列表中的每条内容填充当前用户是否已收藏及收藏总数
+// wechatID 为空（未登录）时 is_bookmarked 均为 false
+func AnnotateFavorites[T any, PT interface {
+	*T
+	Bookmarkable
+}](wechatID string, items []T) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	itemType, _ := PT(&items[0]).favoriteKey()
+	ids := make([]int, len(items))
+	for i := range items {
+		_, ids[i] = PT(&items[i]).favoriteKey()
+	}
+
+	counts, err := FavoriteCounts(itemType, ids)
+	if err != nil {
+		return err
+	}
+	bookmarked, err := userFavoriteSet(wechatID, itemType, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range items {
+		PT(&items[i]).setFavorite(bookmarked[ids[i]], counts[ids[i]])
+	}
+	return nil
+}
+
+// AnnotateFavorite 为单条内容填充收藏信息
+func AnnotateFavorite(wechatID string, item Bookmarkable) error {
+	itemType, itemID := item.favoriteKey()
+	count, err := FavoriteCount(itemType, itemID)
+	if err != nil {
+		return err
+	}
+	bookmarked, err := userFavoriteSet(wechatID, itemType, []int{itemID})
+	if err != nil {
+		return err
+	}
+	item.setFavorite(bookmarked[itemID], int(count))
+	return nil
+}
+
+// migrateFavoritesFromJSON 将 User.Favorites 中的JSON收藏迁移到 Favorite 表
+func migrateFavoritesFromJSON(tx *gorm.DB) error {
+	var users []User
+	if err := tx.Where("favorites <> '' AND favorites <> '[]' AND favorites IS NOT NULL").Find(&users).Error; err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		var legacy []struct {
+			Type  string  `json:"type"`
+			ID    float64 `json:"id"`
+			Title string  `json:"title"`
+			Image string  `json:"image"`
+			Time  int64   `json:"time"`
+		}
+		if err := json.Unmarshal([]byte(u.Favorites), &legacy); err != nil {
+			// 无法解析的旧数据跳过，不阻塞启动
+			continue
+		}
+
+		for _, item := range legacy {
+			if item.Type == "" || item.ID == 0 {
+				continue
+			}
+			itemType, _ := NormalizeContentType(item.Type)
+			createdAt := time.Now()
+			if item.Time > 0 {
+				createdAt = time.Unix(item.Time, 0)
+			}
+			fav := Favorite{
+				UserID:    u.WechatID,
+				ItemType:  itemType,
+				ItemID:    int(item.ID),
+				Title:     item.Title,
+				Image:     item.Image,
+				CreatedAt: createdAt,
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&fav).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAddUserFavorite(t *testing.T) {
+	setupTestDB(t)
+	DB.Create(&User{WechatID: "wx1"})
+	job := Job{Title: "采摘工", Logo: "/uploads/logo.png"}
+	DB.Create(&job)
+	gone := News{Title: "已删除"}
+	DB.Create(&gone)
+	DB.Delete(&gone)
+
+	// job 与 jobs 为同一收藏，未提供标题和图片时取内容本身的
+	if err := AddUserFavorite("wx1", "job", job.ID, "", ""); err != nil {
+		t.Fatalf("AddUserFavorite(job): %v", err)
+	}
+	if err := AddUserFavorite("wx1", "jobs", job.ID, "", ""); err != nil {
+		t.Fatalf("AddUserFavorite(jobs): %v", err)
+	}
+	favs, err := GetUserFavorites("wx1")
+	if err != nil {
+		t.Fatalf("GetUserFavorites: %v", err)
+	}
+	if len(favs) != 1 || favs[0].ItemType != "jobs" || favs[0].Title != "采摘工" || favs[0].Image != "/uploads/logo.png" {
+		t.Errorf("favorites = %+v, want one jobs favorite with the job's title and logo", favs)
+	}
+	if n, _ := FavoriteCount("jobs", job.ID); n != 1 {
+		t.Errorf("FavoriteCount = %d, want 1", n)
+	}
+
+	for _, tc := range []struct {
+		itemType string
+		itemID   int
+		want     error
+	}{
+		{"article", job.ID, ErrUnsupportedContentType},
+		{"", job.ID, ErrUnsupportedContentType},
+		{"jobs", job.ID + 100, ErrContentNotFound},
+		{"news", gone.ID, ErrContentNotFound},
+	} {
+		if err := AddUserFavorite("wx1", tc.itemType, tc.itemID, "t", ""); !errors.Is(err, tc.want) {
+			t.Errorf("AddUserFavorite(%q, %d) = %v, want %v", tc.itemType, tc.itemID, err, tc.want)
+		}
+	}
+
+	if err := RemoveUserFavorite("wx1", "job", job.ID); err != nil {
+		t.Fatalf("RemoveUserFavorite: %v", err)
+	}
+	if favs, _ := GetUserFavorites("wx1"); len(favs) != 0 {
+		t.Errorf("favorites after removing via job = %+v, want none", favs)
+	}
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"strings"
+)
+
+// 图片尺寸限制，防止解压炸弹（小文件解码后占用大量内存）
+var (
+	MaxImageDimension = 12000      // 单边最大像素
+	MaxImagePixels    = 40_000_000 // 最大总像素（GIF 按所有帧累计）
+	MaxDocxUnpacked   = int64(200 << 20)
+	MaxGIFFrames      = 500             // 动图最大帧数
+	MaxGIFFileSize    = int64(20 << 20) // 动图最大文件大小
+)
+
+// ErrFileRejected 上传文件未通过校验
+var ErrFileRejected = errors.New("file rejected")
+
+// FileKind 允许上传的文件类型
+type FileKind struct {
+	MimeType string
+	IsImage  bool
+	Desc     string // 展示给用户的类型名称
+}
+
+// 扩展名白名单 -> 权威 MIME 类型
+var allowedFileKinds = map[string]FileKind{
+	".jpg":  {MimeType: "image/jpeg", IsImage: true, Desc: "图片"},
+	".jpeg": {MimeType: "image/jpeg", IsImage: true, Desc: "图片"},
+	".png":  {MimeType: "image/png", IsImage: true, Desc: "图片"},
+	".gif":  {MimeType: "image/gif", IsImage: true, Desc: "图片"},
+	".pdf":  {MimeType: "application/pdf", Desc: "PDF文档"},
+	".doc":  {MimeType: "application/msword", Desc: "Word文档"},
+	".docx": {MimeType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", Desc: "Word文档"},
+}
+
+// LookupFileKind 根据扩展名查找允许的文件类型
+func LookupFileKind(ext string) (FileKind, bool) {
+	k, ok := allowedFileKinds[strings.ToLower(ext)]
+	return k, ok
+}
+
+// 文件头魔数
+var (
+	magicJPEG = []byte{0xFF, 0xD8, 0xFF}
+	magicPNG  = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}
+	magicGIF7 = []byte("GIF87a")
+	magicGIF9 = []byte("GIF89a")
+	magicPDF  = []byte("%PDF-")
+	magicOLE  = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1} // .doc 复合文档
+	magicZIP  = []byte{'P', 'K', 0x03, 0x04}                           // .docx
+)
+
+// SniffMimeType 根据文件头识别真实类型，无法识别返回空字符串
+func SniffMimeType(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, magicJPEG):
+		return "image/jpeg"
+	case bytes.HasPrefix(head, magicPNG):
+		return "image/png"
+	case bytes.HasPrefix(head, magicGIF7), bytes.HasPrefix(head, magicGIF9):
+		return "image/gif"
+	case bytes.HasPrefix(head, magicPDF):
+		return "application/pdf"
+	case bytes.HasPrefix(head, magicOLE):
+		return "application/msword"
+	case bytes.HasPrefix(head, magicZIP):
+		return "application/zip"
+	}
+	return ""
+}
+
+// ValidateUploadFile 校验上传文件的真实类型与扩展名一致，图片需能完整解码且尺寸在限制内
+// 返回权威 MIME 类型
+func ValidateUploadFile(path, ext string) (string, error) {
+	kind, ok := LookupFileKind(ext)
+	if !ok {
+		return "", fmt.Errorf("%w: extension %s not allowed", ErrFileRejected, ext)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 16)
+	n, _ := io.ReadFull(f, head)
+	sniffed := SniffMimeType(head[:n])
+
+	switch kind.MimeType {
+	case "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+		if sniffed != "application/zip" {
+			return "", fmt.Errorf("%w: content is not a docx package", ErrFileRejected)
+		}
+		if err := validateDocx(path); err != nil {
+			return "", err
+		}
+		return kind.MimeType, nil
+	default:
+		if sniffed != kind.MimeType {
+			return "", fmt.Errorf("%w: content type %q does not match extension %s", ErrFileRejected, sniffed, ext)
+		}
+	}
+
+	if kind.IsImage {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		if err := validateImage(f, kind.MimeType); err != nil {
+			return "", err
+		}
+	}
+	return kind.MimeType, nil
+}
+
+// validateImage 先读取图片头检查尺寸，再完整解码确认文件未损坏
+func validateImage(r io.ReadSeeker, mimeType string) error {
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return fmt.Errorf("%w: invalid image: %v", ErrFileRejected, err)
+	}
+	if "image/"+format != mimeType {
+		return fmt.Errorf("%w: image format %s does not match %s", ErrFileRejected, format, mimeType)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > MaxImageDimension || cfg.Height > MaxImageDimension ||
+		cfg.Width*cfg.Height > MaxImagePixels {
+		return fmt.Errorf("%w: image dimensions %dx%d exceed limit", ErrFileRejected, cfg.Width, cfg.Height)
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if format == "gif" {
+		// 动图完整解码会同时保留所有帧，先扫描文件结构检查大小、帧数和累计像素，再解码
+		if err := checkGIFLimits(r); err != nil {
+			return err
+		}
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := gif.DecodeAll(r); err != nil {
+			return fmt.Errorf("%w: invalid gif: %v", ErrFileRejected, err)
+		}
+		return nil
+	}
+
+	if _, _, err := image.Decode(r); err != nil {
+		return fmt.Errorf("%w: invalid image: %v", ErrFileRejected, err)
+	}
+	return nil
+}
+
+// checkGIFLimits 不解码像素，只按块结构读取各帧的图像描述符，检查文件大小、帧数和所有帧的累计像素
+func checkGIFLimits(r io.ReadSeeker) error {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if size > MaxGIFFileSize {
+		return fmt.Errorf("%w: gif file too large", ErrFileRejected)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	br := bufio.NewReader(r)
+	invalid := func(err error) error {
+		return fmt.Errorf("%w: invalid gif: %v", ErrFileRejected, err)
+	}
+	// 文件头（6 字节）和逻辑屏幕描述符（7 字节），之后可能是全局颜色表
+	var header [13]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return invalid(err)
+	}
+	if err := skipColorTable(br, header[10]); err != nil {
+		return invalid(err)
+	}
+
+	frames, pixels := 0, 0
+	for {
+		introducer, err := br.ReadByte()
+		if err != nil {
+			return invalid(err)
+		}
+		switch introducer {
+		case 0x21: // 扩展块：标签后跟数据子块
+			if _, err := br.ReadByte(); err != nil {
+				return invalid(err)
+			}
+			if err := skipSubBlocks(br); err != nil {
+				return invalid(err)
+			}
+		case 0x2C: // 图像描述符：位置、宽高、标志，之后是局部颜色表和 LZW 数据
+			var desc [9]byte
+			if _, err := io.ReadFull(br, desc[:]); err != nil {
+				return invalid(err)
+			}
+			frames++
+			pixels += int(binary.LittleEndian.Uint16(desc[4:6])) * int(binary.LittleEndian.Uint16(desc[6:8]))
+			if frames > MaxGIFFrames {
+				return fmt.Errorf("%w: gif has too many frames", ErrFileRejected)
+			}
+			if pixels > MaxImagePixels {
+				return fmt.Errorf("%w: gif frames exceed pixel limit", ErrFileRejected)
+			}
+			if err := skipColorTable(br, desc[8]); err != nil {
+				return invalid(err)
+			}
+			if _, err := br.ReadByte(); err != nil { // LZW 最小码长
+				return invalid(err)
+			}
+			if err := skipSubBlocks(br); err != nil {
+				return invalid(err)
+			}
+		case 0x3B: // 结束符
+			return nil
+		default:
+			return invalid(fmt.Errorf("unknown block 0x%02x", introducer))
+		}
+	}
+}
+
+// skipColorTable 标志字节最高位表示有颜色表，低 3 位为表大小
+func skipColorTable(br *bufio.Reader, flags byte) error {
+	if flags&0x80 == 0 {
+		return nil
+	}
+	_, err := br.Discard(3 << ((flags & 0x07) + 1))
+	return err
+}
+
+// skipSubBlocks 跳过以长度为 0 的子块结束的数据子块序列
+func skipSubBlocks(br *bufio.Reader) error {
+	for {
+		n, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		if _, err := br.Discard(int(n)); err != nil {
+			return err
+		}
+	}
+}
+
+// validateDocx 检查 zip 包结构为 Word 文档，并限制解压后总大小
+func validateDocx(path string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("%w: invalid docx: %v", ErrFileRejected, err)
+	}
+	defer zr.Close()
+
+	var hasContentTypes, hasDocument bool
+	var total uint64
+	for _, f := range zr.File {
+		total += f.UncompressedSize64
+		switch f.Name {
+		case "[Content_Types].xml":
+			hasContentTypes = true
+		case "word/document.xml":
+			hasDocument = true
+		}
+	}
+	if !hasContentTypes || !hasDocument {
+		return fmt.Errorf("%w: zip is not a Word document", ErrFileRejected)
+	}
+	if total > uint64(MaxDocxUnpacked) {
+		return fmt.Errorf("%w: docx unpacked size too large", ErrFileRejected)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color/palette"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestGIF 生成 frames 帧、每帧 w×h 的动图
+func writeTestGIF(t *testing.T, frames, w, h int) string {
+	t.Helper()
+	g := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		img := image.NewPaletted(image.Rect(0, 0, w, h), palette.Plan9)
+		img.Pix[0] = uint8(i)
+		g.Image = append(g.Image, img)
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "a.gif")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateUploadFileGIFLimits(t *testing.T) {
+	oldFrames, oldPixels, oldSize := MaxGIFFrames, MaxImagePixels, MaxGIFFileSize
+	t.Cleanup(func() { MaxGIFFrames, MaxImagePixels, MaxGIFFileSize = oldFrames, oldPixels, oldSize })
+
+	path := writeTestGIF(t, 3, 10, 10)
+	if mime, err := ValidateUploadFile(path, ".gif"); err != nil || mime != "image/gif" {
+		t.Fatalf("ValidateUploadFile = %q, %v, want image/gif", mime, err)
+	}
+
+	for name, set := range map[string]func(){
+		"frames": func() { MaxGIFFrames = 2 },
+		"pixels": func() { MaxImagePixels = 250 },
+		"size":   func() { MaxGIFFileSize = 16 },
+	} {
+		MaxGIFFrames, MaxImagePixels, MaxGIFFileSize = oldFrames, oldPixels, oldSize
+		set()
+		if _, err := ValidateUploadFile(path, ".gif"); !errors.Is(err, ErrFileRejected) {
+			t.Errorf("%s limit: err = %v, want ErrFileRejected", name, err)
+		}
+	}
+}
+
+func TestCheckGIFLimitsTruncated(t *testing.T) {
+	data, err := os.ReadFile(writeTestGIF(t, 2, 10, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkGIFLimits(bytes.NewReader(data[:len(data)-5])); !errors.Is(err, ErrFileRejected) {
+		t.Errorf("truncated gif: err = %v, want ErrFileRejected", err)
+	}
+}
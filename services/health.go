@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// 就绪检查阈值，由配置覆盖
+var (
+	HealthMaxWALBytes   = int64(64 << 20)  // WAL 超过该大小时告警（检查点长期无法完成）
+	HealthWarnFreeBytes = int64(1 << 30)   // 上传目录所在磁盘剩余空间低于该值时告警
+	HealthMinFreeBytes  = int64(100 << 20) // 剩余空间低于该值时判定未就绪
+	HealthCheckTimeout  = 3 * time.Second  // 单次就绪检查的总耗时上限
+)
+
+// 单项检查结果状态：warn 只提示，不影响就绪；fail 判定未就绪；skip 表示当前部署不适用
+const (
+	CheckOK   = "ok"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+	CheckSkip = "skip"
+)
+
+// HealthCheck 单项检查结果
+type HealthCheck struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Detail    string  `json:"detail,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// Readiness 就绪检查结果
+type Readiness struct {
+	Ready               bool          `json:"ready"`
+	SchemaVersion       int           `json:"schema_version"`
+	LatestSchemaVersion int           `json:"latest_schema_version"`
+	Checks              []HealthCheck `json:"checks"`
+}
+
+// errDiskFreeUnsupported 当前平台无法获取磁盘剩余空间
+var errDiskFreeUnsupported = errors.New("disk free space not supported on this platform")
+
+// CheckReadiness 依次检查数据库可读写、WAL 大小、迁移版本和上传目录，任一项失败即未就绪
+func CheckReadiness(ctx context.Context) *Readiness {
+	ctx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+	defer cancel()
+
+	res := &Readiness{Ready: true, LatestSchemaVersion: LatestSchemaVersion()}
+	run := func(name string, fn func() (string, string)) {
+		start := time.Now()
+		status, detail := fn()
+		res.Checks = append(res.Checks, HealthCheck{
+			Name:      name,
+			Status:    status,
+			Detail:    detail,
+			LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		})
+		if status == CheckFail {
+			res.Ready = false
+		}
+	}
+
+	run("database", func() (string, string) { return checkDatabase(ctx) })
+	run("wal", func() (string, string) { return checkWAL(ctx) })
+	run("migrations", func() (string, string) {
+		version, err := SchemaVersion(ctx)
+		if err != nil {
+			return CheckFail, err.Error()
+		}
+		res.SchemaVersion = version
+		if version < res.LatestSchemaVersion {
+			return CheckFail, fmt.Sprintf("schema version %d, expected %d", version, res.LatestSchemaVersion)
+		}
+		return CheckOK, fmt.Sprintf("schema version %d", version)
+	})
+	run("uploads", checkUploadDir)
+	return res
+}
+
+// checkDatabase 确认数据库连接可用且能获得写锁（其他进程长时间持有锁时失败）
+func checkDatabase(ctx context.Context) (string, string) {
+	if DB == nil {
+		return CheckFail, "database not initialized"
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return CheckFail, err.Error()
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return CheckFail, err.Error()
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return CheckFail, fmt.Sprintf("acquire write lock: %v", err)
+	}
+	if _, err := conn.ExecContext(context.Background(), "ROLLBACK"); err != nil {
+		return CheckFail, err.Error()
+	}
+	return CheckOK, ""
+}
+
+// checkWAL 检查 WAL 文件大小，持续增长说明检查点被长事务阻塞
+func checkWAL(ctx context.Context) (string, string) {
+	if DB == nil {
+		return CheckFail, "database not initialized"
+	}
+	var files []struct {
+		Seq  int
+		Name string
+		File string
+	}
+	if err := DB.WithContext(ctx).Raw("PRAGMA database_list").Scan(&files).Error; err != nil {
+		return CheckFail, err.Error()
+	}
+	path := ""
+	for _, f := range files {
+		if f.Name == "main" {
+			path = f.File
+		}
+	}
+	if path == "" {
+		return CheckSkip, "in-memory database"
+	}
+
+	info, err := os.Stat(path + "-wal")
+	if errors.Is(err, os.ErrNotExist) {
+		return CheckOK, "no WAL file"
+	}
+	if err != nil {
+		return CheckFail, err.Error()
+	}
+	detail := fmt.Sprintf("%d bytes", info.Size())
+	if info.Size() > HealthMaxWALBytes {
+		return CheckWarn, fmt.Sprintf("%s, exceeds %d MB", detail, HealthMaxWALBytes>>20)
+	}
+	return CheckOK, detail
+}
+
+// checkUploadDir 确认本地上传目录可写且磁盘剩余空间充足，S3 存储不检查
+func checkUploadDir() (string, string) {
+	local, ok := UploadStorage.(*LocalStorage)
+	if !ok {
+		return CheckSkip, "uploads stored in object storage"
+	}
+
+	f, err := os.CreateTemp(local.Dir, ".readyz-*")
+	if err != nil {
+		return CheckFail, fmt.Sprintf("not writable: %v", err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	free, err := diskFree(local.Dir)
+	if errors.Is(err, errDiskFreeUnsupported) {
+		return CheckOK, "writable"
+	}
+	if err != nil {
+		return CheckFail, err.Error()
+	}
+	detail := fmt.Sprintf("writable, %d MB free", free>>20)
+	switch {
+	case free < HealthMinFreeBytes:
+		return CheckFail, fmt.Sprintf("%s, below %d MB", detail, HealthMinFreeBytes>>20)
+	case free < HealthWarnFreeBytes:
+		return CheckWarn, fmt.Sprintf("%s, below %d MB", detail, HealthWarnFreeBytes>>20)
+	}
+	return CheckOK, detail
+}
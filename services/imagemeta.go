@@ -0,0 +1,429 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 去除照片中的 EXIF/XMP/IPTC 等元数据（含 GPS 位置），方向信息先应用到像素上再去掉
+//
+// JPEG：方向为正常时只删除元数据段，图像数据原样保留（无损）；需要旋转时解码后重新编码
+// PNG：删除 eXIf 和文本块；需要旋转时解码后重新编码
+
+// SanitizedJPEGQuality 需要旋转而重新编码 JPEG 时使用的质量
+var SanitizedJPEGQuality = 92
+
+// errNotSanitizable 图片结构无法解析，按校验失败处理
+var errNotSanitizable = fmt.Errorf("%w: unsupported image structure", ErrFileRejected)
+
+// SanitizeImageFile 就地清理图片文件的元数据，changed 表示文件内容是否发生变化
+// 只用于尚未按哈希命名保存的临时文件；已保存的文件内容变化后须按新哈希另存
+func SanitizeImageFile(path, mimeType string) (changed bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	out, err := sanitizeImage(data, mimeType)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(out, data) {
+		return false, nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".sanitize-*")
+	if err != nil {
+		return false, err
+	}
+	if _, err := tmp.Write(out); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return false, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return false, err
+	}
+	return true, nil
+}
+
+// sanitizeImage 返回去除元数据后的图片内容，不需要清理的类型原样返回
+func sanitizeImage(data []byte, mimeType string) ([]byte, error) {
+	switch mimeType {
+	case "image/jpeg":
+		return sanitizeJPEG(data)
+	case "image/png":
+		return sanitizePNG(data)
+	}
+	return data, nil
+}
+
+// ---------------- JPEG ----------------
+
+// sanitizeJPEG 删除 APP1(EXIF/XMP)、APP13(IPTC) 等元数据段和注释，保留 JFIF、ICC 色彩配置和 Adobe 段
+func sanitizeJPEG(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errNotSanitizable
+	}
+
+	orientation := 1
+	var icc []byte // ICC 色彩配置段（可能分为多段），重新编码时放回
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+
+	i := 2
+	for {
+		// 跳过填充字节
+		for i < len(data) && data[i] == 0xFF && i+1 < len(data) && data[i+1] == 0xFF {
+			i++
+		}
+		if i+4 > len(data) || data[i] != 0xFF {
+			return nil, errNotSanitizable
+		}
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if length < 2 || i+2+length > len(data) {
+			return nil, errNotSanitizable
+		}
+		segment := data[i : i+2+length]
+		payload := segment[4:]
+
+		if marker == 0xDA {
+			// SOS 之后为图像数据，截断到 EOI，丢弃附加在文件末尾的数据（部分手机会追加带 EXIF 的副图）
+			end := bytes.Index(data[i:], []byte{0xFF, 0xD9})
+			if end < 0 {
+				out.Write(data[i:])
+			} else {
+				out.Write(data[i : i+end+2])
+			}
+			break
+		}
+
+		keep := true
+		switch {
+		case marker == 0xE1:
+			if o, ok := exifOrientation(payload, true); ok {
+				orientation = o
+			}
+			keep = false
+		case marker == 0xE2:
+			if keep = bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00")); keep {
+				icc = append(icc, segment...)
+			}
+		case marker == 0xE0, marker == 0xEE:
+			keep = true
+		case marker >= 0xE3 && marker <= 0xEF, marker == 0xFE:
+			keep = false
+		}
+		if keep {
+			out.Write(segment)
+		}
+		i += 2 + length
+	}
+
+	if orientation == 1 {
+		return out.Bytes(), nil
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, applyOrientation(img, orientation), &jpeg.Options{Quality: SanitizedJPEGQuality}); err != nil {
+		return nil, err
+	}
+	encoded := buf.Bytes()
+	// 编码器不写色彩配置，把原图的 ICC 段放回 SOI 之后，避免广色域照片偏色；
+	// 重新编码后总是 YCbCr 三通道，CMYK 等其他色彩空间的配置不再适用
+	if !iccIsRGB(icc) {
+		return encoded, nil
+	}
+	return append(append(encoded[:2:2], icc...), encoded[2:]...), nil
+}
+
+// iccIsRGB 判断 JPEG 中 ICC 配置的色彩空间是否为 RGB（配置头第 16~19 字节）
+// segments 为原样拼接的 APP2 段，第一段依次为标记(2)、长度(2)、"ICC_PROFILE\0"(12)、序号(1)、总段数(1)、配置数据
+func iccIsRGB(segments []byte) bool {
+	const header = 2 + 2 + 12 + 2
+	return len(segments) >= header+20 && string(segments[header+16:header+20]) == "RGB "
+}
+
+// ---------------- PNG ----------------
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}
+
+// PNG 中需要删除的辅助块：EXIF、文本（可能含 XMP）、修改时间
+var pngStrippedChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+// sanitizePNG 删除元数据块，其余数据块原样保留（CRC 不变）
+func sanitizePNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errNotSanitizable
+	}
+
+	orientation := 1
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+
+	i := len(pngSignature)
+	for {
+		if i+8 > len(data) {
+			return nil, errNotSanitizable
+		}
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		chunkType := string(data[i+4 : i+8])
+		end := i + 12 + length
+		if end > len(data) {
+			return nil, errNotSanitizable
+		}
+
+		if chunkType == "eXIf" {
+			if o, ok := exifOrientation(data[i+8:i+8+length], false); ok {
+				orientation = o
+			}
+		}
+		if !pngStrippedChunks[chunkType] {
+			out.Write(data[i:end])
+		}
+		i = end
+		if chunkType == "IEND" {
+			break
+		}
+	}
+
+	if orientation == 1 {
+		return out.Bytes(), nil
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, applyOrientation(img, orientation)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ---------------- EXIF 方向 ----------------
+
+// exifOrientation 从 EXIF 数据中读取 IFD0 的 Orientation 标签
+// JPEG APP1 段以 "Exif\0\0" 开头，PNG eXIf 块直接是 TIFF 结构
+func exifOrientation(b []byte, hasHeader bool) (int, bool) {
+	if hasHeader {
+		if !bytes.HasPrefix(b, []byte("Exif\x00\x00")) {
+			return 0, false
+		}
+		b = b[6:]
+	}
+	if len(b) < 8 {
+		return 0, false
+	}
+
+	var order binary.ByteOrder
+	switch string(b[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, false
+	}
+
+	ifd := int(order.Uint32(b[4:8]))
+	if ifd < 8 || ifd+2 > len(b) {
+		return 0, false
+	}
+	count := int(order.Uint16(b[ifd : ifd+2]))
+	for n := 0; n < count; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(b) {
+			return 0, false
+		}
+		if order.Uint16(b[entry:entry+2]) != 0x0112 {
+			continue
+		}
+		// 类型 SHORT，值直接存放在条目中
+		if order.Uint16(b[entry+2:entry+4]) != 3 {
+			return 0, false
+		}
+		o := int(order.Uint16(b[entry+8 : entry+10]))
+		if o < 1 || o > 8 {
+			return 0, false
+		}
+		return o, true
+	}
+	return 0, false
+}
+
+// applyOrientation 按 EXIF 方向值旋转/翻转图像，使其以正常方向显示
+func applyOrientation(src image.Image, orientation int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for sy := 0; sy < h; sy++ {
+		for sx := 0; sx < w; sx++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 水平翻转
+				dx, dy = w-1-sx, sy
+			case 3: // 旋转 180°
+				dx, dy = w-1-sx, h-1-sy
+			case 4: // 垂直翻转
+				dx, dy = sx, h-1-sy
+			case 5: // 沿主对角线翻转
+				dx, dy = sy, sx
+			case 6: // 顺时针旋转 90°
+				dx, dy = h-1-sy, sx
+			case 7: // 沿副对角线翻转
+				dx, dy = h-1-sy, w-1-sx
+			case 8: // 逆时针旋转 90°
+				dx, dy = sy, w-1-sx
+			default:
+				dx, dy = sx, sy
+			}
+			si := sy*rgba.Stride + sx*4
+			di := dy*dst.Stride + dx*4
+			copy(dst.Pix[di:di+4], rgba.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+// stripExistingUploadMetadata 清理存储中已有照片的元数据
+// 文件按内容哈希命名并作为不可变资源长期缓存，不能就地改写：清理后的内容按新哈希另存，
+// 更新元数据登记和各表中的引用（变体按新文件名重新生成）
+// 旧文件不在迁移中删除：事务回滚时引用仍指向旧文件；提交后旧文件不再被引用，由孤立文件清理移入隔离区
+func stripExistingUploadMetadata(tx *gorm.DB) error {
+	objects, err := UploadStorage.List("")
+	if err != nil {
+		return err
+	}
+
+	prefixes := uploadURLPrefixes(UploadStorage)
+	for _, obj := range objects {
+		name := obj.Key
+		if strings.HasPrefix(name, ".") || !IsImageFile(name) || IsVariantFile(name) {
+			continue
+		}
+		target, err := resaveSanitizedUpload(tx, name)
+		if err != nil {
+			if errors.Is(err, ErrFileRejected) {
+				slog.Warn("skipping upload whose metadata cannot be stripped", "path", name, "error", err)
+				continue
+			}
+			return fmt.Errorf("sanitize %s: %w", name, err)
+		}
+		if target == "" {
+			continue
+		}
+		if err := replaceUploadReferences(tx, prefixes, name, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resaveSanitizedUpload 清理存储中一张图片的元数据，内容不变时返回 ""，否则返回清理后内容的文件名
+// 旧数据中相同内容可能存在多个文件名（只有一个登记在元数据表中），清理后的内容已登记时复用已有文件
+func resaveSanitizedUpload(tx *gorm.DB, name string) (string, error) {
+	r, _, err := UploadStorage.Get(name)
+	if err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return "", err
+	}
+	kind, _ := LookupFileKind(filepath.Ext(name))
+	out, err := sanitizeImage(data, kind.MimeType)
+	if err != nil || bytes.Equal(out, data) {
+		return "", err
+	}
+
+	sum := sha256.Sum256(out)
+	hash := hex.EncodeToString(sum[:])
+	target := ContentAddressedName(hash, filepath.Ext(name))
+	var existing UploadFile
+	err = tx.Where("hash = ?", hash).First(&existing).Error
+	if err == nil {
+		target = existing.Path
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if _, err := UploadStorage.Stat(target); errors.Is(err, ErrObjectNotFound) {
+		if err := UploadStorage.Put(target, bytes.NewReader(out), int64(len(out)), kind.MimeType); err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	}
+
+	var f UploadFile
+	err = tx.Where("path = ?", name).First(&f).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return target, nil
+	case err != nil:
+		return "", err
+	case existing.ID != 0:
+		// 清理后的内容已有登记，旧文件的登记不再需要
+		return target, tx.Delete(&f).Error
+	default:
+		return target, tx.Model(&f).Updates(map[string]interface{}{"hash": hash, "path": target, "size": len(out)}).Error
+	}
+}
+
+// replaceUploadReferences 把各表中对上传文件 oldName 的引用改为 newName
+func replaceUploadReferences(tx *gorm.DB, prefixes []string, oldName, newName string) error {
+	escape := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	for _, ref := range uploadReferences {
+		for _, col := range ref.Columns {
+			for _, p := range prefixes {
+				err := tx.Table(ref.Table).Where(col+` LIKE ? ESCAPE '\'`, "%"+escape.Replace(p+oldName)+"%").
+					UpdateColumn(col, gorm.Expr("REPLACE("+col+", ?, ?)", p+oldName, p+newName)).Error
+				if err != nil {
+					return fmt.Errorf("update %s.%s: %w", ref.Table, col, err)
+				}
+			}
+		}
+	}
+	return nil
+}
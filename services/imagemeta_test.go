@@ -0,0 +1,156 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testJPEG 生成带 EXIF 方向（和可选 ICC 配置段）的 4x2 JPEG
+func testJPEG(t *testing.T, orientation uint16, icc []byte) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	img.Set(0, 0, color.RGBA{0xff, 0, 0, 0xff})
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("jpeg.Encode: %v", err)
+	}
+	encoded := buf.Bytes()
+
+	// 大端 TIFF，IFD0 只有 Orientation 一项
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01")
+	exif = binary.BigEndian.AppendUint16(exif, orientation)
+	exif = append(exif, 0, 0, 0, 0, 0, 0)
+
+	out := append([]byte{}, encoded[:2]...)
+	out = append(out, jpegSegment(0xE1, exif)...)
+	if icc != nil {
+		out = append(out, jpegSegment(0xE2, append([]byte("ICC_PROFILE\x00\x01\x01"), icc...))...)
+	}
+	return append(out, encoded[2:]...)
+}
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	seg := []byte{0xFF, marker}
+	seg = binary.BigEndian.AppendUint16(seg, uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+func testICCProfile(colorSpace string) []byte {
+	profile := make([]byte, 128)
+	copy(profile[16:20], colorSpace)
+	return profile
+}
+
+func TestSanitizeJPEGRotateKeepsRGBProfile(t *testing.T) {
+	profile := testICCProfile("RGB ")
+	out, err := sanitizeJPEG(testJPEG(t, 6, profile))
+	if err != nil {
+		t.Fatalf("sanitizeJPEG: %v", err)
+	}
+	if bytes.Contains(out, []byte("Exif\x00\x00")) {
+		t.Errorf("EXIF segment still present")
+	}
+	if !bytes.Contains(out, append([]byte("ICC_PROFILE\x00\x01\x01"), profile...)) {
+		t.Errorf("ICC profile dropped when re-encoding a rotated JPEG")
+	}
+	img, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("decode sanitized JPEG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 2 || b.Dy() != 4 {
+		t.Errorf("bounds = %v, want 2x4 after rotating 90°", b)
+	}
+}
+
+func TestSanitizeJPEGRotateDropsCMYKProfile(t *testing.T) {
+	out, err := sanitizeJPEG(testJPEG(t, 3, testICCProfile("CMYK")))
+	if err != nil {
+		t.Fatalf("sanitizeJPEG: %v", err)
+	}
+	if bytes.Contains(out, []byte("ICC_PROFILE")) {
+		t.Errorf("CMYK profile kept on a re-encoded YCbCr JPEG")
+	}
+}
+
+// 已保存的文件按哈希命名、长期缓存，清理元数据后须按新哈希另存并更新引用，S3 存储同样适用
+func TestStripExistingUploadMetadataS3(t *testing.T) {
+	setupTestDB(t)
+	fake, s3 := newFakeS3(t, "uploads")
+	s3.PublicURL = "https://cdn.example.com/zx_uploads"
+	UploadStorage = s3
+
+	data := testJPEG(t, 1, nil)
+	sum := sha256.Sum256(data)
+	oldHash := hex.EncodeToString(sum[:])
+	oldName := ContentAddressedName(oldHash, ".jpg")
+	for _, key := range []string{oldName, VariantFileName(oldName, "thumb")} {
+		if err := s3.Put(key, bytes.NewReader(data), int64(len(data)), "image/jpeg"); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+	DB.Create(&UploadFile{Hash: oldHash, Path: oldName, Size: int64(len(data)), MimeType: "image/jpeg"})
+	DB.Create(&News{Title: "n", Image: s3.URL(oldName)})
+	DB.Create(&Help{Title: "h", Images: "/uploads/a.jpg,/uploads/" + oldName + "?v=1"})
+
+	if err := stripExistingUploadMetadata(DB); err != nil {
+		t.Fatalf("stripExistingUploadMetadata: %v", err)
+	}
+
+	var f UploadFile
+	if err := DB.First(&f).Error; err != nil {
+		t.Fatalf("load upload record: %v", err)
+	}
+	if f.Hash == oldHash || f.Path != ContentAddressedName(f.Hash, ".jpg") {
+		t.Fatalf("record = %+v, want a new hash and matching path", f)
+	}
+	// 旧文件在迁移中保留，事务回滚时引用仍然有效
+	if got, want := strings.Join(fake.keys(), ","), strings.Join([]string{f.Path, oldName, VariantFileName(oldName, "thumb")}, ","); got != want {
+		t.Errorf("objects = %s, want %s", got, want)
+	}
+	r, _, err := s3.Get(f.Path)
+	if err != nil {
+		t.Fatalf("Get %s: %v", f.Path, err)
+	}
+	defer r.Close()
+	var content bytes.Buffer
+	content.ReadFrom(r)
+	if sum := sha256.Sum256(content.Bytes()); hex.EncodeToString(sum[:]) != f.Hash || int64(content.Len()) != f.Size {
+		t.Errorf("stored content does not match record hash/size")
+	}
+	if bytes.Contains(content.Bytes(), []byte("Exif\x00\x00")) {
+		t.Errorf("EXIF still present in %s", f.Path)
+	}
+
+	var n News
+	DB.First(&n)
+	if n.Image != s3.URL(f.Path) {
+		t.Errorf("news image = %q, want %q", n.Image, s3.URL(f.Path))
+	}
+	var h Help
+	DB.First(&h)
+	if want := "/uploads/a.jpg,/uploads/" + f.Path + "?v=1"; h.Images != want {
+		t.Errorf("help images = %q, want %q", h.Images, want)
+	}
+
+	// 提交后旧文件不再被引用，由孤立文件清理隔离，其变体直接删除
+	old := time.Now().Add(-2 * UploadGCGracePeriod)
+	fake.setModTime(oldName, old)
+	fake.setModTime(VariantFileName(oldName, "thumb"), old)
+	if _, err := RunUploadGC(false); err != nil {
+		t.Fatalf("RunUploadGC: %v", err)
+	}
+	if got, want := strings.Join(fake.keys(), ","), strings.Join([]string{quarantineKey(oldName), f.Path}, ","); got != want {
+		t.Errorf("objects after GC = %s, want %s", got, want)
+	}
+}
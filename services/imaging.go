@@ -0,0 +1,245 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// ImageVariant 图片尺寸变体，统一重新编码为 JPEG
+type ImageVariant struct {
+	Name     string
+	MaxWidth int
+	Quality  int
+}
+
+// ImageVariants 上传图片时生成的变体配置
+var ImageVariants = []ImageVariant{
+	{Name: "thumb", MaxWidth: 320, Quality: 75},
+	{Name: "medium", MaxWidth: 960, Quality: 82},
+}
+
+// LookupImageVariant 根据名称查找变体配置
+func LookupImageVariant(name string) (ImageVariant, bool) {
+	for _, v := range ImageVariants {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return ImageVariant{}, false
+}
+
+// VariantFileName 变体文件名：原文件名去掉扩展名 + _变体名.jpg
+func VariantFileName(name, variant string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name)) + "_" + variant + ".jpg"
+}
+
+// IsImageFile 根据扩展名判断是否为可生成变体的图片
+func IsImageFile(name string) bool {
+	kind, ok := LookupFileKind(filepath.Ext(name))
+	return ok && kind.IsImage
+}
+
+// IsVariantFile 判断文件名是否为生成的变体（变体不再生成变体）
+func IsVariantFile(name string) bool {
+	for _, v := range ImageVariants {
+		if strings.HasSuffix(name, "_"+v.Name+".jpg") {
+			return true
+		}
+	}
+	return false
+}
+
+// EnsureImageVariants 为存储中的图片生成所有缺失的变体
+func EnsureImageVariants(name string) error {
+	var img image.Image
+	for _, v := range ImageVariants {
+		dst := VariantFileName(name, v.Name)
+		if _, err := UploadStorage.Stat(dst); err == nil {
+			continue
+		}
+		if img == nil {
+			var err error
+			if img, err = loadImage(name); err != nil {
+				return err
+			}
+		}
+		if err := writeVariant(img, v, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureImageVariant 确保单个变体存在（旧文件按需生成），返回变体文件名
+func EnsureImageVariant(name, variant string) (string, error) {
+	v, ok := LookupImageVariant(variant)
+	if !ok {
+		return "", fmt.Errorf("unknown image variant: %s", variant)
+	}
+	if !IsImageFile(name) || IsVariantFile(name) {
+		return "", fmt.Errorf("not an original image: %s", name)
+	}
+	variantName := VariantFileName(name, v.Name)
+	if _, err := UploadStorage.Stat(variantName); err == nil {
+		return variantName, nil
+	}
+
+	img, err := loadImage(name)
+	if err != nil {
+		return "", err
+	}
+	if err := writeVariant(img, v, variantName); err != nil {
+		return "", err
+	}
+	return variantName, nil
+}
+
+// loadImage 从存储读取并解码图片，尺寸超限的文件拒绝处理
+func loadImage(name string) (image.Image, error) {
+	f, _, err := UploadStorage.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > MaxImagePixels {
+		return nil, fmt.Errorf("image too large: %dx%d", cfg.Width, cfg.Height)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// writeVariant 缩放并编码为 JPEG 后写入存储
+func writeVariant(img image.Image, v ImageVariant, dst string) error {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, resizeToWidth(img, v.MaxWidth), &jpeg.Options{Quality: v.Quality}); err != nil {
+		return err
+	}
+	return UploadStorage.Put(dst, &buf, int64(buf.Len()), "image/jpeg")
+}
+
+// resizeToWidth 按宽度等比缩小（不放大），透明区域铺白底以便输出 JPEG
+func resizeToWidth(src image.Image, maxWidth int) *image.RGBA {
+	b := src.Bounds()
+
+	// 统一转为 RGBA 并铺白底
+	flat := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), src, b.Min, draw.Over)
+
+	if b.Dx() <= maxWidth {
+		return flat
+	}
+
+	dw := maxWidth
+	dh := b.Dy() * maxWidth / b.Dx()
+	if dh < 1 {
+		dh = 1
+	}
+	return boxResize(flat, dw, dh)
+}
+
+// boxResize 区域平均缩小，每个目标像素取对应源区域的平均值
+func boxResize(src *image.RGBA, dw, dh int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for dy := 0; dy < dh; dy++ {
+		y0 := dy * sh / dh
+		y1 := (dy + 1) * sh / dh
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for dx := 0; dx < dw; dx++ {
+			x0 := dx * sw / dw
+			x1 := (dx + 1) * sw / dw
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, bl, a, n uint32
+			for y := y0; y < y1; y++ {
+				off := y*src.Stride + x0*4
+				for x := x0; x < x1; x++ {
+					r += uint32(src.Pix[off])
+					g += uint32(src.Pix[off+1])
+					bl += uint32(src.Pix[off+2])
+					a += uint32(src.Pix[off+3])
+					off += 4
+					n++
+				}
+			}
+			i := dy*dst.Stride + dx*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(bl / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// ---------------- 列表缩略图 ----------------
+
+// ThumbnailURL 为站内上传的图片地址生成缩略图地址，外部图片原样返回
+func ThumbnailURL(u string) string {
+	return VariantURL(u, "thumb")
+}
+
+// VariantURL 为站内上传的图片地址附加变体参数
+func VariantURL(u, variant string) string {
+	if u == "" || !strings.Contains(u, "/uploads/") || strings.Contains(u, "?") {
+		return u
+	}
+	if !IsImageFile(u) {
+		return u
+	}
+	return u + "?variant=" + variant
+}
+
+// Thumbnailed 列表中带封面图的内容
+type Thumbnailed interface {
+	coverImage() string
+	setThumbnail(url string)
+}
+
+func (n *News) coverImage() string         { return FirstImage(n.Image) }
+func (f *Farmhouse) coverImage() string    { return FirstImage(f.Image, f.Images) }
+func (p *Policy) coverImage() string       { return FirstImage(p.Image, p.Images) }
+func (t *Tourism) coverImage() string      { return FirstImage(t.Image, t.Images) }
+func (j *Job) coverImage() string          { return FirstImage(j.Logo) }
+func (h *Help) coverImage() string         { return FirstImage(h.Image, h.Images) }
+func (c *Consultation) coverImage() string { return FirstImage(c.Images) }
+
+func (n *News) setThumbnail(u string)         { n.Thumbnail = u }
+func (f *Farmhouse) setThumbnail(u string)    { f.Thumbnail = u }
+func (p *Policy) setThumbnail(u string)       { p.Thumbnail = u }
+func (t *Tourism) setThumbnail(u string)      { t.Thumbnail = u }
+func (j *Job) setThumbnail(u string)          { j.Thumbnail = u }
+func (h *Help) setThumbnail(u string)         { h.Thumbnail = u }
+func (c *Consultation) setThumbnail(u string) { c.Thumbnail = u }
+
+// AnnotateThumbnails 为列表中的每条内容填充缩略图地址
+func AnnotateThumbnails[T any, PT interface {
+	*T
+	Thumbnailed
+}](items []T) {
+	for i := range items {
+		p := PT(&items[i])
+		p.setThumbnail(ThumbnailURL(p.coverImage()))
+	}
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// workers 正在运行的后台任务，退出时等待当前一轮处理完成，避免中断数据库写入
+var workers sync.WaitGroup
+
+// Close 等待后台任务退出（调用前需关闭各任务的 stop 通道），然后合并 WAL 并关闭数据库
+// ctx 到期时不再等待后台任务，直接关闭数据库
+func Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("timed out waiting for background workers", "error", ctx.Err())
+	}
+
+	if DB == nil {
+		return nil
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	// 将 WAL 中的内容写回数据库文件，下次启动无需恢复
+	if _, err := sqlDB.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		slog.Warn("WAL checkpoint failed", "error", err)
+	}
+	return sqlDB.Close()
+}
@@ -0,0 +1,424 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 指标以 Prometheus 文本格式（0.0.4）输出，不依赖 Prometheus 客户端库：
+// 指标在内存中累计，WriteMetrics 将当前值写入任意 io.Writer，可直接在测试中校验输出
+
+// 默认的耗时分桶（秒）
+var (
+	DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	DBBuckets  = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+)
+
+// Metrics 全局指标注册表
+var Metrics = NewMetricsRegistry()
+
+// MetricsRegistry 指标注册表，按注册名称排序输出
+type MetricsRegistry struct {
+	mu         sync.Mutex
+	collectors map[string]collector
+}
+
+type collector interface {
+	write(w io.Writer, name string) error
+}
+
+// NewMetricsRegistry 创建空的指标注册表
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{collectors: map[string]collector{}}
+}
+
+func (reg *MetricsRegistry) register(name string, c collector) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, ok := reg.collectors[name]; ok {
+		panic("metrics: duplicate metric " + name)
+	}
+	reg.collectors[name] = c
+}
+
+// WriteMetrics 以 Prometheus 文本格式输出所有指标
+func (reg *MetricsRegistry) WriteMetrics(w io.Writer) error {
+	reg.mu.Lock()
+	names := make([]string, 0, len(reg.collectors))
+	for name := range reg.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	collectors := make([]collector, len(names))
+	for i, name := range names {
+		collectors[i] = reg.collectors[name]
+	}
+	reg.mu.Unlock()
+
+	for i, name := range names {
+		if err := collectors[i].write(w, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ---------------- 计数器和仪表 ----------------
+
+// MetricVec 带标签的计数器或仪表，每组标签值对应一个时间序列
+type MetricVec struct {
+	kind   string // counter 或 gauge
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+}
+
+// NewCounter 注册只增不减的计数器
+func (reg *MetricsRegistry) NewCounter(name, help string, labels ...string) *MetricVec {
+	v := &MetricVec{kind: "counter", help: help, labels: labels, values: map[string]*series{}}
+	reg.register(name, v)
+	return v
+}
+
+// NewGauge 注册可增可减的仪表
+func (reg *MetricsRegistry) NewGauge(name, help string, labels ...string) *MetricVec {
+	v := &MetricVec{kind: "gauge", help: help, labels: labels, values: map[string]*series{}}
+	reg.register(name, v)
+	return v
+}
+
+func (v *MetricVec) get(labelValues []string) *series {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: expected %d label values, got %d", len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.values[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		v.values[key] = s
+	}
+	return s
+}
+
+// Add 增加指定标签的值，计数器只能增加
+func (v *MetricVec) Add(delta float64, labelValues ...string) {
+	if v.kind == "counter" && delta < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	v.mu.Lock()
+	v.get(labelValues).value += delta
+	v.mu.Unlock()
+}
+
+// Inc 指定标签的值加一
+func (v *MetricVec) Inc(labelValues ...string) {
+	v.Add(1, labelValues...)
+}
+
+// Dec 仪表指定标签的值减一
+func (v *MetricVec) Dec(labelValues ...string) {
+	v.Add(-1, labelValues...)
+}
+
+// Set 设置仪表指定标签的值
+func (v *MetricVec) Set(value float64, labelValues ...string) {
+	v.mu.Lock()
+	v.get(labelValues).value = value
+	v.mu.Unlock()
+}
+
+// Value 获取指定标签的当前值
+func (v *MetricVec) Value(labelValues ...string) float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok := v.values[strings.Join(labelValues, "\xff")]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (v *MetricVec) write(w io.Writer, name string) error {
+	v.mu.Lock()
+	list := make([]series, 0, len(v.values))
+	for _, s := range v.values {
+		list = append(list, *s)
+	}
+	v.mu.Unlock()
+	sortSeries(list, func(s series) []string { return s.labelValues })
+
+	if err := writeHeader(w, name, v.help, v.kind); err != nil {
+		return err
+	}
+	for _, s := range list {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(v.labels, s.labelValues), formatValue(s.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ---------------- 直方图 ----------------
+
+// HistogramVec 带标签的直方图，记录观测值的分桶计数、总和与次数
+type HistogramVec struct {
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64 // 与 buckets 一一对应，不累计
+	sum         float64
+	count       uint64
+}
+
+// NewHistogram 注册直方图，buckets 为升序的分桶上界（不含 +Inf）
+func (reg *MetricsRegistry) NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{help: help, labels: labels, buckets: buckets, values: map[string]*histogramSeries{}}
+	reg.register(name, h)
+	return h
+}
+
+// Observe 记录一次观测值
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	if len(labelValues) != len(h.labels) {
+		panic(fmt.Sprintf("metrics: expected %d label values, got %d", len(h.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.values[key]
+	if !ok {
+		s = &histogramSeries{labelValues: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += value
+	s.count++
+}
+
+func (h *HistogramVec) write(w io.Writer, name string) error {
+	h.mu.Lock()
+	list := make([]histogramSeries, 0, len(h.values))
+	for _, s := range h.values {
+		c := *s
+		c.counts = append([]uint64(nil), s.counts...)
+		list = append(list, c)
+	}
+	h.mu.Unlock()
+	sortSeries(list, func(s histogramSeries) []string { return s.labelValues })
+
+	if err := writeHeader(w, name, h.help, "histogram"); err != nil {
+		return err
+	}
+	bucketLabels := append(append([]string(nil), h.labels...), "le")
+	for _, s := range list {
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			lv := append(append([]string(nil), s.labelValues...), formatValue(upper))
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatLabels(bucketLabels, lv), cumulative); err != nil {
+				return err
+			}
+		}
+		lv := append(append([]string(nil), s.labelValues...), "+Inf")
+		labels := formatLabels(h.labels, s.labelValues)
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			name, formatLabels(bucketLabels, lv), s.count,
+			name, labels, formatValue(s.sum),
+			name, labels, s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ---------------- 抓取时计算的指标 ----------------
+
+// GaugeFunc 抓取时调用 fn 计算的仪表，fn 返回 标签值 -> 数值（无标签时键为空字符串）
+type GaugeFunc struct {
+	help  string
+	label string
+	fn    func() map[string]float64
+}
+
+// NewGaugeFunc 注册抓取时计算的仪表，label 为空表示无标签
+func (reg *MetricsRegistry) NewGaugeFunc(name, help, label string, fn func() map[string]float64) {
+	reg.register(name, &GaugeFunc{help: help, label: label, fn: fn})
+}
+
+func (g *GaugeFunc) write(w io.Writer, name string) error {
+	values := g.fn()
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if err := writeHeader(w, name, g.help, "gauge"); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		labels := ""
+		if g.label != "" {
+			labels = formatLabels([]string{g.label}, []string{k})
+		}
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", name, labels, formatValue(values[k])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ---------------- 格式化 ----------------
+
+func writeHeader(w io.Writer, name, help, kind string) error {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	return err
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(labelValueEscaper.Replace(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortSeries[T any](list []T, labels func(T) []string) {
+	sort.Slice(list, func(i, j int) bool {
+		return strings.Join(labels(list[i]), "\xff") < strings.Join(labels(list[j]), "\xff")
+	})
+}
+
+// ---------------- 数据库和业务指标 ----------------
+
+var (
+	dbQueryDuration = Metrics.NewHistogram("db_query_duration_seconds", "SQLite 查询耗时（秒），按操作类型统计", DBBuckets, "operation")
+	dbQueryErrors   = Metrics.NewCounter("db_query_errors_total", "SQLite 查询出错次数（不含记录不存在），按操作类型统计", "operation")
+)
+
+func init() {
+	Metrics.NewGaugeFunc("content_items", "各内容模块当前的内容条数（不含已删除）", "module", contentItemCounts)
+	Metrics.NewGaugeFunc("registered_users", "注册用户数，按角色统计", "role", registeredUserCounts)
+}
+
+const dbMetricsStartKey = "metrics:start"
+
+// instrumentDB 通过 GORM 回调统计每条 SQL 的耗时和错误
+func instrumentDB(db *gorm.DB) error {
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(dbMetricsStartKey, time.Now())
+	}
+	after := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			start, ok := tx.InstanceGet(dbMetricsStartKey)
+			if !ok {
+				return
+			}
+			dbQueryDuration.Observe(time.Since(start.(time.Time)).Seconds(), operation)
+			if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+				dbQueryErrors.Inc(operation)
+			}
+		}
+	}
+
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("metrics:before_create", before),
+		cb.Create().After("gorm:create").Register("metrics:after_create", after("create")),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", before),
+		cb.Query().After("gorm:query").Register("metrics:after_query", after("query")),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", before),
+		cb.Update().After("gorm:update").Register("metrics:after_update", after("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", before),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", after("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", before),
+		cb.Row().After("gorm:row").Register("metrics:after_row", after("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", before),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", after("raw")),
+	)
+}
+
+// contentItemCounts 抓取时统计各内容模块的条数，查询失败的模块不输出
+func contentItemCounts() map[string]float64 {
+	counts := map[string]float64{}
+	if DB == nil {
+		return counts
+	}
+	for name, m := range contentModels {
+		var n int64
+		if err := DB.Model(m.New()).Count(&n).Error; err == nil {
+			counts[name] = float64(n)
+		}
+	}
+	return counts
+}
+
+// registeredUserCounts 抓取时按角色统计注册用户数
+func registeredUserCounts() map[string]float64 {
+	counts := map[string]float64{}
+	if DB == nil {
+		return counts
+	}
+	var rows []struct {
+		Role  string
+		Count int64
+	}
+	if err := DB.Model(&User{}).Select("role, COUNT(*) AS count").Group("role").Scan(&rows).Error; err != nil {
+		return counts
+	}
+	for _, row := range rows {
+		counts[row.Role] = float64(row.Count)
+	}
+	return counts
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteMetricsExposition(t *testing.T) {
+	reg := NewMetricsRegistry()
+	requests := reg.NewCounter("requests_total", "请求次数", "method", "path")
+	requests.Inc("POST", "/x\ny")
+	requests.Add(2.5, "POST", "/x\ny")
+	requests.Inc("GET", `/a"b\c`)
+	inFlight := reg.NewGauge("in_flight", "进行中\n的请求")
+	inFlight.Inc()
+	inFlight.Inc()
+	inFlight.Dec()
+	duration := reg.NewHistogram("duration_seconds", "耗时", []float64{0.25, 1}, "op")
+	for _, v := range []float64{0.125, 0.25, 0.5, 4} {
+		duration.Observe(v, "query")
+	}
+	reg.NewGaugeFunc("items", "条数", "module", func() map[string]float64 {
+		return map[string]float64{"news": 3, "jobs": 1}
+	})
+	reg.NewGaugeFunc("uptime_seconds", "运行时间", "", func() map[string]float64 {
+		return map[string]float64{"": 12}
+	})
+
+	var buf bytes.Buffer
+	if err := reg.WriteMetrics(&buf); err != nil {
+		t.Fatalf("WriteMetrics: %v", err)
+	}
+	want := `# HELP duration_seconds 耗时
+# TYPE duration_seconds histogram
+duration_seconds_bucket{op="query",le="0.25"} 2
+duration_seconds_bucket{op="query",le="1"} 3
+duration_seconds_bucket{op="query",le="+Inf"} 4
+duration_seconds_sum{op="query"} 4.875
+duration_seconds_count{op="query"} 4
+# HELP in_flight 进行中\n的请求
+# TYPE in_flight gauge
+in_flight 1
+# HELP items 条数
+# TYPE items gauge
+items{module="jobs"} 1
+items{module="news"} 3
+# HELP requests_total 请求次数
+# TYPE requests_total counter
+requests_total{method="GET",path="/a\"b\\c"} 1
+requests_total{method="POST",path="/x\ny"} 3.5
+# HELP uptime_seconds 运行时间
+# TYPE uptime_seconds gauge
+uptime_seconds 12
+`
+	if got := buf.String(); got != want {
+		t.Errorf("exposition mismatch\n got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMetricVecValue(t *testing.T) {
+	reg := NewMetricsRegistry()
+	g := reg.NewGauge("queue_length", "队列长度", "queue")
+	g.Set(7, "outbox")
+	g.Add(-2, "outbox")
+	if got := g.Value("outbox"); got != 5 {
+		t.Errorf("Value(outbox) = %v, want 5", got)
+	}
+	if got := g.Value("other"); got != 0 {
+		t.Errorf("Value of unseen labels = %v, want 0", got)
+	}
+}
+
+func TestMetricsRegistryMisuse(t *testing.T) {
+	reg := NewMetricsRegistry()
+	counter := reg.NewCounter("events_total", "事件数", "kind")
+	hist := reg.NewHistogram("latency_seconds", "耗时", DefBuckets, "op")
+
+	for name, fn := range map[string]func(){
+		"duplicate name":         func() { reg.NewGauge("events_total", "重复") },
+		"counter decrease":       func() { counter.Add(-1, "a") },
+		"counter label count":    func() { counter.Inc() },
+		"histogram label count":  func() { hist.Observe(1, "a", "b") },
+		"duplicate across kinds": func() { reg.NewGaugeFunc("latency_seconds", "重复", "", nil) },
+		"counter dec":            func() { counter.Dec("a") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s did not panic", name)
+				}
+			}()
+			fn()
+		})
+	}
+}
+
+// metricLine 返回指标输出中指定序列的值，不存在时返回 0
+// 只输出单个指标，避免抓取时计算的指标本身产生的查询影响数据库统计
+func metricLine(t *testing.T, c collector, name, series string) float64 {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := c.write(&buf, name); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if v, ok := strings.CutPrefix(line, series+" "); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				t.Fatalf("parse %q: %v", line, err)
+			}
+			return f
+		}
+	}
+	return 0
+}
+
+func TestInstrumentDB(t *testing.T) {
+	setupTestDB(t)
+	DB.Create(&News{Title: "n"})
+
+	queries := metricLine(t, dbQueryDuration, "db_query_duration_seconds", `db_query_duration_seconds_count{operation="query"}`)
+	queryErrors := dbQueryErrors.Value("query")
+	rawErrors := dbQueryErrors.Value("raw")
+
+	var n News
+	if err := DB.First(&n).Error; err != nil {
+		t.Fatalf("First: %v", err)
+	}
+	DB.First(&n, 999999) // 记录不存在不计为错误
+	DB.Exec("SELECT * FROM no_such_table")
+
+	if got := metricLine(t, dbQueryDuration, "db_query_duration_seconds", `db_query_duration_seconds_count{operation="query"}`); got != queries+2 {
+		t.Errorf("query count = %v, want %v", got, queries+2)
+	}
+	if got := dbQueryErrors.Value("query"); got != queryErrors {
+		t.Errorf("query errors = %v, want %v (not found is not an error)", got, queryErrors)
+	}
+	if got := dbQueryErrors.Value("raw"); got != rawErrors+1 {
+		t.Errorf("raw errors = %v, want %v", got, rawErrors+1)
+	}
+}
+
+func TestContentAndUserGauges(t *testing.T) {
+	setupTestDB(t)
+	DB.Create(&News{Title: "a"})
+	n := News{Title: "b"}
+	DB.Create(&n)
+	DB.Delete(&n)
+	DB.Create(&User{WechatID: "u1", Role: "user"})
+	DB.Create(&User{WechatID: "u2", Role: "user"})
+
+	if got := metricLine(t, Metrics.collectors["content_items"], "content_items", `content_items{module="news"}`); got != 1 {
+		t.Errorf(`content_items{module="news"} = %v, want 1 (deleted items excluded)`, got)
+	}
+	if got := metricLine(t, Metrics.collectors["registered_users"], "registered_users", `registered_users{role="user"}`); got != 2 {
+		t.Errorf(`registered_users{role="user"} = %v, want 2`, got)
+	}
+}
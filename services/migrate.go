@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SchemaMigration 已执行的数据迁移记录
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey" json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// migration 一次性数据迁移（表结构由 AutoMigrate 负责，这里只处理数据搬迁）
+type migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+}
+
+// 按版本号顺序执行，已发布的迁移不要修改，新增迁移追加到末尾
+var migrations = []migration{
+	{Version: 1, Name: "favorites_from_user_json", Up: migrateFavoritesFromJSON},
+	{Version: 2, Name: "index_existing_uploads", Up: indexExistingUploads},
+	{Version: 3, Name: "strip_upload_metadata", Up: stripExistingUploadMetadata},
+	{Version: 4, Name: "relativize_upload_urls", Up: relativizeStoredUploadURLs},
+	{Version: 5, Name: "backfill_content_updated_at", Up: backfillContentUpdatedAt},
+}
+
+// runMigrations 执行尚未应用的数据迁移
+func runMigrations() error {
+	if err := DB.AutoMigrate(&SchemaMigration{}); err != nil {
+		return err
+	}
+
+	var applied []int
+	if err := DB.Model(&SchemaMigration{}).Pluck("version", &applied).Error; err != nil {
+		return err
+	}
+	done := make(map[int]bool, len(applied))
+	for _, v := range applied {
+		done[v] = true
+	}
+
+	for _, m := range migrations {
+		if done[m.Version] {
+			continue
+		}
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		slog.Info("migration applied", "version", m.Version, "name", m.Name)
+	}
+	return nil
+}
+
+// SchemaVersion 返回当前已应用的最高迁移版本
+func SchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := DB.WithContext(ctx).Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// LatestSchemaVersion 返回代码中定义的最新迁移版本
+func LatestSchemaVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
@@ -0,0 +1,568 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 订阅消息事件类型
+const (
+	EventConsultationAnswered = "consultation_answered" // 咨询已回复
+	EventBookingConfirmed     = "booking_confirmed"     // 预订已确认（预留，待预订功能上线后发送）
+	EventUrgentHelpNearby     = "urgent_help_nearby"    // 附近紧急求助
+)
+
+// 发件箱状态
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+)
+
+// SubscribeConsent 用户对订阅消息模板的授权记录
+// 微信一次性订阅消息每授权一次只能下发一条，Remaining 记录剩余可发送次数
+type SubscribeConsent struct {
+	ID         int    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     string `gorm:"uniqueIndex:idx_consent_user_tpl;not null" json:"user_id"` // wechat_id
+	TemplateID string `gorm:"uniqueIndex:idx_consent_user_tpl;not null" json:"template_id"`
+	Status     string `json:"status"` // accept, reject, ban
+	Remaining  int    `json:"remaining"`
+	// 用户授权时上报的位置，用于附近紧急求助通知；LocatedAt 为空表示未上报
+	Latitude  float64    `json:"latitude"`
+	Longitude float64    `json:"longitude"`
+	LocatedAt *time.Time `json:"located_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// SubscribeLocation 用户接收附近通知的位置
+type SubscribeLocation struct {
+	Latitude  float64 `json:"latitude" validate:"min=-90,max=90"`
+	Longitude float64 `json:"longitude" validate:"min=-180,max=180"`
+}
+
+// NotifyOutbox 订阅消息发件箱，失败的消息按退避策略重试
+type NotifyOutbox struct {
+	ID            int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID        string     `gorm:"index" json:"user_id"`
+	Event         string     `json:"event"`
+	TemplateID    string     `json:"template_id"`
+	Page          string     `json:"page"`
+	Payload       string     `json:"payload"` // 模板数据JSON
+	Status        string     `gorm:"index;default:'pending'" json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error"`
+	NextAttemptAt time.Time  `gorm:"index" json:"next_attempt_at"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// SubscribeMessage 待发送的订阅消息
+type SubscribeMessage struct {
+	ToUser     string            `json:"touser"`
+	TemplateID string            `json:"template_id"`
+	Page       string            `json:"page"`
+	Data       map[string]string `json:"data"`
+}
+
+// SubscribeSender 订阅消息发送接口，便于替换为本地假实现进行离线测试
+type SubscribeSender interface {
+	Send(msg *SubscribeMessage) error
+}
+
+// 事件 -> 模板ID 映射，未配置模板的事件不会入队
+var (
+	notifyTemplatesMu sync.RWMutex
+	notifyTemplates   = map[string]string{}
+)
+
+// 发件箱重试参数
+var (
+	OutboxMaxAttempts = 5
+	OutboxBaseBackoff = 30 * time.Second
+)
+
+// SetNotifyTemplate 配置事件对应的订阅消息模板ID
+func SetNotifyTemplate(event, templateID string) {
+	notifyTemplatesMu.Lock()
+	defer notifyTemplatesMu.Unlock()
+	if templateID == "" {
+		delete(notifyTemplates, event)
+		return
+	}
+	notifyTemplates[event] = templateID
+}
+
+// GetNotifyTemplates 返回当前配置的事件模板映射（供小程序发起订阅授权）
+func GetNotifyTemplates() map[string]string {
+	notifyTemplatesMu.RLock()
+	defer notifyTemplatesMu.RUnlock()
+	result := make(map[string]string, len(notifyTemplates))
+	for k, v := range notifyTemplates {
+		result[k] = v
+	}
+	return result
+}
+
+func templateForEvent(event string) string {
+	notifyTemplatesMu.RLock()
+	defer notifyTemplatesMu.RUnlock()
+	return notifyTemplates[event]
+}
+
+// RecordSubscribeConsent 记录用户对模板的授权结果
+// status 为 wx.requestSubscribeMessage 返回的 accept / reject / ban
+func RecordSubscribeConsent(wechatID, templateID, status string) error {
+	if wechatID == "" || templateID == "" {
+		return errors.New("wechat_id 和 template_id 不能为空")
+	}
+	switch status {
+	case "accept", "reject", "ban":
+	default:
+		return fmt.Errorf("invalid consent status: %s", status)
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var consent SubscribeConsent
+		err := tx.Where("user_id = ? AND template_id = ?", wechatID, templateID).First(&consent).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			consent = SubscribeConsent{
+				UserID:     wechatID,
+				TemplateID: templateID,
+				Status:     status,
+				CreatedAt:  time.Now(),
+				UpdatedAt:  time.Now(),
+			}
+			if status == "accept" {
+				consent.Remaining = 1
+			}
+			return tx.Create(&consent).Error
+		} else if err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"status":     status,
+			"updated_at": time.Now(),
+		}
+		if status == "accept" {
+			// 每次授权累加一次可发送次数
+			updates["remaining"] = gorm.Expr("remaining + ?", 1)
+		} else {
+			updates["remaining"] = 0
+		}
+		return tx.Model(&consent).Updates(updates).Error
+	})
+}
+
+// GetUserSubscribeConsents 获取用户的订阅授权记录
+func GetUserSubscribeConsents(wechatID string) ([]SubscribeConsent, error) {
+	var consents []SubscribeConsent
+	err := DB.Where("user_id = ?", wechatID).Order("updated_at DESC").Find(&consents).Error
+	return consents, err
+}
+
+// UpdateSubscribeLocation 记录用户接收附近通知的位置（保存在该用户的所有授权记录上）
+func UpdateSubscribeLocation(wechatID string, loc SubscribeLocation) error {
+	if err := Validate(&loc); err != nil {
+		return err
+	}
+	return DB.Model(&SubscribeConsent{}).Where("user_id = ?", wechatID).Updates(map[string]interface{}{
+		"latitude":   loc.Latitude,
+		"longitude":  loc.Longitude,
+		"located_at": time.Now(),
+	}).Error
+}
+
+// EnqueueNotification 为用户的事件写入发件箱
+// 用户未授权或授权次数已用完时直接跳过，返回 false
+func EnqueueNotification(event, wechatID, page string, data map[string]string) (bool, error) {
+	templateID := templateForEvent(event)
+	if templateID == "" || wechatID == "" {
+		return false, nil
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return false, err
+	}
+
+	queued := false
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		// 原子扣减授权次数，避免并发重复下发
+		res := tx.Model(&SubscribeConsent{}).
+			Where("user_id = ? AND template_id = ? AND status = ? AND remaining > 0", wechatID, templateID, "accept").
+			UpdateColumn("remaining", gorm.Expr("remaining - ?", 1))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+
+		item := NotifyOutbox{
+			UserID:        wechatID,
+			Event:         event,
+			TemplateID:    templateID,
+			Page:          page,
+			Payload:       string(payload),
+			Status:        OutboxPending,
+			NextAttemptAt: time.Now(),
+			CreatedAt:     time.Now(),
+		}
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+		queued = true
+		return nil
+	})
+	return queued, err
+}
+
+// BroadcastNotification 向所有授权了该事件模板的用户写入发件箱，返回入队数量
+func BroadcastNotification(event, page string, data map[string]string, excludeUserID string) (int, error) {
+	return broadcast(event, page, data, excludeUserID, nil)
+}
+
+// BroadcastNearby 只通知上报位置在 (lat, lng) 周围 radiusKm 公里内的授权用户，未上报位置的用户不通知
+func BroadcastNearby(event, page string, data map[string]string, excludeUserID string, lat, lng, radiusKm float64) (int, error) {
+	return broadcast(event, page, data, excludeUserID, func(c *SubscribeConsent) bool {
+		return c.LocatedAt != nil && DistanceKm(lat, lng, c.Latitude, c.Longitude) <= radiusKm
+	})
+}
+
+// broadcast 向授权了事件模板且满足 match 的用户写入发件箱，match 为 nil 时不筛选
+func broadcast(event, page string, data map[string]string, excludeUserID string, match func(*SubscribeConsent) bool) (int, error) {
+	templateID := templateForEvent(event)
+	if templateID == "" {
+		return 0, nil
+	}
+
+	var consents []SubscribeConsent
+	if err := DB.Where("template_id = ? AND status = ? AND remaining > 0", templateID, "accept").
+		Find(&consents).Error; err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := range consents {
+		c := &consents[i]
+		if c.UserID == excludeUserID || match != nil && !match(c) {
+			continue
+		}
+		ok, err := EnqueueNotification(event, c.UserID, page, data)
+		if err != nil {
+			return count, err
+		}
+		if ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// DistanceKm 两个经纬度坐标之间的球面距离（公里）
+func DistanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// ProcessOutbox 发送到期的待发消息，返回成功发送的数量
+func ProcessOutbox(sender SubscribeSender, batchSize int) (int, error) {
+	var items []NotifyOutbox
+	err := DB.Where("status = ? AND next_attempt_at <= ?", OutboxPending, time.Now()).
+		Order("next_attempt_at asc").Limit(batchSize).Find(&items).Error
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range items {
+		item := &items[i]
+
+		var data map[string]string
+		if err := json.Unmarshal([]byte(item.Payload), &data); err != nil {
+			markOutboxFailed(item, fmt.Sprintf("invalid payload: %v", err), true)
+			continue
+		}
+
+		sendErr := sender.Send(&SubscribeMessage{
+			ToUser:     item.UserID,
+			TemplateID: item.TemplateID,
+			Page:       item.Page,
+			Data:       data,
+		})
+		if sendErr != nil {
+			permanent := false
+			var wxErr *WechatAPIError
+			if errors.As(sendErr, &wxErr) {
+				permanent = wxErr.Permanent()
+			}
+			markOutboxFailed(item, sendErr.Error(), permanent)
+			continue
+		}
+
+		now := time.Now()
+		DB.Model(item).Updates(map[string]interface{}{
+			"status":     OutboxSent,
+			"attempts":   item.Attempts + 1,
+			"last_error": "",
+			"sent_at":    &now,
+		})
+		sent++
+	}
+	return sent, nil
+}
+
+// markOutboxFailed 记录失败并按指数退避安排下一次重试
+func markOutboxFailed(item *NotifyOutbox, reason string, permanent bool) {
+	attempts := item.Attempts + 1
+	updates := map[string]interface{}{
+		"attempts":   attempts,
+		"last_error": reason,
+	}
+	if permanent || attempts >= OutboxMaxAttempts {
+		updates["status"] = OutboxFailed
+	} else {
+		updates["next_attempt_at"] = time.Now().Add(OutboxBaseBackoff * time.Duration(1<<uint(attempts-1)))
+	}
+	if err := DB.Model(item).Updates(updates).Error; err != nil {
+		slog.Error("update outbox status failed", "id", item.ID, "error", err)
+	}
+}
+
+// RetryFailedOutbox 将失败的消息重新放回待发送队列
+func RetryFailedOutbox(id int) error {
+	res := DB.Model(&NotifyOutbox{}).Where("id = ? AND status = ?", id, OutboxFailed).Updates(map[string]interface{}{
+		"status":          OutboxPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("not found")
+	}
+	return nil
+}
+
+// GetOutbox 按状态分页查询发件箱
+func GetOutbox(status string, page, pageSize int) ([]NotifyOutbox, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var items []NotifyOutbox
+	var total int64
+
+	query := DB.Model(&NotifyOutbox{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	query.Count(&total)
+
+	offset := (page - 1) * pageSize
+	err := query.Offset(offset).Limit(pageSize).Order("id DESC").Find(&items).Error
+	return items, total, err
+}
+
+// StartOutboxWorker 启动后台发件箱投递协程，关闭 stop 通道即可停止
+func StartOutboxWorker(sender SubscribeSender, interval time.Duration, stop <-chan struct{}) {
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if n, err := ProcessOutbox(sender, 50); err != nil {
+					slog.Error("outbox delivery failed", "error", err)
+				} else if n > 0 {
+					slog.Info("outbox delivered", "count", n)
+				}
+			}
+		}
+	}()
+}
+
+// ---------------- 微信订阅消息发送实现 ----------------
+
+// WechatAPIError 微信接口返回的业务错误
+type WechatAPIError struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (e *WechatAPIError) Error() string {
+	return fmt.Sprintf("wechat api error %d: %s", e.ErrCode, e.ErrMsg)
+}
+
+// Permanent 判断错误是否不可重试
+// 43101: 用户拒绝接受消息; 40003: openid无效; 47003: 模板参数不准确; 40037: 模板ID无效
+func (e *WechatAPIError) Permanent() bool {
+	switch e.ErrCode {
+	case 43101, 40003, 47003, 40037:
+		return true
+	}
+	return false
+}
+
+// WechatSubscribeSender 通过微信开放接口发送订阅消息
+type WechatSubscribeSender struct {
+	AppID     string
+	AppSecret string
+	// MiniprogramState 跳转小程序类型: developer, trial, formal
+	MiniprogramState string
+	BaseURL          string
+	Client           *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewWechatSubscribeSender 创建微信订阅消息发送器
+func NewWechatSubscribeSender(appID, appSecret string) *WechatSubscribeSender {
+	return &WechatSubscribeSender{
+		AppID:            appID,
+		AppSecret:        appSecret,
+		MiniprogramState: "formal",
+		BaseURL:          "https://api.weixin.qq.com",
+		Client:           &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// token 获取并缓存 access_token，提前5分钟刷新
+func (s *WechatSubscribeSender) token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accessToken != "" && time.Now().Before(s.expiresAt) {
+		return s.accessToken, nil
+	}
+
+	q := url.Values{}
+	q.Set("grant_type", "client_credential")
+	q.Set("appid", s.AppID)
+	q.Set("secret", s.AppSecret)
+	resp, err := s.Client.Get(s.BaseURL + "/cgi-bin/token?" + q.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		WechatAPIError
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if result.ErrCode != 0 || result.AccessToken == "" {
+		return "", &WechatAPIError{ErrCode: result.ErrCode, ErrMsg: result.ErrMsg}
+	}
+
+	s.accessToken = result.AccessToken
+	s.expiresAt = time.Now().Add(time.Duration(result.ExpiresIn)*time.Second - 5*time.Minute)
+	return s.accessToken, nil
+}
+
+// Send 发送订阅消息
+func (s *WechatSubscribeSender) Send(msg *SubscribeMessage) error {
+	token, err := s.token()
+	if err != nil {
+		return err
+	}
+
+	data := make(map[string]map[string]string, len(msg.Data))
+	for k, v := range msg.Data {
+		data[k] = map[string]string{"value": v}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"touser":            msg.ToUser,
+		"template_id":       msg.TemplateID,
+		"page":              msg.Page,
+		"data":              data,
+		"miniprogram_state": s.MiniprogramState,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.Client.Post(s.BaseURL+"/cgi-bin/message/subscribe/send?access_token="+url.QueryEscape(token), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result WechatAPIError
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.ErrCode != 0 {
+		// access_token 失效时清空缓存，下次重试重新获取
+		if result.ErrCode == 40001 || result.ErrCode == 42001 {
+			s.mu.Lock()
+			s.accessToken = ""
+			s.mu.Unlock()
+		}
+		return &result
+	}
+	return nil
+}
+
+// ---------------- 本地假实现 ----------------
+
+// FakeSubscribeSender 本地假发送器，只记录消息，用于离线开发和测试
+type FakeSubscribeSender struct {
+	mu   sync.Mutex
+	Sent []SubscribeMessage
+	// FailNext 设置后接下来的 N 次发送返回 Err
+	FailNext int
+	Err      error
+}
+
+// Send 记录消息，按 FailNext 模拟失败
+func (f *FakeSubscribeSender) Send(msg *SubscribeMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.FailNext > 0 {
+		f.FailNext--
+		if f.Err != nil {
+			return f.Err
+		}
+		return errors.New("fake sender failure")
+	}
+	f.Sent = append(f.Sent, *msg)
+	slog.Info("fake subscribe message sent", "to_user", msg.ToUser, "template_id", msg.TemplateID, "data", msg.Data)
+	return nil
+}
+
+// Messages 返回已记录的消息副本
+func (f *FakeSubscribeSender) Messages() []SubscribeMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]SubscribeMessage(nil), f.Sent...)
+}
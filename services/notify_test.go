@@ -0,0 +1,194 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// setupNotify 初始化数据库并为事件配置模板，测试结束后清除模板
+func setupNotify(t *testing.T, event, templateID string) {
+	t.Helper()
+	setupTestDB(t)
+	SetNotifyTemplate(event, templateID)
+	t.Cleanup(func() { SetNotifyTemplate(event, "") })
+}
+
+func TestEnqueueNotificationConsumesConsent(t *testing.T) {
+	setupNotify(t, EventConsultationAnswered, "tpl-answer")
+
+	// 未授权时不入队
+	if ok, err := EnqueueNotification(EventConsultationAnswered, "wx1", "p", nil); err != nil || ok {
+		t.Fatalf("without consent: queued %v, err %v", ok, err)
+	}
+
+	if err := RecordSubscribeConsent("wx1", "tpl-answer", "accept"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := EnqueueNotification(EventConsultationAnswered, "wx1", "p", map[string]string{"thing1": "t"}); err != nil || !ok {
+		t.Fatalf("with consent: queued %v, err %v", ok, err)
+	}
+	// 一次授权只能下发一条
+	if ok, _ := EnqueueNotification(EventConsultationAnswered, "wx1", "p", nil); ok {
+		t.Errorf("second message queued with a single consent")
+	}
+
+	items, total, err := GetOutbox(OutboxPending, 1, 10)
+	if err != nil || total != 1 || items[0].UserID != "wx1" || items[0].TemplateID != "tpl-answer" {
+		t.Errorf("outbox = %+v (total %d, err %v)", items, total, err)
+	}
+}
+
+func TestProcessOutboxRetriesWithBackoff(t *testing.T) {
+	setupNotify(t, EventBookingConfirmed, "tpl-booking")
+	RecordSubscribeConsent("wx1", "tpl-booking", "accept")
+	EnqueueNotification(EventBookingConfirmed, "wx1", "pages/farmhouse/detail?id=1", map[string]string{"date2": "2026-10-20"})
+
+	sender := &FakeSubscribeSender{FailNext: 1}
+	sent, err := ProcessOutbox(sender, 10)
+	if err != nil || sent != 0 {
+		t.Fatalf("first attempt: sent %d, err %v", sent, err)
+	}
+	var item NotifyOutbox
+	DB.First(&item)
+	if item.Status != OutboxPending || item.Attempts != 1 || !item.NextAttemptAt.After(time.Now()) || item.LastError == "" {
+		t.Fatalf("after failure = %+v, want pending with a later retry", item)
+	}
+
+	// 未到重试时间不发送
+	if sent, _ := ProcessOutbox(sender, 10); sent != 0 {
+		t.Errorf("sent %d before the retry time", sent)
+	}
+
+	DB.Model(&item).UpdateColumn("next_attempt_at", time.Now().Add(-time.Second))
+	if sent, err := ProcessOutbox(sender, 10); err != nil || sent != 1 {
+		t.Fatalf("retry: sent %d, err %v", sent, err)
+	}
+	msgs := sender.Messages()
+	if len(msgs) != 1 || msgs[0].ToUser != "wx1" || msgs[0].TemplateID != "tpl-booking" || msgs[0].Data["date2"] != "2026-10-20" {
+		t.Errorf("messages = %+v", msgs)
+	}
+	DB.First(&item, item.ID)
+	if item.Status != OutboxSent || item.SentAt == nil || item.Attempts != 2 {
+		t.Errorf("after retry = %+v, want sent", item)
+	}
+}
+
+func TestProcessOutboxPermanentFailure(t *testing.T) {
+	setupNotify(t, EventConsultationAnswered, "tpl-answer")
+	RecordSubscribeConsent("wx1", "tpl-answer", "accept")
+	EnqueueNotification(EventConsultationAnswered, "wx1", "p", nil)
+
+	// 用户拒收（43101）不再重试
+	sender := &FakeSubscribeSender{FailNext: 1, Err: &WechatAPIError{ErrCode: 43101, ErrMsg: "user refuse to accept the msg"}}
+	if _, err := ProcessOutbox(sender, 10); err != nil {
+		t.Fatal(err)
+	}
+	var item NotifyOutbox
+	DB.First(&item)
+	if item.Status != OutboxFailed {
+		t.Fatalf("status = %s, want failed", item.Status)
+	}
+
+	if err := RetryFailedOutbox(item.ID); err != nil {
+		t.Fatalf("RetryFailedOutbox: %v", err)
+	}
+	if sent, _ := ProcessOutbox(sender, 10); sent != 1 {
+		t.Errorf("requeued message not sent")
+	}
+	if err := RetryFailedOutbox(item.ID); err == nil {
+		t.Errorf("retrying a sent message succeeded")
+	}
+}
+
+func TestProcessOutboxGivesUpAfterMaxAttempts(t *testing.T) {
+	setupNotify(t, EventConsultationAnswered, "tpl-answer")
+	RecordSubscribeConsent("wx1", "tpl-answer", "accept")
+	EnqueueNotification(EventConsultationAnswered, "wx1", "p", nil)
+
+	sender := &FakeSubscribeSender{FailNext: OutboxMaxAttempts, Err: errors.New("network down")}
+	var item NotifyOutbox
+	for i := 0; i < OutboxMaxAttempts; i++ {
+		DB.Model(&NotifyOutbox{}).Where("1 = 1").UpdateColumn("next_attempt_at", time.Now().Add(-time.Second))
+		ProcessOutbox(sender, 10)
+	}
+	DB.First(&item)
+	if item.Status != OutboxFailed || item.Attempts != OutboxMaxAttempts || item.LastError != "network down" {
+		t.Errorf("after %d failures = %+v, want failed", OutboxMaxAttempts, item)
+	}
+}
+
+func TestBroadcastNearby(t *testing.T) {
+	setupNotify(t, EventUrgentHelpNearby, "tpl-help")
+	for _, id := range []string{"publisher", "near", "far", "unlocated"} {
+		RecordSubscribeConsent(id, "tpl-help", "accept")
+	}
+	// 求助位置约在 (30.00, 120.00)：near 约 3 公里，far 约 110 公里
+	UpdateSubscribeLocation("publisher", SubscribeLocation{Latitude: 30.0, Longitude: 120.0})
+	UpdateSubscribeLocation("near", SubscribeLocation{Latitude: 30.02, Longitude: 120.02})
+	UpdateSubscribeLocation("far", SubscribeLocation{Latitude: 31.0, Longitude: 120.0})
+
+	count, err := BroadcastNearby(EventUrgentHelpNearby, "p", nil, "publisher", 30.0, 120.0, 10)
+	if err != nil || count != 1 {
+		t.Fatalf("BroadcastNearby = %d, %v, want 1", count, err)
+	}
+	var items []NotifyOutbox
+	DB.Find(&items)
+	if len(items) != 1 || items[0].UserID != "near" {
+		t.Errorf("outbox = %+v, want only the nearby user", items)
+	}
+}
+
+func TestDistanceKm(t *testing.T) {
+	// 北京到上海约 1068 公里
+	if d := DistanceKm(39.9042, 116.4074, 31.2304, 121.4737); d < 1050 || d > 1090 {
+		t.Errorf("DistanceKm = %.1f, want about 1068", d)
+	}
+	if d := DistanceKm(30, 120, 30, 120); d != 0 {
+		t.Errorf("DistanceKm of the same point = %f", d)
+	}
+}
+
+func TestUpdateSubscribeLocationValidates(t *testing.T) {
+	setupTestDB(t)
+	var verr *ValidationError
+	if err := UpdateSubscribeLocation("wx1", SubscribeLocation{Latitude: 91, Longitude: 0}); !errors.As(err, &verr) {
+		t.Errorf("err = %v, want *ValidationError", err)
+	}
+}
+
+// 页码和每页条数无效时按默认值分页
+func TestGetOutboxClampsPaging(t *testing.T) {
+	setupTestDB(t)
+	for i := 0; i < 3; i++ {
+		DB.Create(&NotifyOutbox{Event: EventUrgentHelpNearby, UserID: "wx1", Status: OutboxPending})
+	}
+
+	for _, tc := range []struct{ page, pageSize int }{{0, 20}, {-5, 20}, {1, -1}, {1, 0}, {1, 1000}} {
+		items, total, err := GetOutbox("", tc.page, tc.pageSize)
+		if err != nil {
+			t.Fatalf("GetOutbox(%d, %d): %v", tc.page, tc.pageSize, err)
+		}
+		if total != 3 || len(items) != 3 {
+			t.Errorf("GetOutbox(%d, %d) = %d items, total %d; want 3, 3", tc.page, tc.pageSize, len(items), total)
+		}
+	}
+}
+
+func TestAnswerConsultation(t *testing.T) {
+	setupTestDB(t)
+	c := Consultation{Title: "问题", Content: "内容", AuthorID: "wx1", Status: "待回复"}
+	DB.Create(&c)
+
+	got, err := AnswerConsultation(c.ID, ConsultationAnswer{Answer: "回复"}, "admin_admin")
+	if err != nil {
+		t.Fatalf("AnswerConsultation: %v", err)
+	}
+	if got.Status != "已回复" || got.Answer != "回复" || got.ReplyCount != 1 || got.AnsweredAt == nil {
+		t.Errorf("answered consultation = %+v", got)
+	}
+	var verr *ValidationError
+	if _, err := AnswerConsultation(c.ID, ConsultationAnswer{}, "admin_admin"); !errors.As(err, &verr) {
+		t.Errorf("empty answer: err = %v, want *ValidationError", err)
+	}
+}
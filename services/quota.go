@@ -0,0 +1,328 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UploadQuota 按角色配置的上传限制，0 表示不限制
+type UploadQuota struct {
+	DailyBytes int64 `json:"daily_bytes"` // 每日上传字节数
+	DailyFiles int   `json:"daily_files"` // 每日上传文件数
+	PerMinute  int   `json:"per_minute"`  // 每分钟上传请求数
+}
+
+// UploadQuotas 各角色的上传限制，super_admin 按 admin 处理，未知角色按 user 处理
+var UploadQuotas = map[string]UploadQuota{
+	"user":  {DailyBytes: 50 << 20, DailyFiles: 50, PerMinute: 10},
+	"vip":   {DailyBytes: 500 << 20, DailyFiles: 300, PerMinute: 30},
+	"admin": {},
+}
+
+// UploadQuotaForRole 获取角色对应的上传限制
+func UploadQuotaForRole(role string) UploadQuota {
+	if role == "super_admin" {
+		role = "admin"
+	}
+	if q, ok := UploadQuotas[role]; ok {
+		return q
+	}
+	return UploadQuotas["user"]
+}
+
+// 超出的限制类型
+const (
+	QuotaDailyBytes = "daily_bytes"
+	QuotaDailyFiles = "daily_files"
+	QuotaPerMinute  = "per_minute"
+)
+
+// QuotaError 上传超出配额，作为错误响应的 data 返回给客户端
+type QuotaError struct {
+	Limit      string    `json:"limit"`       // 超出的限制类型
+	Max        int64     `json:"max"`         // 限额
+	Used       int64     `json:"used"`        // 当前周期已使用
+	Requested  int64     `json:"requested"`   // 本次请求的用量
+	RetryAfter int       `json:"retry_after"` // 多少秒后可重试
+	ResetsAt   time.Time `json:"resets_at"`
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("upload quota exceeded: %s (used %d + requested %d > max %d)", e.Limit, e.Used, e.Requested, e.Max)
+}
+
+// UploadUsage 用户每日上传用量（含重复上传）
+type UploadUsage struct {
+	UploaderID string    `gorm:"primaryKey;size:64" json:"uploader_id"`
+	Day        string    `gorm:"primaryKey;size:10" json:"day"` // 2006-01-02，按服务器本地时间
+	Bytes      int64     `json:"bytes"`
+	Files      int       `json:"files"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func usageDay(t time.Time) (day string, resetsAt time.Time) {
+	y, m, d := t.Date()
+	return t.Format("2006-01-02"), time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+}
+
+// GetUploadUsage 获取用户当天的上传用量
+func GetUploadUsage(uploaderID string) (*UploadUsage, error) {
+	day, _ := usageDay(time.Now())
+	usage := UploadUsage{UploaderID: uploaderID, Day: day}
+	err := DB.Where("uploader_id = ? AND day = ?", uploaderID, day).Limit(1).Find(&usage).Error
+	return &usage, err
+}
+
+// CheckUploadQuota 检查上传 size 字节后是否会超出当天配额（不占用配额），超出时返回 *QuotaError
+func CheckUploadQuota(uploaderID, role string, size int64) error {
+	usage, err := GetUploadUsage(uploaderID)
+	if err != nil {
+		return err
+	}
+	_, resetsAt := usageDay(time.Now())
+	return dailyQuotaError(UploadQuotaForRole(role), usage, size, resetsAt)
+}
+
+// RemainingUploadBytes 返回用户当天还可上传的字节数，不限制时返回 -1
+func RemainingUploadBytes(uploaderID, role string) (int64, error) {
+	q := UploadQuotaForRole(role)
+	if q.DailyBytes == 0 {
+		return -1, nil
+	}
+	usage, err := GetUploadUsage(uploaderID)
+	if err != nil {
+		return 0, err
+	}
+	return max(q.DailyBytes-usage.Bytes, 0), nil
+}
+
+func dailyQuotaError(q UploadQuota, usage *UploadUsage, size int64, resetsAt time.Time) error {
+	retryAfter := int(time.Until(resetsAt).Seconds()) + 1
+	if q.DailyFiles > 0 && usage.Files+1 > q.DailyFiles {
+		return &QuotaError{Limit: QuotaDailyFiles, Max: int64(q.DailyFiles), Used: int64(usage.Files), Requested: 1, RetryAfter: retryAfter, ResetsAt: resetsAt}
+	}
+	if q.DailyBytes > 0 && usage.Bytes+size > q.DailyBytes {
+		return &QuotaError{Limit: QuotaDailyBytes, Max: q.DailyBytes, Used: usage.Bytes, Requested: size, RetryAfter: retryAfter, ResetsAt: resetsAt}
+	}
+	return nil
+}
+
+// ReserveUploadQuota 占用当天配额（一个文件、size 字节），超出时返回 *QuotaError 且不占用
+// 使用条件更新保证并发上传不会超额；上传最终失败时调用 ReleaseUploadQuota 归还
+func ReserveUploadQuota(uploaderID, role string, size int64) error {
+	_, err := reserveUploadQuota(DB, uploaderID, role, size)
+	return err
+}
+
+// reserveUploadQuota 在 tx 中占用当天配额，返回占用所在的日期，归还时按该日期归还
+func reserveUploadQuota(tx *gorm.DB, uploaderID, role string, size int64) (string, error) {
+	q := UploadQuotaForRole(role)
+	day, resetsAt := usageDay(time.Now())
+
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&UploadUsage{UploaderID: uploaderID, Day: day, UpdatedAt: time.Now()}).Error
+	if err != nil {
+		return "", err
+	}
+
+	res := tx.Model(&UploadUsage{}).
+		Where("uploader_id = ? AND day = ?", uploaderID, day).
+		Where("? = 0 OR files + 1 <= ?", q.DailyFiles, q.DailyFiles).
+		Where("? = 0 OR bytes + ? <= ?", q.DailyBytes, size, q.DailyBytes).
+		Updates(map[string]interface{}{
+			"files":      gorm.Expr("files + 1"),
+			"bytes":      gorm.Expr("bytes + ?", size),
+			"updated_at": time.Now(),
+		})
+	if res.Error != nil {
+		return "", res.Error
+	}
+	if res.RowsAffected > 0 {
+		return day, nil
+	}
+
+	usage := UploadUsage{UploaderID: uploaderID, Day: day}
+	if err := tx.Where("uploader_id = ? AND day = ?", uploaderID, day).Limit(1).Find(&usage).Error; err != nil {
+		return "", err
+	}
+	if qerr := dailyQuotaError(q, &usage, size, resetsAt); qerr != nil {
+		return "", qerr
+	}
+	// 条件更新失败但重新读取时又未超额（并发归还），按超额处理，客户端稍后重试即可
+	return "", &QuotaError{Limit: QuotaDailyBytes, Max: q.DailyBytes, Used: usage.Bytes, Requested: size, RetryAfter: 1, ResetsAt: resetsAt}
+}
+
+// ReleaseUploadQuota 归还 ReserveUploadQuota 占用的当天配额
+func ReleaseUploadQuota(uploaderID string, size int64) error {
+	day, _ := usageDay(time.Now())
+	return releaseUploadQuota(uploaderID, day, 1, size)
+}
+
+// releaseUploadQuota 归还 day 当天占用的 files 个文件、size 字节配额，size 为负时追加占用（不检查限额）
+func releaseUploadQuota(uploaderID, day string, files int, size int64) error {
+	return DB.Model(&UploadUsage{}).
+		Where("uploader_id = ? AND day = ?", uploaderID, day).
+		Updates(map[string]interface{}{
+			"files":      gorm.Expr("MAX(files - ?, 0)", files),
+			"bytes":      gorm.Expr("MAX(bytes - ?, 0)", size),
+			"updated_at": time.Now(),
+		}).Error
+}
+
+// ---------------- 每分钟频率限制 ----------------
+
+var (
+	uploadRateMu sync.Mutex
+	uploadRate   = map[string][]time.Time{}
+)
+
+// AllowUploadRequest 按角色的每分钟上传次数限制计数，超出时返回 *QuotaError
+// 计数保存在内存中，多实例部署时每个实例分别限制
+func AllowUploadRequest(uploaderID, role string) error {
+	limit := UploadQuotaForRole(role).PerMinute
+	if limit <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	windowStart := now.Add(-time.Minute)
+
+	uploadRateMu.Lock()
+	defer uploadRateMu.Unlock()
+
+	// 用户数较多时顺带清理已过窗口的记录
+	if len(uploadRate) > 10000 {
+		for id, hits := range uploadRate {
+			if len(hits) == 0 || hits[len(hits)-1].Before(windowStart) {
+				delete(uploadRate, id)
+			}
+		}
+	}
+
+	hits := uploadRate[uploaderID]
+	i := 0
+	for i < len(hits) && !hits[i].After(windowStart) {
+		i++
+	}
+	hits = hits[i:]
+
+	if len(hits) >= limit {
+		resetsAt := hits[0].Add(time.Minute)
+		uploadRate[uploaderID] = hits
+		return &QuotaError{
+			Limit:      QuotaPerMinute,
+			Max:        int64(limit),
+			Used:       int64(len(hits)),
+			Requested:  1,
+			RetryAfter: int(time.Until(resetsAt).Seconds()) + 1,
+			ResetsAt:   resetsAt,
+		}
+	}
+	uploadRate[uploaderID] = append(hits, now)
+	return nil
+}
+
+// ---------------- 管理员用量统计 ----------------
+
+// UserUploadUsage 用户存储占用（文件按首次上传者计入）及当天上传用量
+type UserUploadUsage struct {
+	UploaderID string      `json:"uploader_id"`
+	Nickname   string      `json:"nickname"`
+	Role       string      `json:"role"`
+	Files      int64       `json:"files"`
+	Bytes      int64       `json:"bytes"`
+	TodayFiles int         `json:"today_files"`
+	TodayBytes int64       `json:"today_bytes"`
+	Quota      UploadQuota `json:"quota"`
+}
+
+// UploadUsageByUser 按占用空间从大到小列出各用户的存储用量
+func UploadUsageByUser(page, pageSize int) ([]UserUploadUsage, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var total int64
+	if err := DB.Model(&UploadFile{}).Where("uploader_id <> ''").Distinct("uploader_id").Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []struct {
+		UploaderID string
+		Files      int64
+		Bytes      int64
+	}
+	err := DB.Model(&UploadFile{}).
+		Select("uploader_id, COUNT(*) AS files, SUM(size) AS bytes").
+		Where("uploader_id <> ''").
+		Group("uploader_id").
+		Order("bytes DESC, uploader_id").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	list := make([]UserUploadUsage, len(rows))
+	for i, row := range rows {
+		list[i] = UserUploadUsage{UploaderID: row.UploaderID, Files: row.Files, Bytes: row.Bytes}
+	}
+	if len(list) == 0 {
+		return list, total, nil
+	}
+
+	ids := make([]string, len(list))
+	var adminNames []string
+	for i, u := range list {
+		ids[i] = u.UploaderID
+		if strings.HasPrefix(u.UploaderID, "admin_") {
+			adminNames = append(adminNames, strings.TrimPrefix(u.UploaderID, "admin_"))
+		}
+	}
+
+	// 上传者可能是微信用户（wechat_id）或管理员账号（admin_用户名）
+	var users []User
+	if err := DB.Select("wechat_id, nickname, role").Where("wechat_id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	userByID := make(map[string]User, len(users))
+	for _, u := range users {
+		userByID[u.WechatID] = u
+	}
+	if len(adminNames) > 0 {
+		var admins []Admin
+		if err := DB.Select("username, nickname, role").Where("username IN ?", adminNames).Find(&admins).Error; err != nil {
+			return nil, 0, err
+		}
+		for _, a := range admins {
+			userByID["admin_"+a.Username] = User{Nickname: a.Nickname, Role: a.Role}
+		}
+	}
+
+	day, _ := usageDay(time.Now())
+	var usages []UploadUsage
+	if err := DB.Where("day = ? AND uploader_id IN ?", day, ids).Find(&usages).Error; err != nil {
+		return nil, 0, err
+	}
+	usageByID := make(map[string]UploadUsage, len(usages))
+	for _, u := range usages {
+		usageByID[u.UploaderID] = u
+	}
+
+	for i := range list {
+		u := &list[i]
+		if user, ok := userByID[u.UploaderID]; ok {
+			u.Nickname, u.Role = user.Nickname, user.Role
+		}
+		usage := usageByID[u.UploaderID]
+		u.TodayFiles, u.TodayBytes = usage.Files, usage.Bytes
+		u.Quota = UploadQuotaForRole(u.Role)
+	}
+	return list, total, nil
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// contentModel 可软删除的内容模块
+type contentModel struct {
+	New         func() interface{} // 返回模型指针
+	Table       string
+	TitleColumn string
+	ImageColumn string // 封面图字段，多图字段取第一张
+}
+
+// 内容类型 -> 模型，类型名与“我的发布”模块保持一致
+var contentModels = map[string]contentModel{
+	"news":         {New: func() interface{} { return &News{} }, Table: "news", TitleColumn: "title", ImageColumn: "image"},
+	"farmhouse":    {New: func() interface{} { return &Farmhouse{} }, Table: "farmhouses", TitleColumn: "title", ImageColumn: "image"},
+	"policy":       {New: func() interface{} { return &Policy{} }, Table: "policies", TitleColumn: "title", ImageColumn: "image"},
+	"tourism":      {New: func() interface{} { return &Tourism{} }, Table: "tourisms", TitleColumn: "name", ImageColumn: "image"},
+	"jobs":         {New: func() interface{} { return &Job{} }, Table: "jobs", TitleColumn: "title", ImageColumn: "logo"},
+	"help":         {New: func() interface{} { return &Help{} }, Table: "helps", TitleColumn: "title", ImageColumn: "image"},
+	"consultation": {New: func() interface{} { return &Consultation{} }, Table: "consultations", TitleColumn: "title", ImageColumn: "images"},
+}
+
+// ContentSummary 获取内容的标题和封面图（未删除的记录）
+func ContentSummary(contentType string, id int) (title, image string, err error) {
+	m, ok := lookupContentModel(contentType)
+	if !ok {
+		return "", "", fmt.Errorf("unsupported content type: %s", contentType)
+	}
+	var row struct {
+		Title string
+		Image string
+	}
+	err = DB.Model(m.New()).
+		Select(fmt.Sprintf("%s AS title, %s AS image", m.TitleColumn, m.ImageColumn)).
+		Where("id = ?", id).Take(&row).Error
+	if err != nil {
+		return "", "", err
+	}
+	return row.Title, FirstImage(row.Image), nil
+}
+
+// FirstImage 从逗号分隔的图片列表中取第一张
+func FirstImage(images ...string) string {
+	for _, list := range images {
+		for _, img := range strings.Split(list, ",") {
+			if img = strings.TrimSpace(img); img != "" {
+				return img
+			}
+		}
+	}
+	return ""
+}
+
+// ContentTypes 返回所有支持软删除的内容类型
+func ContentTypes() []string {
+	return []string{"news", "farmhouse", "policy", "tourism", "jobs", "help", "consultation"}
+}
+
+// NormalizeContentType 返回规范的内容类型名，兼容单数 job 写法
+func NormalizeContentType(contentType string) (string, bool) {
+	if contentType == "job" {
+		contentType = "jobs"
+	}
+	_, ok := contentModels[contentType]
+	return contentType, ok
+}
+
+// lookupContentModel 查找内容类型
+func lookupContentModel(contentType string) (contentModel, bool) {
+	contentType, _ = NormalizeContentType(contentType)
+	m, ok := contentModels[contentType]
+	return m, ok
+}
+
+// ContentLastModified 内容模块最近一次变化的时间：新增、修改、恢复取 updated_at，删除取 deleted_at
+// 用作列表的 Last-Modified，与该类型的列表一起缓存，写入时失效；浏览量等计数的变化不计入
+func ContentLastModified(contentType string) (time.Time, error) {
+	m, ok := lookupContentModel(contentType)
+	if !ok {
+		return time.Time{}, fmt.Errorf("unsupported content type: %s", contentType)
+	}
+	contentType, _ = NormalizeContentType(contentType)
+	v, err := Cache.GetOrLoad("lastmod:"+contentType, 0, []string{contentType}, func() (interface{}, error) {
+		var updated, deleted []time.Time
+		if err := DB.Model(m.New()).Order("updated_at DESC").Limit(1).Pluck("updated_at", &updated).Error; err != nil {
+			return nil, err
+		}
+		err := DB.Unscoped().Model(m.New()).Where("deleted_at IS NOT NULL").
+			Order("deleted_at DESC").Limit(1).Pluck("deleted_at", &deleted).Error
+		if err != nil {
+			return nil, err
+		}
+		var last time.Time
+		for _, t := range append(updated, deleted...) {
+			if t.After(last) {
+				last = t
+			}
+		}
+		return last, nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return v.(time.Time), nil
+}
+
+// backfillContentUpdatedAt 新增的 updated_at 列以创建时间填充（含回收站中的内容）
+func backfillContentUpdatedAt(tx *gorm.DB) error {
+	for _, t := range ContentTypes() {
+		if err := tx.Exec(fmt.Sprintf("UPDATE %s SET updated_at = created_at WHERE updated_at IS NULL", contentModels[t].Table)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// softDelete 记录删除者并软删除
+func softDelete(contentType string, id int, deletedBy string) error {
+	model := contentModels[contentType].New()
+	return invalidateContent(contentType, DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(model).Where("id = ?", id).UpdateColumn("deleted_by", deletedBy)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("not found")
+		}
+		return tx.Delete(model, id).Error
+	}))
+}
+
+// RecycleItem 回收站条目
+type RecycleItem struct {
+	Type      string    `json:"type"`
+	ID        int       `json:"id"`
+	Title     string    `json:"title"`
+	DeletedBy string    `json:"deleted_by"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"` // 预计彻底删除时间
+}
+
+// RecycleRetention 回收站保留时长，超过后由清理任务彻底删除
+var RecycleRetention = 30 * 24 * time.Hour
+
+// RecycleBinList 分页查询回收站，contentType 为空时查询全部类型
+func RecycleBinList(contentType string, page, pageSize int) ([]RecycleItem, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	types := ContentTypes()
+	if contentType != "" {
+		if _, ok := lookupContentModel(contentType); !ok {
+			return nil, 0, fmt.Errorf("unsupported content type: %s", contentType)
+		}
+		contentType, _ = NormalizeContentType(contentType)
+		types = []string{contentType}
+	}
+
+	// 合并各模块的已删除记录，按删除时间倒序分页
+	var unions []string
+	var total int64
+	for _, t := range types {
+		m := contentModels[t]
+		var cnt int64
+		if err := DB.Unscoped().Model(m.New()).Where("deleted_at IS NOT NULL").Count(&cnt).Error; err != nil {
+			return nil, 0, err
+		}
+		total += cnt
+		unions = append(unions, fmt.Sprintf(
+			"SELECT '%s' AS type, id, %s AS title, deleted_by, deleted_at FROM %s WHERE deleted_at IS NOT NULL",
+			t, m.TitleColumn, m.Table))
+	}
+
+	query := ""
+	for i, u := range unions {
+		if i > 0 {
+			query += " UNION ALL "
+		}
+		query += u
+	}
+	query += " ORDER BY deleted_at DESC LIMIT ? OFFSET ?"
+
+	var items []RecycleItem
+	if err := DB.Raw(query, pageSize, (page-1)*pageSize).Scan(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	for i := range items {
+		items[i].PurgeAt = items[i].DeletedAt.Add(RecycleRetention)
+	}
+	if items == nil {
+		items = []RecycleItem{}
+	}
+	return items, total, nil
+}
+
+// RestoreContent 从回收站恢复内容
+func RestoreContent(contentType string, id int) error {
+	m, ok := lookupContentModel(contentType)
+	if !ok {
+		return fmt.Errorf("unsupported content type: %s", contentType)
+	}
+	res := DB.Unscoped().Model(m.New()).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		UpdateColumns(map[string]interface{}{"deleted_at": nil, "deleted_by": "", "updated_at": time.Now()}) // 恢复也算修改，列表的 Last-Modified 随之更新
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("not found")
+	}
+	contentType, _ = NormalizeContentType(contentType)
+	Cache.InvalidateTag(contentType)
+	return nil
+}
+
+// PurgeDeletedContent 彻底删除超过保留期的软删除内容，返回删除数量
+func PurgeDeletedContent(retention time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-retention)
+	var purged int64
+	for _, t := range ContentTypes() {
+		res := DB.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Delete(contentModels[t].New())
+		if res.Error != nil {
+			return purged, res.Error
+		}
+		purged += res.RowsAffected
+	}
+	return purged, nil
+}
+
+// StartPurgeWorker 定期清理回收站，关闭 stop 通道即可停止
+func StartPurgeWorker(interval time.Duration, stop <-chan struct{}) {
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if n, err := PurgeDeletedContent(RecycleRetention); err != nil {
+					slog.Error("recycle bin purge failed", "error", err)
+				} else if n > 0 {
+					slog.Info("recycle bin purged", "count", n)
+					RecordAudit(&AuditLog{
+						ActorID: "system",
+						Action:  AuditContentPurge,
+						After:   AuditSnapshot(map[string]interface{}{"purged": n, "retention": RecycleRetention.String()}),
+					})
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,23 @@
+package services
+
+import "testing"
+
+// 页码和每页条数无效时按默认值分页，不能生成负的 OFFSET 或 LIMIT -1
+func TestRecycleBinListClampsPaging(t *testing.T) {
+	setupTestDB(t)
+	for i := 0; i < 3; i++ {
+		n := News{Title: "n"}
+		DB.Create(&n)
+		DB.Delete(&n)
+	}
+
+	for _, tc := range []struct{ page, pageSize int }{{0, 20}, {-5, 20}, {1, -1}, {1, 0}, {1, 1000}} {
+		items, total, err := RecycleBinList("", tc.page, tc.pageSize)
+		if err != nil {
+			t.Fatalf("RecycleBinList(%d, %d): %v", tc.page, tc.pageSize, err)
+		}
+		if total != 3 || len(items) != 3 {
+			t.Errorf("RecycleBinList(%d, %d) = %d items, total %d; want 3, 3", tc.page, tc.pageSize, len(items), total)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrObjectNotFound 存储中不存在该文件
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo 存储中文件的基本信息
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage 上传文件存储后端，key 为相对路径（如 "abc.jpg"、".quarantine/abc.jpg"）
+type Storage interface {
+	// Put 写入文件，已存在时覆盖
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Get 打开文件，返回的 reader 支持 Seek 以便按 Range 读取
+	Get(key string) (io.ReadSeekCloser, *ObjectInfo, error)
+	// Stat 获取文件信息，不存在时返回 ErrObjectNotFound
+	Stat(key string) (*ObjectInfo, error)
+	// Delete 删除文件，不存在时不报错
+	Delete(key string) error
+	// List 列出 prefix 目录下（不含子目录）的文件，prefix 为 "" 或以 "/" 结尾
+	List(prefix string) ([]ObjectInfo, error)
+	// URL 返回保存到数据库的文件地址：由本服务提供的文件为相对路径，响应时再展开为绝对地址
+	URL(key string) string
+}
+
+// renamer 支持原子重命名的存储（本地磁盘），移动文件时优先使用
+type renamer interface {
+	Rename(src, dst string) error
+}
+
+// UploadStorage 当前使用的上传文件存储，默认本地磁盘
+var UploadStorage Storage = NewLocalStorage(UploadDir)
+
+// moveObject 在存储内移动文件
+func moveObject(s Storage, src, dst string) error {
+	if rn, ok := s.(renamer); ok {
+		return rn.Rename(src, dst)
+	}
+	r, info, err := s.Get(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := s.Put(dst, r, info.Size, ""); err != nil {
+		return err
+	}
+	return s.Delete(src)
+}
+
+// putFile 将本地文件写入存储
+func putFile(s Storage, key, localPath, contentType string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return s.Put(key, f, info.Size(), contentType)
+}
+
+// cleanKey 校验存储 key，拒绝绝对路径和 ".." 以防越出存储目录
+func cleanKey(key string) (string, error) {
+	k := path.Clean("/" + strings.ReplaceAll(key, "\\", "/"))[1:]
+	if k == "" || k != key {
+		return "", fmt.Errorf("invalid storage key: %q", key)
+	}
+	return k, nil
+}
+
+// ---------------- 本地磁盘 ----------------
+
+// LocalStorage 本地目录存储
+type LocalStorage struct {
+	Dir string
+}
+
+// NewLocalStorage 创建本地目录存储
+func NewLocalStorage(dir string) *LocalStorage {
+	return &LocalStorage{Dir: dir}
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	k, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(k)), nil
+}
+
+// Put 先写临时文件再重命名，避免并发读取到半个文件
+func (s *LocalStorage) Put(key string, r io.Reader, size int64, contentType string) error {
+	dst, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".put-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) Get(key string) (io.ReadSeekCloser, *ObjectInfo, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, ErrObjectNotFound
+		}
+		return nil, nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		f.Close()
+		return nil, nil, ErrObjectNotFound
+	}
+	return f, &ObjectInfo{Key: key, Size: fi.Size(), ModTime: fi.ModTime()}, nil
+}
+
+func (s *LocalStorage) Stat(key string) (*ObjectInfo, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, ErrObjectNotFound
+	}
+	return &ObjectInfo{Key: key, Size: fi.Size(), ModTime: fi.ModTime()}, nil
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// 子目录（如分片目录）删空后一并移除，非空时删除失败忽略即可
+	if dir := filepath.Dir(p); filepath.Clean(dir) != filepath.Clean(s.Dir) {
+		os.Remove(dir)
+	}
+	return nil
+}
+
+func (s *LocalStorage) List(prefix string) ([]ObjectInfo, error) {
+	dir := s.Dir
+	if prefix != "" {
+		p, err := s.path(strings.TrimSuffix(prefix, "/"))
+		if err != nil {
+			return nil, err
+		}
+		dir = p
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	objects := make([]ObjectInfo, 0, len(entries))
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, ObjectInfo{Key: prefix + e.Name(), Size: fi.Size(), ModTime: fi.ModTime()})
+	}
+	return objects, nil
+}
+
+func (s *LocalStorage) URL(key string) string {
+	return UploadPathPrefix + key
+}
+
+// Rename 同一目录树内直接重命名
+func (s *LocalStorage) Rename(src, dst string) error {
+	sp, err := s.path(src)
+	if err != nil {
+		return err
+	}
+	dp, err := s.path(dst)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dp), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(sp, dp); err != nil {
+		if os.IsNotExist(err) {
+			return ErrObjectNotFound
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,346 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Storage S3 兼容对象存储（AWS S3、MinIO、各云厂商的 S3 接口），使用路径风格地址和 SigV4 签名
+type S3Storage struct {
+	Endpoint  string // 如 http://127.0.0.1:9000
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	PublicURL string // 可选，桶的公开访问地址；为空时通过本服务 /uploads/ 代理访问
+	Client    *http.Client
+}
+
+// NewS3Storage 创建 S3 兼容存储
+func NewS3Storage(endpoint, bucket, region, accessKey, secretKey, publicURL string) *S3Storage {
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3Storage{
+		Endpoint:  strings.TrimRight(endpoint, "/"),
+		Bucket:    bucket,
+		Region:    region,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		PublicURL: strings.TrimRight(publicURL, "/"),
+		Client:    &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// S3Error 对象存储返回的错误
+type S3Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *S3Error) Error() string {
+	return fmt.Sprintf("s3 error %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func (s *S3Storage) Put(key string, r io.Reader, size int64, contentType string) error {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	resp, err := s.do("PUT", key, nil, r, size, map[string]string{"Content-Type": contentType})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Storage) Get(key string) (io.ReadSeekCloser, *ObjectInfo, error) {
+	info, err := s.Stat(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &s3Object{s: s, key: key, size: info.Size}, info, nil
+}
+
+func (s *S3Storage) Stat(key string) (*ObjectInfo, error) {
+	resp, err := s.do("HEAD", key, nil, nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &ObjectInfo{Key: key, Size: resp.ContentLength, ModTime: modTime}, nil
+}
+
+func (s *S3Storage) Delete(key string) error {
+	resp, err := s.do("DELETE", key, nil, nil, 0, nil)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// List 使用 ListObjectsV2，以 "/" 为分隔符只列出当前层级
+func (s *S3Storage) List(prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}, "delimiter": {"/"}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do("GET", "", query, nil, 0, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				LastModified time.Time `xml:"LastModified"`
+				Size         int64     `xml:"Size"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range result.Contents {
+			objects = append(objects, ObjectInfo{Key: c.Key, Size: c.Size, ModTime: c.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *S3Storage) URL(key string) string {
+	if s.PublicURL != "" {
+		return s.PublicURL + "/" + awsURIEscape(key, false)
+	}
+	return UploadPathPrefix + key
+}
+
+// do 发送签名请求，key 为空时请求桶本身；404 返回 ErrObjectNotFound
+func (s *S3Storage) do(method, key string, query url.Values, body io.Reader, size int64, headers map[string]string) (*http.Response, error) {
+	if key != "" {
+		k, err := cleanKey(key)
+		if err != nil {
+			return nil, err
+		}
+		key = k
+	}
+
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	objectPath := "/" + s.Bucket
+	if key != "" {
+		objectPath += "/" + key
+	}
+	u.Path = objectPath
+	u.RawPath = awsURIEscape(objectPath, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	s.sign(req, time.Now().UTC(), s3UnsignedPayload)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrObjectNotFound
+		}
+		s3err := &S3Error{StatusCode: resp.StatusCode}
+		if method != "HEAD" {
+			data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+			xml.Unmarshal(data, s3err)
+		}
+		return nil, s3err
+	}
+	return resp, nil
+}
+
+// ---------------- SigV4 签名 ----------------
+
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// sign 按 AWS Signature Version 4 为请求签名，请求体以流式上传时 payloadHash 使用 UNSIGNED-PAYLOAD
+func (s *S3Storage) sign(req *http.Request, now time.Time, payloadHash string) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if req.Header.Get("Range") != "" {
+		signedHeaders = append(signedHeaders, "range")
+	}
+	sort.Strings(signedHeaders)
+
+	var canonicalHeaders strings.Builder
+	for _, h := range signedHeaders {
+		v := req.Header.Get(h)
+		if h == "host" {
+			v = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalQuery 按参数名排序并编码查询字符串（签名和实际请求使用同一结果）
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, awsURIEscape(k, true)+"="+awsURIEscape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsURIEscape 按 SigV4 规则编码：只保留 A-Z a-z 0-9 - _ . ~，encodeSlash 为 false 时保留 "/"
+func awsURIEscape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !encodeSlash {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// ---------------- 按 Range 读取 ----------------
+
+// s3Object 支持 Seek 的对象读取器：Seek 只记录位置，下次 Read 时从该位置发起 Range 请求
+type s3Object struct {
+	s      *S3Storage
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+	if o.body == nil {
+		headers := map[string]string{"Range": "bytes=" + strconv.FormatInt(o.offset, 10) + "-"}
+		resp, err := o.s.do("GET", o.key, nil, nil, 0, headers)
+		if err != nil {
+			return 0, err
+		}
+		o.body = resp.Body
+	}
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = o.offset + offset
+	case io.SeekEnd:
+		abs = o.size + offset
+	default:
+		return 0, errors.New("s3Object.Seek: invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("s3Object.Seek: negative position")
+	}
+	if abs != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = abs
+	return abs, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body != nil {
+		return o.body.Close()
+	}
+	return nil
+}
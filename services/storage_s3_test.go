@@ -0,0 +1,252 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 内存中的 S3 兼容服务（MinIO 替身），支持测试用到的对象读写、Range 读取和 ListObjectsV2
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, *S3Storage) {
+	t.Helper()
+	f := &fakeS3{bucket: bucket, objects: make(map[string]fakeObject)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, NewS3Storage(srv.URL, bucket, "", "minioadmin", "minioadmin", "")
+}
+
+// setModTime 修改对象的最后修改时间，用于模拟过了宽限期的文件
+func (f *fakeS3) setModTime(key string, t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj := f.objects[key]
+	obj.modTime = t
+	f.objects[key] = obj
+}
+
+func (f *fakeS3) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.objects))
+	for k := range f.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minioadmin/") ||
+		r.Header.Get("X-Amz-Date") == "" || r.Header.Get("X-Amz-Content-Sha256") == "" {
+		http.Error(w, "<Error><Code>AccessDenied</Code><Message>missing signature</Message></Error>", http.StatusForbidden)
+		return
+	}
+	bucketPath := "/" + f.bucket
+	if r.URL.Path == bucketPath || r.URL.Path == bucketPath+"/" {
+		f.list(w, r)
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, bucketPath+"/")
+	if !ok {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+
+	// 先读完请求体再加锁：复制对象时请求体本身来自对同一服务的 GET
+	var data []byte
+	if r.Method == http.MethodPut {
+		data, _ = io.ReadAll(r.Body)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		if r.ContentLength >= 0 && int64(len(data)) != r.ContentLength {
+			http.Error(w, "<Error><Code>IncompleteBody</Code></Error>", http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = fakeObject{data: data, contentType: r.Header.Get("Content-Type"), modTime: time.Now().UTC()}
+	case http.MethodHead, http.MethodGet:
+		obj, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Last-Modified", obj.modTime.Format(http.TimeFormat))
+		w.Header().Set("Content-Type", obj.contentType)
+		data := obj.data
+		status := http.StatusOK
+		if rng := r.Header.Get("Range"); rng != "" {
+			start, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			data = data[start:]
+			status = http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("list-type") != "2" {
+		http.Error(w, "<Error><Code>NotImplemented</Code></Error>", http.StatusNotImplemented)
+		return
+	}
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+
+	type content struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		Size         int64     `xml:"Size"`
+	}
+	var result struct {
+		XMLName  xml.Name  `xml:"ListBucketResult"`
+		Contents []content `xml:"Contents"`
+	}
+	f.mu.Lock()
+	for key, obj := range f.objects {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok || delimiter != "" && strings.Contains(rest, delimiter) {
+			continue
+		}
+		result.Contents = append(result.Contents, content{Key: key, LastModified: obj.modTime, Size: int64(len(obj.data))})
+	}
+	f.mu.Unlock()
+	sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+func TestS3StoragePutGetStatDelete(t *testing.T) {
+	_, s := newFakeS3(t, "uploads")
+
+	body := []byte("hello, object storage")
+	if err := s.Put("a.txt", bytes.NewReader(body), int64(len(body)), "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	info, err := s.Stat("a.txt")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Size != int64(len(body)) || info.ModTime.IsZero() {
+		t.Errorf("Stat = %+v, want size %d and a modification time", info, len(body))
+	}
+
+	r, _, err := s.Get("a.txt")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer r.Close()
+	if _, err := r.Seek(7, io.SeekStart); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll after Seek: %v", err)
+	}
+	if string(got) != "object storage" {
+		t.Errorf("ranged read = %q, want %q", got, "object storage")
+	}
+
+	if err := s.Delete("a.txt"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Stat("a.txt"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Stat after Delete: err = %v, want ErrObjectNotFound", err)
+	}
+	if err := s.Delete("a.txt"); err != nil {
+		t.Errorf("Delete of missing object: %v, want nil", err)
+	}
+}
+
+func TestS3StorageListOnlyCurrentLevel(t *testing.T) {
+	_, s := newFakeS3(t, "uploads")
+	for _, key := range []string{"a.jpg", "b.png", ".quarantine/c.jpg"} {
+		if err := s.Put(key, strings.NewReader("x"), 1, ""); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+
+	objects, err := s.List("")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var keys []string
+	for _, o := range objects {
+		keys = append(keys, o.Key)
+	}
+	if strings.Join(keys, ",") != "a.jpg,b.png" {
+		t.Errorf("List(\"\") = %v, want [a.jpg b.png]", keys)
+	}
+
+	objects, err = s.List(UploadQuarantineDir + "/")
+	if err != nil {
+		t.Fatalf("List quarantine: %v", err)
+	}
+	if len(objects) != 1 || objects[0].Key != ".quarantine/c.jpg" {
+		t.Errorf("List quarantine = %+v, want .quarantine/c.jpg", objects)
+	}
+}
+
+func TestS3StorageMoveObject(t *testing.T) {
+	fake, s := newFakeS3(t, "uploads")
+	if err := s.Put("a.jpg", strings.NewReader("image"), 5, "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := moveObject(s, "a.jpg", quarantineKey("a.jpg")); err != nil {
+		t.Fatalf("moveObject: %v", err)
+	}
+	if got := strings.Join(fake.keys(), ","); got != ".quarantine/a.jpg" {
+		t.Errorf("objects after move = %s, want .quarantine/a.jpg", got)
+	}
+}
+
+func TestS3StorageRejectsUnsafeKeys(t *testing.T) {
+	_, s := newFakeS3(t, "uploads")
+	for _, key := range []string{"../a.jpg", "/a.jpg", "a/../../b"} {
+		if err := s.Put(key, strings.NewReader("x"), 1, ""); err == nil {
+			t.Errorf("Put(%q) succeeded, want invalid key error", key)
+		}
+	}
+}
+
+func TestS3StorageURL(t *testing.T) {
+	_, s := newFakeS3(t, "uploads")
+	if got := s.URL("a.jpg"); got != "/uploads/a.jpg" {
+		t.Errorf("URL without public URL = %q, want /uploads/a.jpg", got)
+	}
+	s.PublicURL = "https://cdn.example.com/bucket"
+	if got := s.URL("a b.jpg"); got != "https://cdn.example.com/bucket/a%20b.jpg" {
+		t.Errorf("URL with public URL = %q", got)
+	}
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UploadDir 上传文件存储目录
+var UploadDir = "./uploads"
+
+// UploadFile 上传文件元数据，文件按内容哈希命名，相同内容只存一份
+type UploadFile struct {
+	ID           int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Hash         string    `gorm:"uniqueIndex;not null" json:"hash"` // 内容 SHA-256
+	Path         string    `gorm:"uniqueIndex;not null" json:"path"` // 相对 UploadDir 的文件名
+	OriginalName string    `json:"original_name"`
+	Size         int64     `json:"size"`
+	MimeType     string    `json:"mime_type"`
+	UploaderID   string    `gorm:"index" json:"uploader_id"`
+	UploadCount  int       `gorm:"default:1" json:"upload_count"` // 上传次数（含重复上传），不是引用计数
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ContentAddressedName 根据内容哈希和扩展名生成存储文件名
+func ContentAddressedName(hash, ext string) string {
+	return hash + strings.ToLower(ext)
+}
+
+// ContentHashFromName 从按内容哈希命名的文件名（含图片变体）中取出哈希，非此类文件名返回 false
+// 这类文件内容与文件名一一对应，可以长期缓存
+func ContentHashFromName(name string) (string, bool) {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	if IsVariantFile(name) {
+		base = base[:strings.LastIndex(base, "_")]
+	}
+	if len(base) != sha256.Size*2 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(base); err != nil || strings.ToLower(base) != base {
+		return "", false
+	}
+	return base, true
+}
+
+// GetUploadByHash 根据内容哈希查找已上传文件
+func GetUploadByHash(hash string) (*UploadFile, error) {
+	var f UploadFile
+	if err := DB.Where("hash = ?", hash).First(&f).Error; err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// GetUploadByPath 根据存储文件名查找已上传文件
+func GetUploadByPath(path string) (*UploadFile, error) {
+	var f UploadFile
+	if err := DB.Where("path = ?", path).First(&f).Error; err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// SaveUpload 将本地临时文件按内容哈希写入存储并登记，完成后删除临时文件
+// 相同内容已存在时上传次数加一并返回已有记录（deduplicated 为 true）
+// 存储读写（如 S3）可能较慢，不放在数据库事务中，避免长时间占用数据库连接
+func SaveUpload(tmpPath, hash, ext string, meta UploadFile) (file *UploadFile, deduplicated bool, err error) {
+	defer os.Remove(tmpPath)
+
+	existing, err := GetUploadByHash(hash)
+	if err == nil {
+		// 文件可能被清理（如已移入隔离区），缺失时用本次上传补回
+		if _, statErr := UploadStorage.Stat(existing.Path); errors.Is(statErr, ErrObjectNotFound) {
+			if err := putFile(UploadStorage, existing.Path, tmpPath, existing.MimeType); err != nil {
+				return nil, false, err
+			}
+		} else if statErr != nil {
+			return nil, false, statErr
+		}
+		if err := countRepeatUpload(existing); err != nil {
+			return nil, false, err
+		}
+		return existing, true, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	name := ContentAddressedName(hash, ext)
+	if err := putFile(UploadStorage, name, tmpPath, meta.MimeType); err != nil {
+		return nil, false, err
+	}
+
+	// 登记失败时不删除已写入的文件：同名文件可能属于并发上传的相同内容，未登记的文件由清理任务回收
+	meta.Hash = hash
+	meta.Path = name
+	meta.UploadCount = 1
+	meta.CreatedAt = time.Now()
+	meta.UpdatedAt = time.Now()
+	result := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&meta)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		// 并发上传的相同内容已先登记
+		if existing, err = GetUploadByHash(hash); err != nil {
+			return nil, false, err
+		}
+		if err := countRepeatUpload(existing); err != nil {
+			return nil, false, err
+		}
+		return existing, true, nil
+	}
+	return &meta, false, nil
+}
+
+// countRepeatUpload 相同内容再次上传时累加上传次数，并刷新 updated_at（清理任务据此计算宽限期）
+func countRepeatUpload(f *UploadFile) error {
+	now := time.Now()
+	err := DB.Model(f).UpdateColumns(map[string]interface{}{
+		"upload_count": gorm.Expr("upload_count + ?", 1),
+		"updated_at":   now,
+	}).Error
+	if err != nil {
+		return err
+	}
+	f.UploadCount++
+	f.UpdatedAt = now
+	return nil
+}
+
+// HashFile 计算文件内容的 SHA-256
+func HashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// indexExistingUploads 为上传目录中尚未登记的旧文件建立哈希索引
+// 旧文件保持原文件名（数据库中已有引用），相同内容以最早的文件为准，
+// 之后重复上传相同内容时直接返回该文件
+func indexExistingUploads(tx *gorm.DB) error {
+	entries, err := os.ReadDir(UploadDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.Type().IsRegular() && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fullPath := filepath.Join(UploadDir, name)
+		hash, size, err := HashFile(fullPath)
+		if err != nil {
+			return fmt.Errorf("hash %s: %w", name, err)
+		}
+
+		mimeType := ""
+		if f, err := os.Open(fullPath); err == nil {
+			buf := make([]byte, 512)
+			n, _ := io.ReadFull(f, buf)
+			f.Close()
+			mimeType = http.DetectContentType(buf[:n])
+		}
+
+		info, _ := os.Stat(fullPath)
+		createdAt := time.Now()
+		if info != nil {
+			createdAt = info.ModTime()
+		}
+
+		err = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&UploadFile{
+			Hash:         hash,
+			Path:         name,
+			OriginalName: name,
+			Size:         size,
+			MimeType:     mimeType,
+			UploadCount:  1,
+			CreatedAt:    createdAt,
+			UpdatedAt:    createdAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
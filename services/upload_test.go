@@ -0,0 +1,101 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTempUpload(t *testing.T, content string) (path, hash string) {
+	t.Helper()
+	path = filepath.Join(t.TempDir(), "upload.tmp")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, _, err := HashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, hash
+}
+
+func TestSaveUploadDeduplicates(t *testing.T) {
+	setupTestDB(t)
+
+	tmp, hash := writeTempUpload(t, "same content")
+	first, dedup, err := SaveUpload(tmp, hash, ".PDF", UploadFile{OriginalName: "a.pdf", MimeType: "application/pdf"})
+	if err != nil || dedup {
+		t.Fatalf("first SaveUpload = %v, deduplicated %v", err, dedup)
+	}
+	if first.Path != hash+".pdf" || first.UploadCount != 1 {
+		t.Errorf("first upload = %+v", first)
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("temporary file not removed")
+	}
+
+	tmp, _ = writeTempUpload(t, "same content")
+	second, dedup, err := SaveUpload(tmp, hash, ".pdf", UploadFile{OriginalName: "b.pdf", MimeType: "application/pdf"})
+	if err != nil || !dedup {
+		t.Fatalf("second SaveUpload = %v, deduplicated %v", err, dedup)
+	}
+	if second.ID != first.ID || second.UploadCount != 2 {
+		t.Errorf("second upload = %+v, want record %d with upload_count 2", second, first.ID)
+	}
+	stored, err := GetUploadByHash(hash)
+	if err != nil || stored.UploadCount != 2 {
+		t.Errorf("stored record = %+v (%v), want upload_count 2", stored, err)
+	}
+	if !stored.UpdatedAt.After(first.UpdatedAt) {
+		t.Errorf("updated_at = %v, want later than first upload %v", stored.UpdatedAt, first.UpdatedAt)
+	}
+}
+
+// 重复上传刷新 updated_at，很久以前上传过的文件重新上传后在宽限期内不被清理
+func TestRunUploadGCGracePeriodAfterRepeatUpload(t *testing.T) {
+	setupTestDB(t)
+
+	tmp, hash := writeTempUpload(t, "content")
+	saved, _, err := SaveUpload(tmp, hash, ".pdf", UploadFile{MimeType: "application/pdf"})
+	if err != nil {
+		t.Fatalf("SaveUpload: %v", err)
+	}
+	old := time.Now().Add(-2 * UploadGCGracePeriod)
+	DB.Model(saved).UpdateColumn("updated_at", old)
+	os.Chtimes(filepath.Join(UploadDir, saved.Path), old, old)
+
+	tmp, _ = writeTempUpload(t, "content")
+	if _, _, err := SaveUpload(tmp, hash, ".pdf", UploadFile{MimeType: "application/pdf"}); err != nil {
+		t.Fatalf("SaveUpload again: %v", err)
+	}
+	report, err := RunUploadGC(true)
+	if err != nil {
+		t.Fatalf("RunUploadGC: %v", err)
+	}
+	if len(report.Orphans) != 0 || report.InGracePeriod != 1 {
+		t.Errorf("report = %+v, want the re-uploaded file in its grace period", report)
+	}
+}
+
+// 缺失的文件（如已被清理）在重复上传时补回
+func TestSaveUploadRestoresMissingObject(t *testing.T) {
+	setupTestDB(t)
+
+	tmp, hash := writeTempUpload(t, "content")
+	saved, _, err := SaveUpload(tmp, hash, ".pdf", UploadFile{MimeType: "application/pdf"})
+	if err != nil {
+		t.Fatalf("SaveUpload: %v", err)
+	}
+	if err := UploadStorage.Delete(saved.Path); err != nil {
+		t.Fatal(err)
+	}
+
+	tmp, _ = writeTempUpload(t, "content")
+	if _, _, err := SaveUpload(tmp, hash, ".pdf", UploadFile{MimeType: "application/pdf"}); err != nil {
+		t.Fatalf("SaveUpload again: %v", err)
+	}
+	if _, err := UploadStorage.Stat(saved.Path); err != nil {
+		t.Errorf("object not restored: %v", err)
+	}
+}
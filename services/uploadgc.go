@@ -0,0 +1,331 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 孤立上传文件清理：存储中没有任何数据引用的文件超过宽限期后先移入隔离区，隔离期满再彻底删除
+var (
+	UploadGCGracePeriod    = 24 * time.Hour     // 上传后多久仍未被引用视为孤立（用户可能还在填写表单）
+	UploadQuarantinePeriod = 7 * 24 * time.Hour // 隔离区保留时间，期间重新被引用会自动恢复
+)
+
+// UploadQuarantineDir 隔离区目录名（存储中的 key 前缀，不对外提供访问）
+const UploadQuarantineDir = ".quarantine"
+
+// QuarantinedUpload 已移入隔离区的上传文件
+type QuarantinedUpload struct {
+	ID            int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Path          string    `gorm:"uniqueIndex;not null" json:"path"`
+	Size          int64     `json:"size"`
+	QuarantinedAt time.Time `gorm:"index" json:"quarantined_at"`
+}
+
+// uploadReference 可能引用上传文件的表和字段（逗号分隔的图片、JSON 附件等统一按文本匹配）
+type uploadReference struct {
+	Table   string
+	Columns []string
+}
+
+// 已软删除的内容仍可从回收站恢复，因此按表直接查询，不过滤 deleted_at
+var uploadReferences = []uploadReference{
+	{Table: "news", Columns: []string{"image"}},
+	{Table: "farmhouses", Columns: []string{"image", "images", "author_avatar"}},
+	{Table: "policies", Columns: []string{"image", "images", "attachments"}},
+	{Table: "tourisms", Columns: []string{"image", "images", "publisher_avatar"}},
+	{Table: "jobs", Columns: []string{"logo", "publisher_avatar"}},
+	{Table: "helps", Columns: []string{"image", "images"}},
+	{Table: "consultations", Columns: []string{"avatar", "images"}},
+	{Table: "users", Columns: []string{"avatar"}},
+	{Table: "histories", Columns: []string{"image"}},
+	{Table: "favorites", Columns: []string{"image"}},
+	{Table: "settings", Columns: []string{"value"}}, // 轮播图
+}
+
+// uploadRefTail 地址前缀之后的文件名部分
+const uploadRefTail = `([^"',\s?#)\]]+)`
+
+// uploadURLPrefixes 数据中引用上传文件时可能使用的地址前缀：本服务代理的 /uploads/，
+// 以及当前存储生成的地址前缀（如配置了 s3_public_url 时为桶的公开地址）
+func uploadURLPrefixes(s Storage) []string {
+	prefixes := []string{UploadPathPrefix}
+	const probe = "probe"
+	if u := s.URL(probe); strings.HasSuffix(u, probe) {
+		if p := strings.TrimSuffix(u, probe); p != UploadPathPrefix {
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes
+}
+
+// ReferencedUploads 返回所有被数据引用的上传文件名
+func ReferencedUploads() (map[string]bool, error) {
+	prefixes := uploadURLPrefixes(UploadStorage)
+	quoted := make([]string, len(prefixes))
+	var args []interface{}
+	for i, p := range prefixes {
+		quoted[i] = regexp.QuoteMeta(p)
+		args = append(args, "%"+strings.NewReplacer("%", `\%`, "_", `\_`).Replace(p)+"%")
+	}
+	pattern := regexp.MustCompile(`(?:` + strings.Join(quoted, "|") + `)` + uploadRefTail)
+
+	refs := make(map[string]bool)
+	for _, ref := range uploadReferences {
+		for _, col := range ref.Columns {
+			conds := make([]string, len(prefixes))
+			for i := range conds {
+				conds[i] = col + ` LIKE ? ESCAPE '\'`
+			}
+			var values []sql.NullString
+			err := DB.Table(ref.Table).Where(strings.Join(conds, " OR "), args...).Pluck(col, &values).Error
+			if err != nil {
+				return nil, fmt.Errorf("scan %s.%s: %w", ref.Table, col, err)
+			}
+			for _, v := range values {
+				for _, m := range pattern.FindAllStringSubmatch(v.String, -1) {
+					refs[path.Base(m[1])] = true
+				}
+			}
+		}
+	}
+	return refs, nil
+}
+
+// OrphanUpload 孤立的上传文件
+type OrphanUpload struct {
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// UploadGCReport 一次清理的结果；DryRun 时只报告将执行的操作
+type UploadGCReport struct {
+	DryRun           bool           `json:"dry_run"`
+	Scanned          int            `json:"scanned"`
+	Referenced       int            `json:"referenced"`
+	InGracePeriod    int            `json:"in_grace_period"`
+	Orphans          []OrphanUpload `json:"orphans"`            // 本次移入隔离区
+	Restored         []string       `json:"restored"`           // 重新被引用，移回上传目录
+	Purged           []string       `json:"purged"`             // 隔离期满，彻底删除
+	PendingPurge     int            `json:"pending_purge"`      // 仍在隔离期内
+	VariantsRemoved  int            `json:"variants_removed"`   // 原图已不存在的缩略图
+	TempFilesRemoved int            `json:"temp_files_removed"` // 中断上传遗留的临时文件
+	FreedBytes       int64          `json:"freed_bytes"`
+	GracePeriod      string         `json:"grace_period"`
+	QuarantinePeriod string         `json:"quarantine_period"`
+}
+
+// RunUploadGC 扫描上传文件存储，隔离孤立文件、恢复重新被引用的文件、删除隔离期满的文件
+func RunUploadGC(dryRun bool) (*UploadGCReport, error) {
+	report := &UploadGCReport{
+		DryRun:           dryRun,
+		Orphans:          []OrphanUpload{},
+		Restored:         []string{},
+		Purged:           []string{},
+		GracePeriod:      UploadGCGracePeriod.String(),
+		QuarantinePeriod: UploadQuarantinePeriod.String(),
+	}
+
+	refs, err := ReferencedUploads()
+	if err != nil {
+		return nil, err
+	}
+
+	// 重复上传会刷新元数据的更新时间，宽限期以文件修改时间和最近一次上传中较晚者为准
+	var metas []UploadFile
+	if err := DB.Select("path", "updated_at").Find(&metas).Error; err != nil {
+		return nil, err
+	}
+	lastUpload := make(map[string]time.Time, len(metas))
+	for _, m := range metas {
+		lastUpload[m.Path] = m.UpdatedAt
+	}
+
+	objects, err := UploadStorage.List("")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	originals := make(map[string]bool)
+	var variants []ObjectInfo
+
+	for _, obj := range objects {
+		name := obj.Key
+
+		if strings.HasPrefix(name, ".") {
+			if now.Sub(obj.ModTime) > UploadGCGracePeriod {
+				report.TempFilesRemoved++
+				report.FreedBytes += obj.Size
+				if !dryRun {
+					UploadStorage.Delete(name)
+				}
+			}
+			continue
+		}
+		if IsVariantFile(name) {
+			variants = append(variants, obj)
+			continue
+		}
+
+		report.Scanned++
+		if refs[name] {
+			report.Referenced++
+			originals[variantBase(name)] = true
+			continue
+		}
+
+		lastUsed := obj.ModTime
+		if t, ok := lastUpload[name]; ok && t.After(lastUsed) {
+			lastUsed = t
+		}
+		if now.Sub(lastUsed) <= UploadGCGracePeriod {
+			report.InGracePeriod++
+			originals[variantBase(name)] = true
+			continue
+		}
+
+		report.Orphans = append(report.Orphans, OrphanUpload{Path: name, Size: obj.Size, LastUsedAt: lastUsed})
+		if !dryRun {
+			if err := quarantineUpload(name, obj.Size); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	// 原图已隔离或不存在的变体直接删除，需要时可重新生成
+	for _, obj := range variants {
+		if refs[obj.Key] || originals[variantBase(obj.Key)] {
+			continue
+		}
+		report.VariantsRemoved++
+		report.FreedBytes += obj.Size
+		if !dryRun {
+			if err := UploadStorage.Delete(obj.Key); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	var quarantined []QuarantinedUpload
+	if err := DB.Order("quarantined_at").Find(&quarantined).Error; err != nil {
+		return report, err
+	}
+	for _, q := range quarantined {
+		switch {
+		case refs[q.Path]:
+			report.Restored = append(report.Restored, q.Path)
+			if !dryRun {
+				if err := restoreQuarantinedUpload(q); err != nil {
+					return report, err
+				}
+			}
+		case now.Sub(q.QuarantinedAt) > UploadQuarantinePeriod:
+			report.Purged = append(report.Purged, q.Path)
+			report.FreedBytes += q.Size
+			if !dryRun {
+				if err := purgeQuarantinedUpload(q); err != nil {
+					return report, err
+				}
+			}
+		default:
+			report.PendingPurge++
+		}
+	}
+	return report, nil
+}
+
+// variantBase 去掉变体后缀和扩展名，用于把变体与原图对应起来
+func variantBase(name string) string {
+	for _, v := range ImageVariants {
+		if suffix := "_" + v.Name + ".jpg"; strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// quarantineKey 隔离区中的存储 key
+func quarantineKey(name string) string {
+	return UploadQuarantineDir + "/" + name
+}
+
+// quarantineUpload 将文件移入隔离区并登记
+func quarantineUpload(name string, size int64) error {
+	if err := moveObject(UploadStorage, name, quarantineKey(name)); err != nil {
+		return err
+	}
+	q := QuarantinedUpload{Path: name, Size: size, QuarantinedAt: time.Now()}
+	return DB.Where("path = ?", name).Assign(q).FirstOrCreate(&QuarantinedUpload{}).Error
+}
+
+// restoreQuarantinedUpload 将隔离区中的文件移回上传目录
+func restoreQuarantinedUpload(q QuarantinedUpload) error {
+	// 隔离期间相同内容被重新上传时，存储中已有文件
+	if _, err := UploadStorage.Stat(q.Path); err == nil {
+		if err := UploadStorage.Delete(quarantineKey(q.Path)); err != nil {
+			return err
+		}
+	} else if err := moveObject(UploadStorage, quarantineKey(q.Path), q.Path); err != nil && !errors.Is(err, ErrObjectNotFound) {
+		return err
+	}
+	return DB.Delete(&q).Error
+}
+
+// purgeQuarantinedUpload 彻底删除隔离期满的文件及其元数据
+func purgeQuarantinedUpload(q QuarantinedUpload) error {
+	if err := UploadStorage.Delete(quarantineKey(q.Path)); err != nil {
+		return err
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		// 隔离期间相同内容被重新上传时保留元数据
+		if _, err := UploadStorage.Stat(q.Path); errors.Is(err, ErrObjectNotFound) {
+			if err := tx.Where("path = ?", q.Path).Delete(&UploadFile{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&q).Error
+	})
+}
+
+// StartUploadGCWorker 定期清理孤立上传文件，关闭 stop 通道即可停止
+func StartUploadGCWorker(interval time.Duration, stop <-chan struct{}) {
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				report, err := RunUploadGC(false)
+				if err != nil {
+					slog.Error("upload GC failed", "error", err)
+					continue
+				}
+				if len(report.Orphans) == 0 && len(report.Restored) == 0 && len(report.Purged) == 0 {
+					continue
+				}
+				slog.Info("upload GC finished",
+					"quarantined", len(report.Orphans), "restored", len(report.Restored), "purged", len(report.Purged))
+				RecordAudit(&AuditLog{
+					ActorID: "system",
+					Action:  AuditUploadsGC,
+					After:   AuditSnapshot(report),
+				})
+			}
+		}
+	}()
+}
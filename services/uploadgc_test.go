@@ -0,0 +1,110 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupTestDB 在临时目录中初始化数据库和本地上传目录，测试结束后恢复全局状态
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	oldDB, oldStorage, oldDir, oldCache := DB, UploadStorage, UploadDir, Cache
+	t.Cleanup(func() {
+		if sqlDB, err := DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+		DB, UploadStorage, UploadDir, Cache = oldDB, oldStorage, oldDir, oldCache
+	})
+
+	dir := t.TempDir()
+	UploadDir = filepath.Join(dir, "uploads")
+	UploadStorage = NewLocalStorage(UploadDir)
+	Cache = NewCache(100, time.Minute)
+	if err := InitDB(filepath.Join(dir, "test.db")+"?_pragma=busy_timeout(5000)", "error"); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+}
+
+func TestReferencedUploadsLocal(t *testing.T) {
+	setupTestDB(t)
+	DB.Create(&News{Title: "n", Image: "/uploads/a.jpg"})
+	DB.Create(&Policy{Title: "p", Images: "/uploads/b.png,https://example.com/uploads/c.png",
+		Attachments: `[{"name":"d.pdf","url":"/uploads/d.pdf"}]`})
+	DB.Create(&Help{Title: "h", Images: "https://elsewhere.example.com/e.jpg"})
+	DB.Create(&Settings{Key: "banners", Value: `[{"url":"/uploads/f.webp?v=1"}]`})
+
+	refs, err := ReferencedUploads()
+	if err != nil {
+		t.Fatalf("ReferencedUploads: %v", err)
+	}
+	for _, name := range []string{"a.jpg", "b.png", "c.png", "d.pdf", "f.webp"} {
+		if !refs[name] {
+			t.Errorf("%s not recognised as referenced", name)
+		}
+	}
+	if refs["e.jpg"] {
+		t.Errorf("external image e.jpg recognised as an upload")
+	}
+}
+
+func TestReferencedUploadsS3PublicURL(t *testing.T) {
+	setupTestDB(t)
+	_, s3 := newFakeS3(t, "uploads")
+	s3.PublicURL = "https://cdn.example.com/zx_uploads"
+	UploadStorage = s3
+
+	DB.Create(&News{Title: "n", Image: "https://cdn.example.com/zx_uploads/a.jpg"})
+	DB.Create(&Help{Title: "h", Images: "/uploads/b.jpg"}) // 配置公开地址之前保存的代理地址
+
+	refs, err := ReferencedUploads()
+	if err != nil {
+		t.Fatalf("ReferencedUploads: %v", err)
+	}
+	if !refs["a.jpg"] || !refs["b.jpg"] {
+		t.Errorf("refs = %v, want a.jpg and b.jpg", refs)
+	}
+}
+
+// 配置了 s3_public_url 时被引用的对象不能被当作孤立文件清理
+func TestRunUploadGCKeepsS3PublicURLReferences(t *testing.T) {
+	setupTestDB(t)
+	fake, s3 := newFakeS3(t, "uploads")
+	s3.PublicURL = "https://cdn.example.com/zx_uploads"
+	UploadStorage = s3
+
+	for _, key := range []string{"kept.jpg", "orphan.jpg", "fresh.jpg"} {
+		if err := s3.Put(key, strings.NewReader("x"), 1, "image/jpeg"); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+	old := time.Now().Add(-2 * UploadGCGracePeriod)
+	fake.setModTime("kept.jpg", old)
+	fake.setModTime("orphan.jpg", old)
+	DB.Create(&News{Title: "n", Image: s3.URL("kept.jpg")})
+
+	report, err := RunUploadGC(false)
+	if err != nil {
+		t.Fatalf("RunUploadGC: %v", err)
+	}
+	if report.Referenced != 1 || report.InGracePeriod != 1 || len(report.Orphans) != 1 || report.Orphans[0].Path != "orphan.jpg" {
+		t.Errorf("report = %+v, want kept.jpg referenced, fresh.jpg in grace period, orphan.jpg quarantined", report)
+	}
+	if got := strings.Join(fake.keys(), ","); got != ".quarantine/orphan.jpg,fresh.jpg,kept.jpg" {
+		t.Errorf("objects after GC = %s", got)
+	}
+
+	// 重新被引用后下一次清理移回原处
+	DB.Create(&Help{Title: "h", Images: s3.URL("orphan.jpg")})
+	report, err = RunUploadGC(false)
+	if err != nil {
+		t.Fatalf("RunUploadGC: %v", err)
+	}
+	if len(report.Restored) != 1 || report.Restored[0] != "orphan.jpg" {
+		t.Errorf("restored = %v, want [orphan.jpg]", report.Restored)
+	}
+	if got := strings.Join(fake.keys(), ","); got != "fresh.jpg,kept.jpg,orphan.jpg" {
+		t.Errorf("objects after restore = %s", got)
+	}
+}
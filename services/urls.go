@@ -0,0 +1,256 @@
+package services
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// 上传文件地址在数据库中统一保存为相对路径（/uploads/xxx.jpg），返回给客户端时再拼接对外访问地址，
+// 这样更换域名或部署在反向代理之后，已保存的图片链接不会失效
+
+// UploadPathPrefix 上传文件的相对路径前缀
+const UploadPathPrefix = "/uploads/"
+
+// PublicBaseURL 配置的对外访问地址（如 https://zx.example.com），为空时按请求的 Host / X-Forwarded-* 推断
+var PublicBaseURL string
+
+// MaxUploadHosts 最多登记的主机名数量，防止经代理转发的大量不同 Host 使登记表无限增长
+const MaxUploadHosts = 32
+
+// 属于本服务的主机名：本地开发地址、配置的对外地址，以及经可信代理访问时使用的 Host
+var (
+	uploadHostsMu sync.RWMutex
+	uploadHosts   = map[string]bool{"localhost": true, "127.0.0.1": true}
+)
+
+// RegisterUploadHost 记录本服务的对外主机名，客户端回传的该主机上的上传文件链接会被保存为相对路径
+// 只应传入配置的对外地址或可信代理转发的主机名，超过 MaxUploadHosts 后不再登记
+func RegisterUploadHost(host string) {
+	host = hostname(host)
+	if host == "" {
+		return
+	}
+	uploadHostsMu.RLock()
+	known := uploadHosts[host]
+	uploadHostsMu.RUnlock()
+	if known {
+		return
+	}
+	uploadHostsMu.Lock()
+	if len(uploadHosts) < MaxUploadHosts {
+		uploadHosts[host] = true
+	}
+	uploadHostsMu.Unlock()
+}
+
+// SetPublicBaseURL 设置对外访问地址并登记其主机名
+func SetPublicBaseURL(base string) {
+	PublicBaseURL = strings.TrimRight(base, "/")
+	if u, err := url.Parse(PublicBaseURL); err == nil {
+		RegisterUploadHost(u.Host)
+	}
+}
+
+func hostname(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, ok := strings.Cut(host, ":"); ok && !strings.HasPrefix(host, "[") {
+		return h
+	}
+	return host
+}
+
+var absoluteUploadURLPattern = regexp.MustCompile(`https?://([^/\s"',\\]+)/uploads/`)
+
+// RelativizeUploadURLs 将文本中指向本服务的上传文件绝对地址改写为相对路径
+// 支持单个地址、逗号分隔的多张图片和附件 JSON
+func RelativizeUploadURLs(s string) string {
+	if !strings.Contains(s, UploadPathPrefix) {
+		return s
+	}
+	uploadHostsMu.RLock()
+	defer uploadHostsMu.RUnlock()
+	return absoluteUploadURLPattern.ReplaceAllStringFunc(s, func(m string) string {
+		host := absoluteUploadURLPattern.FindStringSubmatch(m)[1]
+		if uploadHosts[hostname(host)] {
+			return UploadPathPrefix
+		}
+		return m
+	})
+}
+
+// uploadURLFields 响应中保存上传文件地址的字段（JSON 字段名），值为单个地址、逗号分隔的多个地址、
+// 地址映射（图片变体）或附件 JSON；其余字段（标题、正文等用户填写的文字）不做改写
+var uploadURLFields = map[string]bool{
+	"image":            true,
+	"images":           true,
+	"avatar":           true,
+	"author_avatar":    true,
+	"publisher_avatar": true,
+	"logo":             true,
+	"url":              true,
+	"variants":         true,
+	"attachments":      true,
+}
+
+// ExpandUploadURLs 将编码后的 JSON 响应中上传文件地址字段的相对路径展开为绝对地址
+func ExpandUploadURLs(s, base string) string {
+	if base == "" || !strings.Contains(s, UploadPathPrefix) {
+		return s
+	}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return s
+	}
+	var buf strings.Builder
+	if err := json.NewEncoder(&buf).Encode(expandUploadFields(v, strings.TrimRight(base, "/"), false)); err != nil {
+		return s
+	}
+	return buf.String()
+}
+
+// expandUploadFields 递归处理解码后的 JSON，inField 表示当前值位于地址字段之下
+func expandUploadFields(v interface{}, base string, inField bool) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, item := range x {
+			x[k] = expandUploadFields(item, base, inField || uploadURLFields[k])
+		}
+	case []interface{}:
+		for i, item := range x {
+			x[i] = expandUploadFields(item, base, inField)
+		}
+	case string:
+		if inField {
+			return expandUploadValue(x, base)
+		}
+	}
+	return v
+}
+
+// expandUploadValue 展开地址字段的值：附件 JSON 只处理其中的地址字段，其余按逗号分隔的地址列表处理
+func expandUploadValue(s, base string) string {
+	if !strings.Contains(s, UploadPathPrefix) {
+		return s
+	}
+	if strings.HasPrefix(strings.TrimSpace(s), "[") || strings.HasPrefix(strings.TrimSpace(s), "{") {
+		var v interface{}
+		dec := json.NewDecoder(strings.NewReader(s))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return s
+		}
+		data, err := json.Marshal(expandUploadFields(v, base, false))
+		if err != nil {
+			return s
+		}
+		return string(data)
+	}
+	parts := strings.Split(s, ",")
+	for i, p := range parts {
+		if strings.HasPrefix(p, UploadPathPrefix) {
+			parts[i] = base + p
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// ---------------- 写入时统一为相对路径 ----------------
+
+func (n *News) BeforeSave(tx *gorm.DB) error {
+	n.Image = RelativizeUploadURLs(n.Image)
+	return nil
+}
+
+func (f *Farmhouse) BeforeSave(tx *gorm.DB) error {
+	f.Image = RelativizeUploadURLs(f.Image)
+	f.Images = RelativizeUploadURLs(f.Images)
+	f.AuthorAvatar = RelativizeUploadURLs(f.AuthorAvatar)
+	return nil
+}
+
+func (p *Policy) BeforeSave(tx *gorm.DB) error {
+	p.Image = RelativizeUploadURLs(p.Image)
+	p.Images = RelativizeUploadURLs(p.Images)
+	p.Attachments = RelativizeUploadURLs(p.Attachments)
+	return nil
+}
+
+func (t *Tourism) BeforeSave(tx *gorm.DB) error {
+	t.Image = RelativizeUploadURLs(t.Image)
+	t.Images = RelativizeUploadURLs(t.Images)
+	t.PublisherAvatar = RelativizeUploadURLs(t.PublisherAvatar)
+	return nil
+}
+
+func (j *Job) BeforeSave(tx *gorm.DB) error {
+	j.Logo = RelativizeUploadURLs(j.Logo)
+	j.PublisherAvatar = RelativizeUploadURLs(j.PublisherAvatar)
+	return nil
+}
+
+func (h *Help) BeforeSave(tx *gorm.DB) error {
+	h.Image = RelativizeUploadURLs(h.Image)
+	h.Images = RelativizeUploadURLs(h.Images)
+	return nil
+}
+
+func (c *Consultation) BeforeSave(tx *gorm.DB) error {
+	c.Avatar = RelativizeUploadURLs(c.Avatar)
+	c.Images = RelativizeUploadURLs(c.Images)
+	return nil
+}
+
+func (u *User) BeforeSave(tx *gorm.DB) error {
+	u.Avatar = RelativizeUploadURLs(u.Avatar)
+	return nil
+}
+
+func (f *Favorite) BeforeSave(tx *gorm.DB) error {
+	f.Image = RelativizeUploadURLs(f.Image)
+	return nil
+}
+
+func (h *History) BeforeSave(tx *gorm.DB) error {
+	h.Image = RelativizeUploadURLs(h.Image)
+	return nil
+}
+
+// ---------------- 数据迁移 ----------------
+
+var localhostUploadURLPattern = regexp.MustCompile(`https?://(localhost|127\.0\.0\.1)(:\d+)?/uploads/`)
+
+// relativizeStoredUploadURLs 将已保存的 http://localhost:8080/uploads/ 绝对地址改写为相对路径
+// 扫描范围与孤立文件清理的引用字段一致（含回收站中的内容）
+func relativizeStoredUploadURLs(tx *gorm.DB) error {
+	for _, ref := range uploadReferences {
+		for _, col := range ref.Columns {
+			var rows []struct {
+				ID    int
+				Value string
+			}
+			err := tx.Table(ref.Table).Select("id, "+col+" AS value").
+				Where(col+" LIKE ? OR "+col+" LIKE ?", "%localhost%/uploads/%", "%127.0.0.1%/uploads/%").
+				Scan(&rows).Error
+			if err != nil {
+				return err
+			}
+			for _, row := range rows {
+				rewritten := localhostUploadURLPattern.ReplaceAllString(row.Value, UploadPathPrefix)
+				if rewritten == row.Value {
+					continue
+				}
+				if err := tx.Table(ref.Table).Where("id = ?", row.ID).UpdateColumn(col, rewritten).Error; err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestExpandUploadURLsOnlyURLFields(t *testing.T) {
+	body := `{"code":200,"data":{"id":7,"score":1.50,"image":"/uploads/a.jpg","images":"/uploads/b.jpg,https://x.example.com/c.jpg",` +
+		`"content":"see \"/uploads/secret\" and ,/uploads/x","variants":{"thumb":"/uploads/a_thumb.jpg"},` +
+		`"attachments":"[{\"name\":\"/uploads/ note\",\"url\":\"/uploads/d.pdf\"}]"}}` + "\n"
+
+	var got struct {
+		Data struct {
+			ID          json.Number       `json:"id"`
+			Score       json.Number       `json:"score"`
+			Image       string            `json:"image"`
+			Images      string            `json:"images"`
+			Content     string            `json:"content"`
+			Variants    map[string]string `json:"variants"`
+			Attachments string            `json:"attachments"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(ExpandUploadURLs(body, "https://zx.example.com/")), &got); err != nil {
+		t.Fatalf("expanded body is not JSON: %v", err)
+	}
+	d := got.Data
+	if d.ID != "7" || d.Score != "1.50" {
+		t.Errorf("numbers changed: id %s, score %s", d.ID, d.Score)
+	}
+	if d.Image != "https://zx.example.com/uploads/a.jpg" {
+		t.Errorf("image = %q", d.Image)
+	}
+	if d.Images != "https://zx.example.com/uploads/b.jpg,https://x.example.com/c.jpg" {
+		t.Errorf("images = %q", d.Images)
+	}
+	if d.Content != `see "/uploads/secret" and ,/uploads/x` {
+		t.Errorf("user text rewritten: %q", d.Content)
+	}
+	if d.Variants["thumb"] != "https://zx.example.com/uploads/a_thumb.jpg" {
+		t.Errorf("variants = %v", d.Variants)
+	}
+	if d.Attachments != `[{"name":"/uploads/ note","url":"https://zx.example.com/uploads/d.pdf"}]` {
+		t.Errorf("attachments = %q", d.Attachments)
+	}
+}
+
+func TestRegisterUploadHostBounded(t *testing.T) {
+	uploadHostsMu.Lock()
+	saved := uploadHosts
+	uploadHosts = map[string]bool{"localhost": true}
+	uploadHostsMu.Unlock()
+	t.Cleanup(func() {
+		uploadHostsMu.Lock()
+		uploadHosts = saved
+		uploadHostsMu.Unlock()
+	})
+
+	for i := 0; i < MaxUploadHosts*2; i++ {
+		RegisterUploadHost(fmt.Sprintf("h%d.example.com:8080", i))
+	}
+	if len(uploadHosts) != MaxUploadHosts {
+		t.Errorf("registered %d hosts, want at most %d", len(uploadHosts), MaxUploadHosts)
+	}
+	if got := RelativizeUploadURLs("https://h1.example.com/uploads/a.jpg"); got != "/uploads/a.jpg" {
+		t.Errorf("registered host not relativized: %q", got)
+	}
+	if got := RelativizeUploadURLs("https://h60.example.com/uploads/a.jpg"); got != "https://h60.example.com/uploads/a.jpg" {
+		t.Errorf("host beyond the limit relativized: %q", got)
+	}
+}
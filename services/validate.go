@@ -0,0 +1,186 @@
+package services
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 取值范围受限的字段（validate 标签中的 enum=名称），由配置覆盖；列表为空时不限制取值
+var ValidationEnums = map[string][]string{
+	"news_category":         {"乡村动态", "政策解读", "产业发展", "文化旅游", "通知公告", "其他"},
+	"policy_category":       {"乡村振兴", "土地政策", "农业补贴", "产业扶持", "社会保障", "其他"},
+	"tourism_category":      {"自然风光", "人文古迹", "农家乐", "采摘体验", "民俗文化", "其他"},
+	"help_category":         {"农业生产", "生活求助", "医疗健康", "交通出行", "其他"},
+	"help_urgency":          {"紧急", "一般", "urgent", "normal"},
+	"job_type":              {"全职", "兼职", "临时工", "实习"},
+	"consultation_category": {"政策咨询", "技术咨询", "市场咨询", "其他"},
+	"consultation_status":   {"待回复", "已回复", "已解决"},
+	"feedback_type":         {"功能建议", "问题反馈", "内容投诉", "其他"},
+}
+
+// 校验规则名，也是字段错误中的 rule
+const (
+	RuleRequired = "required"   // 必填，去掉首尾空白后不能为空
+	RuleMax      = "max"        // 字符串为最大字符数，数值为最大值
+	RuleMaxLen   = "max_length" // 字符串超过 max 时报告的规则名
+	RuleMin      = "min"        // 数值最小值
+	RulePhone    = "phone"      // 手机号或座机号
+	RuleURL      = "url"        // http(s) 地址或站内路径
+	RuleURLs     = "urls"       // 逗号分隔的多个地址
+	RuleEnum     = "enum"       // 取值须在 ValidationEnums 对应列表中
+	RuleEmail    = "email"      // 电子邮箱地址
+)
+
+// phonePattern 手机号（可带 +86）、座机号（区号-号码，可带分机）或 400 电话
+var phonePattern = regexp.MustCompile(`^(?:(?:\+?86)?1[3-9]\d{9}|0\d{2,3}-?\d{7,8}(?:-\d{1,6})?|400-?\d{3}-?\d{4})$`)
+
+// FieldError 单个字段的校验错误，Field 为 JSON 字段名，Param 为规则参数（如最大长度、可选值）
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+// ValidationError 写入内容时字段校验未通过
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Field + ": " + f.Rule
+		if f.Param != "" {
+			parts[i] += "=" + f.Param
+		}
+	}
+	return "validation failed: " + strings.Join(parts, ", ")
+}
+
+// Validate 按结构体字段的 validate 标签校验，返回所有未通过的字段（*ValidationError），全部通过时返回 nil
+// 标签示例：`validate:"required,max=100"`、`validate:"phone"`、`validate:"min=0,max=5"`、`validate:"enum=job_type"`
+// 除 required 外，字段为空（零值或 nil 指针）时不检查其他规则；标签写错（未知规则、参数无效）时返回普通错误
+func Validate(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	rt := rv.Type()
+	var errs []FieldError
+	for i := 0; i < rt.NumField(); i++ {
+		tag := rt.Field(i).Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(rt.Field(i).Tag.Get("json"), ",")
+		if name == "" {
+			name = rt.Field(i).Name
+		}
+		fe, ok, err := checkField(rv.Field(i), tag)
+		if err != nil {
+			return fmt.Errorf("validate %s.%s: %w", rt.Name(), rt.Field(i).Name, err)
+		}
+		if !ok {
+			fe.Field = name
+			errs = append(errs, fe)
+		}
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Fields: errs}
+	}
+	return nil
+}
+
+// checkField 依次检查字段的各条规则，返回第一条未通过的规则；指针字段检查其指向的值
+func checkField(v reflect.Value, tag string) (FieldError, bool, error) {
+	rules := strings.Split(tag, ",")
+	if v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.String && strings.TrimSpace(v.String()) == "" || v.IsZero() {
+		if slices.Contains(rules, RuleRequired) {
+			return FieldError{Rule: RuleRequired}, false, nil
+		}
+		return FieldError{}, true, nil
+	}
+
+	for _, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		ok := true
+		var err error
+		switch name {
+		case RuleRequired:
+		case RuleMax, RuleMin:
+			ok, err = checkBound(v, name, param)
+		case RulePhone:
+			ok = phonePattern.MatchString(strings.ReplaceAll(v.String(), " ", ""))
+		case RuleURL:
+			ok = validURL(v.String())
+		case RuleURLs:
+			for _, item := range strings.Split(v.String(), ",") {
+				if item = strings.TrimSpace(item); item != "" && !validURL(item) {
+					ok = false
+					break
+				}
+			}
+		case RuleEnum:
+			allowed, known := ValidationEnums[param]
+			if !known {
+				err = fmt.Errorf("unknown enum %q", param)
+			} else if ok = len(allowed) == 0 || slices.Contains(allowed, v.String()); !ok {
+				param = strings.Join(allowed, ",")
+			}
+		case RuleEmail:
+			addr, perr := mail.ParseAddress(v.String())
+			ok = perr == nil && addr.Address == v.String()
+		default:
+			err = fmt.Errorf("unknown validation rule %q", rule)
+		}
+		if err != nil {
+			return FieldError{}, false, err
+		}
+		if !ok {
+			if name == RuleMax && v.Kind() == reflect.String {
+				name = RuleMaxLen
+			}
+			return FieldError{Rule: name, Param: param}, false, nil
+		}
+	}
+	return FieldError{}, true, nil
+}
+
+// checkBound 字符串按字符数比较，数值按大小比较
+func checkBound(v reflect.Value, rule, param string) (bool, error) {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid parameter %s=%q", rule, param)
+	}
+	var n float64
+	switch v.Kind() {
+	case reflect.String:
+		n = float64(utf8.RuneCountInString(v.String()))
+	case reflect.Int, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Float64:
+		n = v.Float()
+	default:
+		return false, fmt.Errorf("rule %s not supported for %s", rule, v.Type())
+	}
+	if rule == RuleMax {
+		return n <= limit, nil
+	}
+	return n >= limit, nil
+}
+
+// validURL 接受绝对的 http(s) 地址或以 / 开头的站内路径（上传文件保存为相对路径）
+func validURL(s string) bool {
+	if strings.HasPrefix(s, "/") && !strings.HasPrefix(s, "//") {
+		return true
+	}
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
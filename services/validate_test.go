@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateFieldErrors(t *testing.T) {
+	news := News{Title: "", Category: "不存在的分类", Image: "javascript:alert(1)", PublisherID: "u1"}
+	err := Validate(&news)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Validate = %v, want *ValidationError", err)
+	}
+	got := map[string]string{}
+	for _, f := range verr.Fields {
+		got[f.Field] = f.Rule
+	}
+	want := map[string]string{"title": RuleRequired, "category": RuleEnum, "image": RuleURL}
+	if len(got) != len(want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+	for field, rule := range want {
+		if got[field] != rule {
+			t.Errorf("%s: rule %q, want %q", field, got[field], rule)
+		}
+	}
+}
+
+func TestValidateDefaultCategories(t *testing.T) {
+	for _, name := range []string{"news_category", "policy_category", "tourism_category", "help_category"} {
+		if len(ValidationEnums[name]) == 0 {
+			t.Errorf("%s has no default values", name)
+		}
+	}
+}
+
+func TestValidatePointerFields(t *testing.T) {
+	long := string(make([]rune, 51))
+	bad, email := "not-an-email", "a@example.com"
+	if err := Validate(&UserProfileUpdate{}); err != nil {
+		t.Errorf("empty update: %v", err)
+	}
+	if err := Validate(&UserProfileUpdate{Email: &email}); err != nil {
+		t.Errorf("valid email: %v", err)
+	}
+	err := Validate(&UserProfileUpdate{Nickname: &long, Email: &bad})
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Fields) != 2 ||
+		verr.Fields[0].Rule != RuleMaxLen || verr.Fields[1].Rule != RuleEmail {
+		t.Errorf("Validate = %v, want nickname max_length and email errors", err)
+	}
+}
+
+// 标签写错时返回普通错误，不是字段错误，也不 panic
+func TestValidateInvalidTag(t *testing.T) {
+	for _, v := range []interface{}{
+		&struct {
+			A string `validate:"bogus"`
+		}{A: "x"},
+		&struct {
+			A string `validate:"max=abc"`
+		}{A: "x"},
+		&struct {
+			A string `validate:"enum=missing"`
+		}{A: "x"},
+		&struct {
+			A bool `validate:"max=1"`
+		}{A: true},
+	} {
+		err := Validate(v)
+		var verr *ValidationError
+		if err == nil || errors.As(err, &verr) {
+			t.Errorf("Validate(%+v) = %v, want a non-field error", v, err)
+		}
+	}
+}
+
+func TestSaveBannersValidates(t *testing.T) {
+	setupTestDB(t)
+	err := SaveBanners([]Banner{{URL: "/uploads/a.jpg", Title: "ok"}, {URL: "ftp://x"}})
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Fields) != 1 || verr.Fields[0].Field != "banners[1].url" {
+		t.Fatalf("SaveBanners = %v, want banners[1].url error", err)
+	}
+	if err := SaveBanners([]Banner{{URL: "/uploads/a.jpg", Title: "ok"}}); err != nil {
+		t.Fatalf("SaveBanners: %v", err)
+	}
+}
+
+func TestUpdateUserProfileIgnoresRole(t *testing.T) {
+	setupTestDB(t)
+	u := User{WechatID: "wx1", Nickname: "old", Role: "user"}
+	DB.Create(&u)
+
+	nickname := "new"
+	if err := UpdateUserProfile(u.ID, UserProfileUpdate{Nickname: &nickname}); err != nil {
+		t.Fatalf("UpdateUserProfile: %v", err)
+	}
+	got, _ := GetUserProfileByID(u.ID)
+	if got.Nickname != "new" || got.Role != "user" {
+		t.Errorf("user = %+v", got)
+	}
+
+	bad := "12"
+	var verr *ValidationError
+	if err := UpdateUserProfile(u.ID, UserProfileUpdate{Phone: &bad}); !errors.As(err, &verr) {
+		t.Errorf("invalid phone: err = %v, want *ValidationError", err)
+	}
+}
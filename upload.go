@@ -0,0 +1,439 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"zxbe_demo/services"
+)
+
+// multipartOverhead 普通上传请求体中 multipart 边界和表单头部的余量
+const multipartOverhead = 64 << 10
+
+// 文件上传处理函数
+func uploadHandler(w http.ResponseWriter, r *http.Request) {
+	// 上传需登录，并按角色限制频率；请求体大小超出当天剩余配额时不再读取
+	uploaderID, role, ok := uploadIdentity(w, r)
+	if !ok {
+		return
+	}
+	if err := services.AllowUploadRequest(uploaderID, role); err != nil {
+		sendUploadLimitError(w, r, err)
+		return
+	}
+	if r.ContentLength > 0 {
+		if err := services.CheckUploadQuota(uploaderID, role, r.ContentLength); err != nil {
+			sendUploadLimitError(w, r, err)
+			return
+		}
+	}
+
+	// 请求体按单文件上限和当天剩余配额中的较小者限制，未声明长度（分块传输）的请求同样受限
+	limit := services.MaxUploadFileSize
+	remaining, err := services.RemainingUploadBytes(uploaderID, role)
+	if err != nil {
+		sendUploadLimitError(w, r, err)
+		return
+	}
+	quotaLimited := remaining >= 0 && remaining < limit
+	if quotaLimited {
+		limit = remaining
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limit+multipartOverhead)
+
+	extendReadDeadline(w)
+
+	// 解析multipart form，超过 10MB 的部分写入临时文件
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) && quotaLimited {
+			if qerr := services.CheckUploadQuota(uploaderID, role, limit+1); qerr != nil {
+				sendUploadLimitError(w, r, qerr)
+				return
+			}
+		}
+		sendError(w, r, errFileTooLarge)
+		return
+	}
+
+	file, handler, err := r.FormFile("file")
+	if err != nil {
+		sendError(w, r, errNoFile)
+		return
+	}
+	defer file.Close()
+
+	originalName := handler.Filename
+	ext := strings.ToLower(filepath.Ext(originalName))
+
+	// 检查文件类型（扩展名白名单，真实内容在写入后校验）
+	if _, ok := services.LookupFileKind(ext); !ok {
+		sendError(w, r, errFileTypeNotAllowed)
+		return
+	}
+
+	// 先写入本地临时文件并同时计算内容哈希，校验处理后按哈希命名写入存储，相同内容只保存一份
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		sendError(w, r, errInternal)
+		return
+	}
+	tmpPath := tmp.Name()
+
+	hasher := sha256.New()
+	fileSize, err := io.Copy(io.MultiWriter(tmp, hasher), file)
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		sendError(w, r, errInternal)
+		return
+	}
+	if fileSize > services.MaxUploadFileSize {
+		os.Remove(tmpPath)
+		sendError(w, r, errFileTooLarge)
+		return
+	}
+
+	finishUpload(w, r, tmpPath, hex.EncodeToString(hasher.Sum(nil)), fileSize, originalName, nil)
+}
+
+// finishUpload 处理已完整写入本地临时文件的上传：校验内容、清理元数据、按哈希入库、生成图片变体并返回文件信息
+// 普通上传和分片上传合并后共用，临时文件在返回前删除
+// session 非空时为分片上传，配额已在初始化会话时按声明大小占用，处理失败时归还
+func finishUpload(w http.ResponseWriter, r *http.Request, tmpPath, hash string, fileSize int64, originalName string, session *services.UploadSession) {
+	ext := strings.ToLower(filepath.Ext(originalName))
+	releaseQuota := func() {}
+	if session != nil {
+		releaseQuota = func() { services.ReleaseUploadSessionQuota(session) }
+	}
+
+	// 校验文件头魔数与扩展名一致，图片完整解码并检查尺寸
+	mimeType, err := services.ValidateUploadFile(tmpPath, ext)
+	if err != nil {
+		os.Remove(tmpPath)
+		releaseQuota()
+		log.Printf("❌ 上传文件校验失败 (%s): %v", originalName, err)
+		if errors.Is(err, services.ErrFileRejected) {
+			sendError(w, r, errFileContentMismatch)
+		} else {
+			sendError(w, r, errInternal)
+		}
+		return
+	}
+
+	// 去除照片的 EXIF/GPS 等元数据（先应用方向），内容变化后重新计算哈希
+	changed, err := services.SanitizeImageFile(tmpPath, mimeType)
+	if err == nil && changed {
+		hash, fileSize, err = services.HashFile(tmpPath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		releaseQuota()
+		log.Printf("❌ 清理图片元数据失败 (%s): %v", originalName, err)
+		if errors.Is(err, services.ErrFileRejected) {
+			sendError(w, r, errFileContentMismatch)
+		} else {
+			sendError(w, r, errInternal)
+		}
+		return
+	}
+
+	// 按处理后的实际大小占用当天配额（分片上传按实际大小调整已占用的配额），保存失败时归还
+	uploaderID := r.Header.Get("X-Wechat-ID")
+	if session != nil {
+		err = services.SettleUploadSessionQuota(session, fileSize)
+	} else if err = services.ReserveUploadQuota(uploaderID, getOperatorRole(uploaderID), fileSize); err == nil {
+		releaseQuota = func() { services.ReleaseUploadQuota(uploaderID, fileSize) }
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		releaseQuota()
+		sendUploadLimitError(w, r, err)
+		return
+	}
+
+	saved, deduplicated, err := services.SaveUpload(tmpPath, hash, ext, services.UploadFile{
+		OriginalName: originalName,
+		Size:         fileSize,
+		MimeType:     mimeType,
+		UploaderID:   uploaderID,
+	})
+	if err != nil {
+		releaseQuota()
+		log.Printf("❌ 保存上传文件失败: %v", err)
+		sendError(w, r, errInternal)
+		return
+	}
+	result := "stored"
+	if deduplicated {
+		result = "deduplicated"
+		log.Printf("♻️ 重复上传，复用已有文件: %s", saved.Path)
+	}
+	uploadFiles.Inc(result)
+	uploadBytes.Add(float64(fileSize), result)
+
+	// 获取文件类型描述
+	fileType := getFileTypeDescription(ext)
+
+	// 返回详细的文件信息
+	fileURL := services.UploadStorage.URL(saved.Path)
+
+	// 图片生成缩略图等尺寸变体
+	variants := map[string]string{}
+	if services.IsImageFile(saved.Path) {
+		if err := services.EnsureImageVariants(saved.Path); err != nil {
+			log.Printf("⚠️ 生成图片变体失败 (%s): %v", saved.Path, err)
+		} else {
+			for _, v := range services.ImageVariants {
+				variants[v.Name] = services.UploadStorage.URL(services.VariantFileName(saved.Path, v.Name))
+			}
+		}
+	}
+
+	sendSuccess(w, map[string]interface{}{
+		"url":          fileURL,
+		"name":         originalName,
+		"size":         saved.Size,
+		"path":         saved.Path,
+		"hash":         saved.Hash,
+		"type":         ext,
+		"type_desc":    fileType,
+		"mime_type":    saved.MimeType,
+		"variants":     variants,
+		"deduplicated": deduplicated,
+		"upload_time":  time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// uploadIdentity 获取上传者身份（微信用户或管理员账号）及角色，未登录或已封禁时写入错误响应并返回 false
+func uploadIdentity(w http.ResponseWriter, r *http.Request) (uploaderID, role string, ok bool) {
+	uploaderID = r.Header.Get("X-Wechat-ID")
+	if uploaderID == "" {
+		sendError(w, r, errUnauthorized)
+		return "", "", false
+	}
+	role = getOperatorRole(uploaderID)
+	switch role {
+	case "":
+		sendError(w, r, errAccountNotFound)
+		return "", "", false
+	case "banned":
+		sendError(w, r, errUserBanned)
+		return "", "", false
+	}
+	return uploaderID, role, true
+}
+
+// sendUploadLimitError 超出上传配额或频率限制时返回 429，data 中说明超出的限制、已用量和重置时间
+func sendUploadLimitError(w http.ResponseWriter, r *http.Request, err error) {
+	var quotaErr *services.QuotaError
+	if !errors.As(err, &quotaErr) {
+		log.Printf("❌ 检查上传配额失败: %v", err)
+		sendError(w, r, errInternal)
+		return
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(quotaErr.RetryAfter))
+	apiErr := errUploadQuotaExceeded
+	if quotaErr.Limit == services.QuotaPerMinute {
+		apiErr = errUploadRateLimited
+	}
+	sendErrorData(w, r, apiErr, quotaErr)
+}
+
+// uploadsFileHandler 从上传文件存储读取并提供访问，Content-Type 以上传时校验得到的类型为准
+// 不提供目录列表；图片内联显示，文档一律作为附件下载；按内容哈希命名的文件长期缓存，支持 ETag 和 Range
+func uploadsFileHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" {
+			setCORSOrigin(w, r)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Range")
+			return
+		}
+		if r.Method != "GET" && r.Method != "HEAD" {
+			w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// 只提供存储根目录下的文件，隐藏文件（临时文件、隔离区、分片）不对外提供
+		name := strings.TrimPrefix(r.URL.Path, "/uploads/")
+		if name == "" || strings.Contains(name, "/") || strings.HasPrefix(name, ".") {
+			http.NotFound(w, r)
+			return
+		}
+
+		// ?variant=thumb 返回对应尺寸变体，旧图片按需生成
+		if variant := r.URL.Query().Get("variant"); variant != "" && services.IsImageFile(name) {
+			variantName, err := services.EnsureImageVariant(name, variant)
+			if err != nil {
+				if errors.Is(err, services.ErrObjectNotFound) {
+					http.NotFound(w, r)
+					return
+				}
+				log.Printf("⚠️ 生成图片变体失败 (%s/%s): %v", name, variant, err)
+			} else {
+				name = variantName
+			}
+		}
+
+		f, info, err := services.UploadStorage.Get(name)
+		if err != nil {
+			if errors.Is(err, services.ErrObjectNotFound) {
+				http.NotFound(w, r)
+				return
+			}
+			log.Printf("❌ 读取上传文件失败 (%s): %v", name, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		if info.Size > 1<<20 {
+			extendWriteDeadline(w)
+		}
+
+		contentType, originalName := uploadContentType(name)
+		h := w.Header()
+		h.Set("Content-Type", contentType)
+		h.Set("X-Content-Type-Options", "nosniff")
+		// 上传内容不允许执行脚本或加载其他资源（防止伪装成文档的 HTML/SVG 在本域下执行）
+		h.Set("Content-Security-Policy", "default-src 'none'; img-src 'self'; style-src 'unsafe-inline'; sandbox")
+
+		if strings.HasPrefix(contentType, "image/") {
+			// 图片允许配置的来源跨域读取（管理后台在 canvas 中裁剪等）
+			setCORSOrigin(w, r)
+			h.Set("Cross-Origin-Resource-Policy", "cross-origin")
+		} else {
+			h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": originalName}))
+			h.Set("Cross-Origin-Resource-Policy", "same-site")
+		}
+
+		// 按内容哈希命名的文件内容永不改变，ETag 直接使用哈希；旧文件名的文件需重新验证
+		if _, ok := services.ContentHashFromName(name); ok {
+			h.Set("Cache-Control", "public, max-age=31536000, immutable")
+			h.Set("ETag", `"`+strings.TrimSuffix(name, filepath.Ext(name))+`"`)
+		} else {
+			h.Set("Cache-Control", "public, no-cache")
+			h.Set("ETag", fmt.Sprintf(`"%x-%x"`, info.Size, info.ModTime.UnixNano()))
+		}
+
+		http.ServeContent(w, r, name, info.ModTime, f)
+	}
+}
+
+// initStorage 根据配置选择上传文件存储：storage_backend 为 s3 时使用 S3 兼容对象存储，默认本地上传目录
+func initStorage() {
+	c := config.Upload
+	services.UploadDir = c.Dir
+	switch c.StorageBackend {
+	case "local":
+		os.MkdirAll(services.UploadDir, 0755)
+		services.UploadStorage = services.NewLocalStorage(services.UploadDir)
+		log.Printf("📁 上传文件存储: 本地目录 %s", services.UploadDir)
+	case "s3":
+		services.UploadStorage = services.NewS3Storage(c.S3Endpoint, c.S3Bucket, c.S3Region, c.S3AccessKey, c.S3SecretKey, c.S3PublicURL)
+		log.Printf("☁️ 上传文件存储: S3 %s/%s", c.S3Endpoint, c.S3Bucket)
+	}
+}
+
+// initUploadQuotas 应用各角色的上传限制（格式已在加载配置时校验）
+func initUploadQuotas() {
+	for role, v := range map[string]string{"user": config.Upload.QuotaUser, "vip": config.Upload.QuotaVIP, "admin": config.Upload.QuotaAdmin} {
+		services.UploadQuotas[role], _ = parseUploadQuota(v)
+	}
+}
+
+// initUploadGC 应用孤立文件宽限期和隔离期并启动清理任务
+func initUploadGC(stop <-chan struct{}) {
+	services.UploadGCGracePeriod = time.Duration(config.Upload.GCGraceHours) * time.Hour
+	services.UploadQuarantinePeriod = time.Duration(config.Upload.QuarantineDays) * 24 * time.Hour
+	services.StartUploadGCWorker(6*time.Hour, stop)
+}
+
+// 管理员孤立文件清理：GET 只返回报告（dry run），POST 立即执行
+func adminUploadGCHandler(w http.ResponseWriter, r *http.Request) {
+	wechatID := r.Header.Get("X-Wechat-ID")
+
+	dryRun := r.Method == "GET"
+	report, err := services.RunUploadGC(dryRun)
+	if err != nil {
+		log.Printf("❌ 孤立文件清理失败: %v", err)
+		sendError(w, r, errInternal)
+		return
+	}
+
+	if !dryRun {
+		log.Printf("🧹 孤立文件清理: 隔离 %d 个，恢复 %d 个，删除 %d 个 (操作者: %s)",
+			len(report.Orphans), len(report.Restored), len(report.Purged), wechatID)
+		recordAudit(r, wechatID, services.AuditUploadsGC, "uploads", "", nil, report)
+	}
+	sendSuccess(w, report)
+}
+
+// 管理员查看各用户的上传文件占用（文件按首次上传者计入）及当天用量
+func adminUploadUsageHandler(w http.ResponseWriter, r *http.Request) {
+	page := 1
+	pageSize := 20
+	if p := r.URL.Query().Get("page"); p != "" {
+		fmt.Sscanf(p, "%d", &page)
+	}
+	if ps := r.URL.Query().Get("page_size"); ps != "" {
+		fmt.Sscanf(ps, "%d", &pageSize)
+	}
+
+	list, total, err := services.UploadUsageByUser(page, pageSize)
+	if err != nil {
+		log.Printf("❌ 获取上传用量失败: %v", err)
+		sendError(w, r, errInternal)
+		return
+	}
+
+	sendSuccess(w, map[string]interface{}{
+		"list":      list,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"quotas":    services.UploadQuotas,
+	})
+}
+
+// uploadContentType 获取上传文件的权威 MIME 类型和下载时使用的原始文件名：
+// 优先使用元数据表，其次按白名单扩展名，否则按二进制流下载
+func uploadContentType(name string) (contentType, originalName string) {
+	originalName = name
+	if meta, err := services.GetUploadByPath(name); err == nil {
+		if meta.OriginalName != "" {
+			originalName = meta.OriginalName
+		}
+		if meta.MimeType != "" {
+			return meta.MimeType, originalName
+		}
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	if kind, ok := services.LookupFileKind(ext); ok {
+		return kind.MimeType, originalName
+	}
+	if t := mime.TypeByExtension(ext); strings.HasPrefix(t, "image/") {
+		return t, originalName
+	}
+	return "application/octet-stream", originalName
+}
+
+// 获取文件类型描述
+func getFileTypeDescription(ext string) string {
+	if kind, ok := services.LookupFileKind(ext); ok {
+		return kind.Desc
+	}
+	return "未知文件"
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"zxbe_demo/services"
+)
+
+// initValidation 以配置覆盖各字段的可选值
+func initValidation() {
+	v := config.Validation
+	for name, values := range map[string][]string{
+		"news_category":         v.NewsCategories,
+		"policy_category":       v.PolicyCategories,
+		"tourism_category":      v.TourismCategories,
+		"help_category":         v.HelpCategories,
+		"help_urgency":          v.HelpUrgencies,
+		"job_type":              v.JobTypes,
+		"consultation_category": v.ConsultationCategories,
+		"feedback_type":         v.FeedbackTypes,
+	} {
+		services.ValidationEnums[name] = values
+	}
+}
+
+// ruleMessages 字段校验规则的中英文提示，%s 为规则参数
+var ruleMessages = map[string][2]string{
+	services.RuleRequired: {"不能为空", "is required"},
+	services.RuleMax:      {"不能大于 %s", "must be at most %s"},
+	services.RuleMaxLen:   {"不能超过 %s 个字符", "must be at most %s characters"},
+	services.RuleMin:      {"不能小于 %s", "must be at least %s"},
+	services.RulePhone:    {"电话号码格式不正确", "is not a valid phone number"},
+	services.RuleURL:      {"链接格式不正确", "is not a valid URL"},
+	services.RuleURLs:     {"包含格式不正确的链接", "contains an invalid URL"},
+	services.RuleEnum:     {"取值须为：%s", "must be one of: %s"},
+	services.RuleEmail:    {"邮箱格式不正确", "is not a valid email address"},
+}
+
+// sendWriteError 写入内容失败时返回错误：字段校验未通过时返回 VALIDATION_FAILED 和各字段的错误，其余按服务器错误处理
+func sendWriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var verr *services.ValidationError
+	if !errors.As(err, &verr) {
+		log.Printf("❌ 写入失败: %v", err)
+		sendError(w, r, errInternal)
+		return
+	}
+
+	lang := requestLang(r)
+	fields := make([]map[string]string, len(verr.Fields))
+	for i, f := range verr.Fields {
+		msg := ruleMessages[f.Rule][0]
+		if lang == "en" {
+			msg = ruleMessages[f.Rule][1]
+		}
+		if f.Param != "" {
+			msg = fmt.Sprintf(msg, f.Param)
+		}
+		fields[i] = map[string]string{"field": f.Field, "rule": f.Rule, "message": msg}
+		if f.Param != "" {
+			fields[i]["param"] = f.Param
+		}
+	}
+	sendErrorData(w, r, errValidationFailed, map[string]interface{}{"errors": fields})
+}